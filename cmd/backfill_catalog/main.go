@@ -0,0 +1,152 @@
+// Command backfill_catalog maps supply rows stored before the item catalog existed onto it.
+//
+// Rows written since migration 0015 are mapped by a trigger; this fills category_id and
+// catalog_item_id where they are still empty: the item from name, the category from tag
+// (supply_items) or required_type (requirements_supplies) and otherwise from the item. Values
+// that match nothing are listed, most used first, so they can be added to the catalog as aliases
+// and the command run again. With -dry the changes are rolled back.
+//
+//	go run ./cmd/backfill_catalog -dry
+//	go run ./cmd/backfill_catalog -tables supply_items -v
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// tagColumns are the tables to map and their free-text category column.
+var tagColumns = map[string]string{
+	"supply_items":          "tag",
+	"requirements_supplies": "required_type",
+}
+
+type stats struct {
+	items, categories, unmapped int
+}
+
+func main() {
+	dryRun := flag.Bool("dry", false, "Dry run: report what would be mapped, write nothing")
+	tablesFlag := flag.String("tables", "supply_items,requirements_supplies", "Comma separated tables to map")
+	verbose := flag.Bool("v", false, "Print every mapped row")
+	flag.Parse()
+
+	var tables []string
+	for _, name := range strings.Split(*tablesFlag, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := tagColumns[name]; !ok {
+			log.Fatalf("unknown table %q", name)
+		}
+		tables = append(tables, name)
+	}
+
+	cfg := config.Load()
+	pool, err := db.Connect(cfg)
+	if err != nil {
+		log.Fatalf("db connect: %v", err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	if !*dryRun {
+		// make sure the catalog tables, columns and resolve functions exist
+		if err := db.Migrate(ctx, pool); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	total := stats{}
+	for _, table := range tables {
+		st, err := backfillTable(ctx, tx, table, tagColumns[table], *verbose)
+		if err != nil {
+			log.Fatalf("%s: %v", table, err)
+		}
+		log.Printf("%s: items=%d categories=%d unmapped=%d", table, st.items, st.categories, st.unmapped)
+		total.items += st.items
+		total.categories += st.categories
+		total.unmapped += st.unmapped
+	}
+	prefix := "done."
+	if *dryRun {
+		prefix = "[DRY] nothing written."
+	} else if err := tx.Commit(ctx); err != nil {
+		log.Fatalf("commit: %v", err)
+	}
+	log.Printf("%s items=%d categories=%d unmapped=%d", prefix, total.items, total.categories, total.unmapped)
+}
+
+func backfillTable(ctx context.Context, tx pgx.Tx, table, tagColumn string, verbose bool) (stats, error) {
+	st := stats{}
+	// items first so rows without a usable tag can take the category of their item
+	n, err := mapRows(ctx, tx, table, "catalog_item_id", "resolve_catalog_item(name)", verbose)
+	if err != nil {
+		return st, err
+	}
+	st.items = n
+	n, err = mapRows(ctx, tx, table, "category_id",
+		"coalesce(resolve_catalog_category("+tagColumn+"), (select i.category_id from catalog_items i where i.id = t.catalog_item_id))", verbose)
+	if err != nil {
+		return st, err
+	}
+	st.categories = n
+
+	for _, col := range []struct{ value, empty string }{{tagColumn, "category_id"}, {"name", "catalog_item_id"}} {
+		rows, err := tx.Query(ctx, "select btrim("+col.value+"), count(*) from "+table+
+			" where "+col.empty+" is null and deleted_at is null and coalesce(btrim("+col.value+"),'')<>''"+
+			" group by 1 order by 2 desc, 1")
+		if err != nil {
+			return st, err
+		}
+		for rows.Next() {
+			var value string
+			var count int
+			if err := rows.Scan(&value, &count); err != nil {
+				rows.Close()
+				return st, err
+			}
+			st.unmapped += count
+			fmt.Printf("? %s.%s %q x%d\n", table, col.value, value, count)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// mapRows sets column to expr on the rows where it is empty and expr resolves, returning how many.
+func mapRows(ctx context.Context, tx pgx.Tx, table, column, expr string, verbose bool) (int, error) {
+	rows, err := tx.Query(ctx, "update "+table+" t set "+column+" = m.v from (select id, "+expr+" as v from "+table+
+		" t where "+column+" is null) m where t.id = m.id and m.v is not null returning t.id, t."+column)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id, value string
+		if err := rows.Scan(&id, &value); err != nil {
+			return n, err
+		}
+		n++
+		if verbose {
+			fmt.Printf("~ %s/%s %s=%s\n", table, id, column, value)
+		}
+	}
+	return n, rows.Err()
+}
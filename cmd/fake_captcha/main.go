@@ -0,0 +1,23 @@
+// Command fake_captcha serves turnstile.NewFakeServer so the API can be run with captcha
+// verification on but without real provider keys. Point TURNSTILE_API_URL, HCAPTCHA_API_URL or
+// RECAPTCHA_API_URL at it, e.g. TURNSTILE_API_URL=http://localhost:8787/turnstile/v0/siteverify.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"guangfu250923/internal/turnstile"
+)
+
+func main() {
+	addr := os.Getenv("FAKE_CAPTCHA_ADDR")
+	if addr == "" {
+		addr = ":8787"
+	}
+	log.Printf("fake captcha verifier listening on %s (tokens starting with \"pass\" succeed)", addr)
+	if err := http.ListenAndServe(addr, turnstile.NewFakeServer()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,28 @@
+// Command fake_line serves lineauth.NewFakeServer so LINE Login can be exercised locally without a
+// LINE channel. It uses the server's LINE_CHANNEL_ID / LINE_CHANNEL_SECRET; point the server at it
+// with LINE_AUTHORIZE_URL=http://localhost:8788/oauth2/v2.1/authorize and
+// LINE_TOKEN_URL=http://localhost:8788/oauth2/v2.1/token.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"guangfu250923/internal/lineauth"
+)
+
+func main() {
+	addr := os.Getenv("FAKE_LINE_ADDR")
+	if addr == "" {
+		addr = ":8788"
+	}
+	channelID, secret := os.Getenv("LINE_CHANNEL_ID"), os.Getenv("LINE_CHANNEL_SECRET")
+	if channelID == "" || secret == "" {
+		log.Fatal("LINE_CHANNEL_ID and LINE_CHANNEL_SECRET are required")
+	}
+	log.Printf("fake LINE Login listening on %s for channel %s", addr, channelID)
+	if err := http.ListenAndServe(addr, lineauth.NewFakeServer(channelID, secret)); err != nil {
+		log.Fatal(err)
+	}
+}
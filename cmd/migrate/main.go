@@ -0,0 +1,145 @@
+// Command migrate manages the versioned schema migrations in internal/db/migrations.
+//
+//	go run ./cmd/migrate status        # list migrations and whether they are applied
+//	go run ./cmd/migrate up [N]        # apply all (or the next N) pending migrations
+//	go run ./cmd/migrate down N        # revert the N most recent migrations
+//	go run ./cmd/migrate create <name> # add empty NNNN_<name>.up.sql / .down.sql files
+//
+// The server applies pending migrations on boot as well; migrations are embedded in the
+// binary, so files added with create need a rebuild before up sees them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/db"
+)
+
+var nameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate [-timeout 5m] [-dir internal/db/migrations] status | up [N] | down N | create <name>")
+	os.Exit(2)
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 5*time.Minute, "Overall timeout (includes waiting for the migration lock)")
+	dir := flag.String("dir", db.MigrationsDir, "Migrations directory used by create")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 || !nameRe.MatchString(args[1]) {
+			log.Fatalf("create needs a snake_case name, e.g. create add_places_owner")
+		}
+		if err := create(*dir, args[1]); err != nil {
+			log.Fatalf("create: %v", err)
+		}
+		return
+	}
+
+	cfg := config.Load()
+	pool, err := db.Connect(cfg)
+	if err != nil {
+		log.Fatalf("db connect: %v", err)
+	}
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch args[0] {
+	case "status":
+		statuses, err := db.MigrationStatuses(ctx, pool)
+		if err != nil {
+			log.Fatalf("status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Local().Format(time.DateTime)
+			}
+			if s.Modified {
+				state += " (file changed since applied)"
+			}
+			if s.Missing {
+				state += " (not in this binary)"
+			}
+			fmt.Printf("%04d %-40s %s\n", s.Version, s.Name, state)
+		}
+	case "up":
+		n := 0
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n <= 0 {
+				log.Fatalf("up: N must be a positive number")
+			}
+		}
+		applied, err := db.MigrateUp(ctx, pool, n)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("up: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+	case "down":
+		if len(args) != 2 {
+			usage()
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			log.Fatalf("down: N must be a positive number")
+		}
+		reverted, err := db.MigrateDown(ctx, pool, n)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("down: %v", err)
+		}
+	default:
+		usage()
+	}
+}
+
+// create writes the next-numbered, empty up/down pair into dir.
+func create(dir, name string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var next int64 = 1
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseInt(prefix, 10, 64); err == nil && v >= next {
+			next = v + 1
+		}
+	}
+	base := fmt.Sprintf("%04d_%s", next, name)
+	for _, suffix := range []string{".up.sql", ".down.sql"} {
+		path := filepath.Join(dir, base+suffix)
+		body := "-- " + base + suffix + "\n"
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			return err
+		}
+		fmt.Println("created", path)
+	}
+	return nil
+}
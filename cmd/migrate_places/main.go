@@ -0,0 +1,330 @@
+// Command migrate_places folds the legacy facility tables (shelters, medical_stations, ...) into places.
+//
+// Each legacy row becomes one places row (see internal/legacy for the column mapping) and the
+// legacy id -> place id pair is recorded in legacy_place_map together with a hash of the source
+// row. Re-running is safe: unchanged rows are skipped, changed rows update their place, new rows
+// are inserted. With -dry nothing is written and a diff report is printed instead.
+//
+//	go run ./cmd/migrate_places -dry
+//	go run ./cmd/migrate_places -tables shelters,restrooms
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/config"
+	"guangfu250923/internal/db"
+	"guangfu250923/internal/legacy"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type mapping struct {
+	placeID string
+	hash    string
+}
+
+type stats struct {
+	created, updated, unchanged, orphaned int
+}
+
+func main() {
+	dryRun := flag.Bool("dry", false, "Dry run: print a diff report, do not write")
+	tablesFlag := flag.String("tables", "", "Comma separated legacy tables to migrate (default: all)")
+	verbose := flag.Bool("v", false, "Print every created/updated row (always on with -dry)")
+	flag.Parse()
+
+	tables := legacy.Tables
+	if *tablesFlag != "" {
+		tables = nil
+		for _, name := range strings.Split(*tablesFlag, ",") {
+			t, ok := legacy.Lookup(strings.TrimSpace(name))
+			if !ok {
+				log.Fatalf("unknown legacy table %q", name)
+			}
+			tables = append(tables, t)
+		}
+	}
+
+	cfg := config.Load()
+	pool, err := db.Connect(cfg)
+	if err != nil {
+		log.Fatalf("db connect: %v", err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	if !*dryRun {
+		// make sure legacy_place_map and the compatibility views exist
+		if err := db.Migrate(ctx, pool); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+	}
+
+	total := stats{}
+	for _, t := range tables {
+		st, err := migrateTable(ctx, pool, t, *dryRun, *verbose || *dryRun)
+		if err != nil {
+			log.Fatalf("%s: %v", t.Name, err)
+		}
+		log.Printf("%s: created=%d updated=%d unchanged=%d orphaned=%d", t.Name, st.created, st.updated, st.unchanged, st.orphaned)
+		total.created += st.created
+		total.updated += st.updated
+		total.unchanged += st.unchanged
+		total.orphaned += st.orphaned
+	}
+	prefix := "done."
+	if *dryRun {
+		prefix = "[DRY] nothing written."
+	}
+	log.Printf("%s created=%d updated=%d unchanged=%d orphaned=%d", prefix, total.created, total.updated, total.unchanged, total.orphaned)
+}
+
+func migrateTable(ctx context.Context, pool *pgxpool.Pool, t legacy.Table, dryRun, verbose bool) (stats, error) {
+	st := stats{}
+	existing, err := loadMappings(ctx, pool, t.Name)
+	if err != nil {
+		return st, err
+	}
+	// soft-deleted legacy rows count as gone (reported, place kept); deleted_at stays out of the
+	// row so it doesn't change the source hash of everything migrated before it existed
+	rows, err := pool.Query(ctx, "select to_jsonb(t) - 'deleted_at' from "+t.Name+" t where t.deleted_at is null order by created_at, id")
+	if err != nil {
+		return st, err
+	}
+	var legacyRows []map[string]interface{}
+	for rows.Next() {
+		var row map[string]interface{}
+		if err := rows.Scan(&row); err != nil {
+			rows.Close()
+			return st, err
+		}
+		legacyRows = append(legacyRows, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+
+	seen := map[string]bool{}
+	for _, row := range legacyRows {
+		legacyID, _ := row["id"].(string)
+		seen[legacyID] = true
+		p := t.ToPlace(row)
+		hash := legacy.Hash(row)
+		m, ok := existing[legacyID]
+		switch {
+		case !ok:
+			st.created++
+			if verbose {
+				fmt.Printf("+ %s/%s -> new place %q (%s, %s)\n", t.Name, legacyID, p.Name, p.Type, p.Status)
+			}
+			if dryRun {
+				continue
+			}
+			if err := insertPlace(ctx, pool, t.Name, legacyID, hash, p); err != nil {
+				return st, fmt.Errorf("insert %s: %w", legacyID, err)
+			}
+		case m.hash == hash:
+			st.unchanged++
+		default:
+			st.updated++
+			if verbose {
+				changes, err := diffPlace(ctx, pool, m.placeID, p)
+				if err != nil {
+					return st, fmt.Errorf("diff %s: %w", legacyID, err)
+				}
+				fmt.Printf("~ %s/%s -> place %s\n", t.Name, legacyID, m.placeID)
+				for _, line := range changes {
+					fmt.Println("    " + line)
+				}
+			}
+			if dryRun {
+				continue
+			}
+			if err := updatePlace(ctx, pool, t.Name, legacyID, m.placeID, hash, p); err != nil {
+				return st, fmt.Errorf("update %s: %w", legacyID, err)
+			}
+		}
+	}
+	// mapped rows whose legacy row is gone (or soft-deleted): keep the place, just report it
+	for legacyID, m := range existing {
+		if !seen[legacyID] {
+			st.orphaned++
+			if verbose {
+				fmt.Printf("? %s/%s no longer exists or was deleted; place %s kept\n", t.Name, legacyID, m.placeID)
+			}
+		}
+	}
+	return st, nil
+}
+
+func loadMappings(ctx context.Context, pool *pgxpool.Pool, table string) (map[string]mapping, error) {
+	out := map[string]mapping{}
+	// in dry-run the table may not have been created yet
+	var exists bool
+	if err := pool.QueryRow(ctx, `select to_regclass('legacy_place_map') is not null`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return out, nil
+	}
+	rows, err := pool.Query(ctx, `select legacy_id, place_id, source_hash from legacy_place_map where legacy_table=$1`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var m mapping
+		if err := rows.Scan(&id, &m.placeID, &m.hash); err != nil {
+			return nil, err
+		}
+		out[id] = m
+	}
+	return out, rows.Err()
+}
+
+const placeColumns = "name,address,coordinates,type,sub_type,status,contact_name,contact_phone,website_url,info_sources,notes,resources,additional_info,created_at,updated_at"
+
+// placeArgs returns the values for placeColumns, in order.
+func placeArgs(p legacy.Place) ([]interface{}, error) {
+	coords, err := json.Marshal(p.Coordinates)
+	if err != nil {
+		return nil, err
+	}
+	var resources *string
+	if p.Resources != nil {
+		b, err := json.Marshal(p.Resources)
+		if err != nil {
+			return nil, err
+		}
+		s := string(b)
+		resources = &s
+	}
+	addInfo, err := json.Marshal(p.AdditionalInfo)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{p.Name, p.Address, string(coords), p.Type, p.SubType, p.Status, p.ContactName, p.ContactPhone, p.WebsiteURL, p.InfoSources, p.Notes, resources, string(addInfo), nullTime(p.CreatedAt), nullTime(p.UpdatedAt)}, nil
+}
+
+func insertPlace(ctx context.Context, pool *pgxpool.Pool, table, legacyID, hash string, p legacy.Place) error {
+	args, err := placeArgs(p)
+	if err != nil {
+		return err
+	}
+	newID, _ := uuid.NewV7()
+	placeID := newID.String()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, `insert into places(id,`+placeColumns+`)
+        values($1,$2,$3,$4::jsonb,$5,$6,$7,$8,$9,$10,$11::text[],$12,$13::jsonb,$14::jsonb,coalesce($15::timestamptz,now()),coalesce($16::timestamptz,now()))`,
+		append([]interface{}{placeID}, args...)...)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `insert into legacy_place_map(legacy_table,legacy_id,place_id,source_hash) values($1,$2,$3,$4)`, table, legacyID, placeID, hash)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func updatePlace(ctx context.Context, pool *pgxpool.Pool, table, legacyID, placeID, hash string, p legacy.Place) error {
+	args, err := placeArgs(p)
+	if err != nil {
+		return err
+	}
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	// created_at is left alone; updated_at follows the legacy row
+	_, err = tx.Exec(ctx, `update places set name=$2,address=$3,coordinates=$4::jsonb,type=$5,sub_type=$6,status=$7,contact_name=$8,contact_phone=$9,
+        website_url=$10,info_sources=$11::text[],notes=$12,resources=$13::jsonb,additional_info=$14::jsonb,updated_at=coalesce($15::timestamptz,now()) where id=$1`,
+		append(append([]interface{}{placeID}, args[:13]...), args[14])...)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `update legacy_place_map set source_hash=$3, migrated_at=now() where legacy_table=$1 and legacy_id=$2`, table, legacyID, hash)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// diffPlace lists "column: old -> new" lines between the current place and its re-mapped row.
+func diffPlace(ctx context.Context, pool *pgxpool.Pool, placeID string, p legacy.Place) ([]string, error) {
+	var current map[string]interface{}
+	err := pool.QueryRow(ctx, `select to_jsonb(p) from places p where id=$1`, placeID).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []string{"(place missing, will fail; delete the legacy_place_map row to re-create it)"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	next := p.Fields()
+	keys := make([]string, 0, len(next))
+	for k := range next {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out []string
+	for _, k := range keys {
+		if !reflect.DeepEqual(normalize(current[k]), normalize(next[k])) {
+			out = append(out, fmt.Sprintf("%s: %s -> %s", k, compact(current[k]), compact(next[k])))
+		}
+	}
+	return out, nil
+}
+
+// normalize treats the empty values places stores by default (empty string / null / []) as equal.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		if x == "" {
+			return nil
+		}
+	case []interface{}:
+		if len(x) == 0 {
+			return nil
+		}
+	}
+	return v
+}
+
+func compact(v interface{}) string {
+	b, _ := json.Marshal(v)
+	s := string(b)
+	if len(s) > 120 {
+		s = s[:117] + "..."
+	}
+	return s
+}
+
+func nullTime(s string) *string {
+	if s == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+		// to_jsonb(timestamptz) is ISO 8601; leave anything else to the database default
+		return nil
+	}
+	return &s
+}
@@ -15,6 +15,8 @@ import (
 	"guangfu250923/internal/db"
 	"guangfu250923/internal/handlers"
 	"guangfu250923/internal/middleware"
+	"guangfu250923/internal/pin"
+	"guangfu250923/internal/policy"
 	"guangfu250923/internal/sheetcache"
 
 	"github.com/gin-contrib/cors"
@@ -33,11 +35,55 @@ func main() {
 
 	slog.Info("database connected", "cfg", cfg.DBHost+":"+cfg.DBPort+"/"+cfg.DBName)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Apply pending versioned migrations; the timeout also covers waiting for another
+	// instance that holds the migration lock.
+	migrateTimeout, _ := strconv.Atoi(os.Getenv("MIGRATE_TIMEOUT_SEC"))
+	if migrateTimeout <= 0 {
+		migrateTimeout = 120
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(migrateTimeout)*time.Second)
 	defer cancel()
 	if err := db.Migrate(ctx, pool); err != nil {
 		log.Fatalf("migration failed: %v", err)
 	}
+	// PINs stored before hashing was introduced are hashed in place, in the background
+	pinCtx, cancelPin := context.WithCancel(context.Background())
+	defer cancelPin()
+	pin.StartHashLegacy(pinCtx, pool)
+
+	// Soft-deleted rows are purged for good after SOFT_DELETE_RETENTION_DAYS (default 30, 0 disables)
+	retentionDays := 30
+	if v, err := strconv.Atoi(os.Getenv("SOFT_DELETE_RETENTION_DAYS")); err == nil {
+		retentionDays = v
+	}
+	purgeCtx, cancelPurge := context.WithCancel(context.Background())
+	defer cancelPurge()
+	if retentionDays > 0 {
+		db.StartPurgeLoop(purgeCtx, pool, time.Duration(retentionDays)*24*time.Hour, time.Hour)
+	}
+	// GET /stats/needs reads a summary recomputed every NEEDS_SUMMARY_REFRESH_SEC (default 30, 0 disables)
+	needsRefreshSec := 30
+	if v, err := strconv.Atoi(os.Getenv("NEEDS_SUMMARY_REFRESH_SEC")); err == nil {
+		needsRefreshSec = v
+	}
+	statsCtx, cancelStats := context.WithCancel(context.Background())
+	defer cancelStats()
+	if needsRefreshSec > 0 {
+		db.StartNeedsSummaryLoop(statsCtx, pool, time.Duration(needsRefreshSec)*time.Second)
+	}
+	// Occupancy, vacancy and stock are sampled every TIMESERIES_INTERVAL_SEC (default 300, 0 disables)
+	// for /{resource}/:id/timeseries; samples older than TIMESERIES_RETENTION_DAYS (default 90, 0 keeps
+	// them) are purged daily
+	snapshotSec, timeseriesDays := 300, 90
+	if v, err := strconv.Atoi(os.Getenv("TIMESERIES_INTERVAL_SEC")); err == nil {
+		snapshotSec = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("TIMESERIES_RETENTION_DAYS")); err == nil {
+		timeseriesDays = v
+	}
+	if snapshotSec > 0 {
+		db.StartSnapshotLoop(statsCtx, pool, time.Duration(snapshotSec)*time.Second, time.Duration(timeseriesDays)*24*time.Hour)
+	}
 
 	r := gin.Default()
 	// CORS configuration: allow specified front-end origins
@@ -60,8 +106,8 @@ func main() {
 		AllowMethods: []string{"GET", "POST", "PATCH", "OPTIONS"},
 		// Add "User-Agent" to satisfy Safari (it sometimes includes it in Access-Control-Request-Headers)
 		// You may broaden this further or use "*" if you trust clients and want less friction.
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "User-Agent", "X-Api-Key"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "User-Agent", "X-Api-Key", "X-Turnstile-Token", "If-Match", "If-None-Match", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "Idempotent-Replayed", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
 		AllowCredentials: false,
 		MaxAge:           43200 * time.Second, // 12h
 	}))
@@ -80,8 +126,26 @@ func main() {
 	r.Use(middleware.CacheHeaders(0))
 	// Security headers (CSP/etc.)
 	r.Use(middleware.SecurityHeaders())
-	// IP / Country filter for POST/PATCH (uses Cf-Ipcountry header internally + ip_denylist table)
+	// IP / Country filter for POST/PATCH (uses Cf-Ipcountry header internally + ip_denylist table, managed under /_admin/ip_denylist)
 	r.Use(middleware.IPFilter(pool))
+	// Resolve X-Api-Key / Bearer keys (api_keys table + env keys) into the caller's identity and scopes
+	r.Use(middleware.APIKeyAuth(pool))
+	// Resolve Bearer session tokens (issued by POST /auth/line/token) into the signed-in user
+	r.Use(middleware.UserAuth(pool))
+	// Roles of the caller (API key role:<role> scopes, user_roles) for the permissions routes
+	// declare with authz.Require; see internal/policy for who may do what
+	authz := middleware.NewAuthorizer(pool, policy.Default(handlers.HistoryResources(), policy.Options{
+		OpenSupplyProviders: os.Getenv("VERIFY_SUPPLY_PIN") != "true",
+	}))
+	r.Use(authz.Resolve())
+	// Token-bucket limits for write requests, per client IP (or per API key); see rateLimitRules
+	r.Use(middleware.RateLimit(pool, rateLimitRules))
+	// Turnstile on the public create routes listed in TURNSTILE_ROUTES (API key holders skip it)
+	r.Use(middleware.TurnstileRoutes())
+	// One transaction per write request, tagged with the caller for entity_versions
+	r.Use(middleware.RequestTx(pool))
+	// Retried POSTs with the same Idempotency-Key get the first response back instead of running twice
+	r.Use(middleware.Idempotency(pool))
 	r.GET("/healthz", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
 
 	// Swagger UI with custom configuration
@@ -100,134 +164,227 @@ func main() {
 	r.GET("/sheet/snapshot", func(c *gin.Context) { c.JSON(http.StatusOK, sheetCache.Snapshot()) })
 
 	h := handlers.New(pool)
+	// With LEGACY_READ_FROM_PLACES=true the legacy facility endpoints are read-only (405 on writes)
+	r.Use(h.LegacyWriteGuard())
+	// Strong ETags on single resources; PATCH honours If-Match with them (412 on a stale version)
+	versioned := h.Versioning()
 	// LINE Login endpoints
 	r.GET("/auth/line/start", h.StartLineAuth)
 	r.POST("/auth/line/token", h.ExchangeLineToken)
+	r.GET("/auth/me", middleware.RequireUser(), h.GetMe)
+	r.POST("/auth/logout", middleware.RequireUser(), h.Logout)
 	r.POST("/shelters", h.CreateShelter)
 	r.GET("/shelters", h.ListShelters)
-	r.GET("/shelters/:id", h.GetShelter)
-	r.DELETE("/shelters/:id", middleware.ModifyAPIKeyRequired(), h.DeleteShelter)
+	r.GET("/shelters/:id", versioned, h.GetShelter)
+	r.DELETE("/shelters/:id", authz.Require("shelters:delete"), h.DeleteShelter)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/shelters/:id", middleware.ModifyAPIKeyRequired(), h.PatchShelter)
+	r.PATCH("/shelters/:id", authz.Require("shelters:update"), versioned, h.PatchShelter)
 	r.POST("/medical_stations", h.CreateMedicalStation)
 	r.GET("/medical_stations", h.ListMedicalStations)
-	r.GET("/medical_stations/:id", h.GetMedicalStation)
-	r.DELETE("/medical_stations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteMedicalStation)
+	r.GET("/medical_stations/:id", versioned, h.GetMedicalStation)
+	r.DELETE("/medical_stations/:id", authz.Require("medical_stations:delete"), h.DeleteMedicalStation)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/medical_stations/:id", middleware.ModifyAPIKeyRequired(), h.PatchMedicalStation)
+	r.PATCH("/medical_stations/:id", authz.Require("medical_stations:update"), versioned, h.PatchMedicalStation)
 	r.POST("/mental_health_resources", h.CreateMentalHealthResource)
 	r.GET("/mental_health_resources", h.ListMentalHealthResources)
-	r.GET("/mental_health_resources/:id", h.GetMentalHealthResource)
-	r.DELETE("/mental_health_resources/:id", middleware.ModifyAPIKeyRequired(), h.DeleteMentalHealthResource)
+	r.GET("/mental_health_resources/:id", versioned, h.GetMentalHealthResource)
+	r.DELETE("/mental_health_resources/:id", authz.Require("mental_health_resources:delete"), h.DeleteMentalHealthResource)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/mental_health_resources/:id", middleware.ModifyAPIKeyRequired(), h.PatchMentalHealthResource)
+	r.PATCH("/mental_health_resources/:id", authz.Require("mental_health_resources:update"), versioned, h.PatchMentalHealthResource)
 	r.POST("/accommodations", h.CreateAccommodation)
 	r.GET("/accommodations", h.ListAccommodations)
-	r.GET("/accommodations/:id", h.GetAccommodation)
-	r.DELETE("/accommodations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteAccommodation)
+	r.GET("/accommodations/:id", versioned, h.GetAccommodation)
+	r.DELETE("/accommodations/:id", authz.Require("accommodations:delete"), h.DeleteAccommodation)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/accommodations/:id", middleware.ModifyAPIKeyRequired(), h.PatchAccommodation)
+	r.PATCH("/accommodations/:id", authz.Require("accommodations:update"), versioned, h.PatchAccommodation)
 	r.POST("/shower_stations", h.CreateShowerStation)
 	r.GET("/shower_stations", h.ListShowerStations)
-	r.GET("/shower_stations/:id", h.GetShowerStation)
-	r.DELETE("/shower_stations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteShowerStation)
+	r.GET("/shower_stations/:id", versioned, h.GetShowerStation)
+	r.DELETE("/shower_stations/:id", authz.Require("shower_stations:delete"), h.DeleteShowerStation)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/shower_stations/:id", middleware.ModifyAPIKeyRequired(), h.PatchShowerStation)
+	r.PATCH("/shower_stations/:id", authz.Require("shower_stations:update"), versioned, h.PatchShowerStation)
 
 	// Water refill stations
 	r.POST("/water_refill_stations", h.CreateWaterRefillStation)
 	r.GET("/water_refill_stations", h.ListWaterRefillStations)
-	r.GET("/water_refill_stations/:id", h.GetWaterRefillStation)
-	r.DELETE("/water_refill_stations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteWaterRefillStation)
+	r.GET("/water_refill_stations/:id", versioned, h.GetWaterRefillStation)
+	r.DELETE("/water_refill_stations/:id", authz.Require("water_refill_stations:delete"), h.DeleteWaterRefillStation)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/water_refill_stations/:id", middleware.ModifyAPIKeyRequired(), h.PatchWaterRefillStation)
+	r.PATCH("/water_refill_stations/:id", authz.Require("water_refill_stations:update"), versioned, h.PatchWaterRefillStation)
 	// Restrooms
 	r.POST("/restrooms", h.CreateRestroom)
 	r.GET("/restrooms", h.ListRestrooms)
-	r.GET("/restrooms/:id", h.GetRestroom)
-	r.DELETE("/restrooms/:id", middleware.ModifyAPIKeyRequired(), h.DeleteRestroom)
+	r.GET("/restrooms/:id", versioned, h.GetRestroom)
+	r.DELETE("/restrooms/:id", authz.Require("restrooms:delete"), h.DeleteRestroom)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/restrooms/:id", h.PatchRestroom)
+	r.PATCH("/restrooms/:id", authz.Require("restrooms:update"), versioned, h.PatchRestroom)
 	r.POST("/volunteer_organizations", h.CreateVolunteerOrg)
 	r.GET("/volunteer_organizations", h.ListVolunteerOrgs)
-	r.GET("/volunteer_organizations/:id", h.GetVolunteerOrg)
-	r.DELETE("/volunteer_organizations/:id", middleware.ModifyAPIKeyRequired(), h.DeleteVolunteerOrg)
+	r.GET("/volunteer_organizations/:id", versioned, h.GetVolunteerOrg)
+	r.DELETE("/volunteer_organizations/:id", authz.Require("volunteer_organizations:delete"), h.DeleteVolunteerOrg)
 	// 2025-10-06 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/volunteer_organizations/:id", middleware.ModifyAPIKeyRequired(), h.PatchVolunteerOrg)
+	r.PATCH("/volunteer_organizations/:id", authz.Require("volunteer_organizations:update"), versioned, h.PatchVolunteerOrg)
 	// Human resources
 	r.GET("/human_resources", h.ListHumanResources)
-	r.GET("/human_resources/:id", h.GetHumanResource)
+	r.GET("/human_resources/:id", versioned, h.GetHumanResource)
 	r.POST("/human_resources", h.CreateHumanResource)
-	r.DELETE("/human_resources/:id", middleware.ModifyAPIKeyRequired(), h.DeleteHumanResource)
+	r.DELETE("/human_resources/:id", authz.Require("human_resources:delete"), h.DeleteHumanResource)
 	// 2025-10-06 因為需要用這個 api 進行到位人數確認，所以是唯一開放的 PATCH api
 	// 2025-10-08 驗證 API Key：在 handler 內部判斷是否僅更新 status/is_completed/headcount_got，若非僅更新這三者才要求 API Key
-	r.PATCH("/human_resources/:id", h.PatchHumanResource)
+	// status/is_completed/headcount_got 由 policy 開放；其他欄位：有權限的角色 / API Key、登入的建立者 / 共同管理者，或（VERIFY_HR_PIN=true 時）正確的 PIN
+	r.PATCH("/human_resources/:id", authz.Require("human_resources:update"), versioned, h.PatchHumanResource)
+	r.POST("/human_resources/:id/reset_pin", h.ResetPin)
 	// Supplies (new domain) & supply items (renamed from suppily)
 	r.POST("/supplies", h.CreateSupply)
 	r.GET("/supplies", h.ListSupplies)
-	r.GET("/supplies/:id", h.GetSupply)
-	r.DELETE("/supplies/:id", middleware.ModifyAPIKeyRequired(), h.DeleteSupply)
+	r.GET("/supplies/:id", versioned, h.GetSupply)
+	r.DELETE("/supplies/:id", authz.Require("supplies:delete"), h.DeleteSupply)
 	// 2025-10-01 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/supplies/:id", middleware.ModifyAPIKeyRequired(), h.PatchSupply)
+	// 登入 (LINE) 的建立者 / 共同管理者也可以編輯自己的供應單，VERIFY_SUPPLY_PIN=true 時也可用 PIN (handler 內判斷)
+	r.PATCH("/supplies/:id", authz.Require("supplies:update"), versioned, h.PatchSupply)
+	r.POST("/supplies/:id/reset_pin", h.ResetPin)
 	r.POST("/supplies/:id", h.DistributeSupplyItems) // 批次配送 (累加 recieved_count)
 	r.POST("/supply_items", h.CreateSupplyItem)
 	r.GET("/supply_items", h.ListSupplyItems)
-	r.GET("/supply_items/:id", h.GetSupplyItem)
-	r.DELETE("/supply_items/:id", middleware.ModifyAPIKeyRequired(), h.DeleteSupplyItem)
+	r.GET("/supply_items/:id", versioned, h.GetSupplyItem)
+	r.DELETE("/supply_items/:id", authz.Require("supply_items:delete"), h.DeleteSupplyItem)
 	// 2025-10-01 要求先關起來
 	// 2025-10-08 打開來，但是要求驗證 API Key， 提供第三方進行資料同步
-	r.PATCH("/supply_items/:id", middleware.ModifyAPIKeyRequired(), h.PatchSupplyItem)
+	// 與所屬供應單相同：API Key、建立者 / 共同管理者或供應單的 PIN (handler 內判斷)
+	r.PATCH("/supply_items/:id", authz.Require("supply_items:update"), versioned, h.PatchSupplyItem)
+	// Delivery ledger: every delivery (POST /supplies/:id) and received_count change, with reversals
+	r.GET("/supply_items/:id/deliveries", h.ListSupplyDeliveries)
+	r.POST("/supply_items/:id/deliveries/:delivery_id/reverse", authz.Require("supply_items:update"), h.ReverseSupplyDelivery)
+	// Unit catalog quantities are normalized with (read-only; edited under /_admin/units)
+	r.GET("/units", h.ListUnits)
+	// Item catalog supply tags and names are mapped to (read-only; edited under /_admin/catalog)
+	r.GET("/catalog/categories", h.ListCatalogCategories)
+	r.GET("/catalog/items", h.ListCatalogItems)
+	r.GET("/catalog/items/:id", h.GetCatalogItem)
+	r.GET("/catalog/suggest", h.SuggestCatalog) // closest categories / items for free text, e.g. while typing a tag
+	// Dashboard: what is still missing, grouped by item / category / place / township / status (JSON or CSV)
+	r.GET("/stats/needs", h.NeedsStats)
+	// Admin endpoints: the group needs some admin:<area> permission (the admin role or scope, or a
+	// moderator for request logs, the IP denylist, units and the catalog) and each route its own
+	admin := r.Group("/_admin", authz.AdminAuth())
 	// Admin: request logs
-	r.GET("/_admin/request_logs", h.ListRequestLogs)
+	admin.GET("/request_logs", authz.Require("admin:request_logs"), h.ListRequestLogs)
+	admin.POST("/request_logs/:id/block", authz.Require("admin:ip_denylist"), h.BlockRequestLogIP) // deny the IP the logged request came from
+	// Admin: IP denylist used by IPFilter (changes apply immediately)
+	admin.GET("/ip_denylist", authz.Require("admin:ip_denylist"), h.ListIPDenylist)
+	admin.POST("/ip_denylist", authz.Require("admin:ip_denylist"), h.CreateIPDenyEntry)
+	admin.DELETE("/ip_denylist/:id", authz.Require("admin:ip_denylist"), h.DeleteIPDenyEntry)
+	// Admin: partner API keys (the plain key is only returned on create / rotate)
+	apiKeys := admin.Group("/api_keys", authz.Require("admin:api_keys"))
+	apiKeys.GET("", h.ListAPIKeys)
+	apiKeys.POST("", h.CreateAPIKey)
+	apiKeys.GET("/:id", h.GetAPIKey)
+	apiKeys.PATCH("/:id", h.PatchAPIKey)
+	apiKeys.POST("/:id/rotate", h.RotateAPIKey)
+	apiKeys.POST("/:id/revoke", h.RevokeAPIKey)
+	// Admin: captcha verification counters (passed / failed / errors / cache hits)
+	admin.GET("/captcha_metrics", authz.Require("admin:captcha_metrics"), h.CaptchaMetrics)
+	// Admin: user roles (viewer, site_coordinator per place, moderator, partner_sync, admin) and the
+	// policy they map to
+	admin.GET("/users", authz.Require("admin:users"), h.ListUsers)
+	admin.POST("/users/:id/roles", authz.Require("admin:users"), h.AddUserRole)
+	admin.DELETE("/users/:id/roles/:role_id", authz.Require("admin:users"), h.RemoveUserRole)
+	admin.GET("/policy", authz.Require("admin:users"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"roles": policy.Roles(), "permissions": authz.Policy().Describe()})
+	})
+	// Admin: unit catalog and per-item conversions; units in use but not in the catalog
+	admin.GET("/units/review", authz.Require("admin:units"), h.ListUnitReview)
+	admin.POST("/units", authz.Require("admin:units"), h.PutUnit)
+	admin.DELETE("/units/:unit", authz.Require("admin:units"), h.DeleteUnit)
+	admin.POST("/unit_conversions", authz.Require("admin:units"), h.PutUnitConversion)
+	admin.DELETE("/unit_conversions/:item/:from_unit", authz.Require("admin:units"), h.DeleteUnitConversion)
+	// Admin: item catalog (categories are upserted by slug; a category still holding items can't be deleted)
+	catalog := admin.Group("/catalog", authz.Require("admin:catalog"))
+	catalog.POST("/categories", h.PutCatalogCategory)
+	catalog.DELETE("/categories/:id", h.DeleteCatalogCategory)
+	catalog.POST("/items", h.CreateCatalogItem)
+	catalog.PATCH("/items/:id", h.PatchCatalogItem)
+	catalog.DELETE("/items/:id", h.DeleteCatalogItem)
 
 	// Reports (incidents)
 	r.POST("/reports", h.CreateReport)
 	r.GET("/reports", h.ListReports)
-	r.GET("/reports/:id", h.GetReport)
-	r.PATCH("/reports/:id", h.PatchReport)
+	r.GET("/reports/:id", versioned, h.GetReport)
+	r.PATCH("/reports/:id", authz.Require("reports:update"), versioned, h.PatchReport)
 
 	// Spam detection results
-	spamResultAPIKey := os.Getenv("SPAM_RESULT_API_KEY")
-	r.POST("/spam_results", middleware.APIKeyVerifier(spamResultAPIKey), h.CreateSpamResult)
+	r.POST("/spam_results", authz.Require("spam_results:create"), h.CreateSpamResult)
 	r.GET("/spam_results", h.ListSpamResults)
-	r.GET("/spam_results/:id", h.GetSpamResult)
-	r.PATCH("/spam_results/:id", middleware.APIKeyVerifier(spamResultAPIKey), h.PatchSpamResult)
+	r.GET("/spam_results/:id", versioned, h.GetSpamResult)
+	r.PATCH("/spam_results/:id", authz.Require("spam_results:update"), versioned, h.PatchSpamResult)
 
 	// Supply item providers
 	r.POST("/supply_providers", h.CreateSupplyProvider)
 	r.GET("/supply_providers", h.ListSupplyProviders)
-	r.GET("/supply_providers/:id", h.GetSupplyProvider)
-	r.PATCH("/supply_providers/:id", h.PatchSupplyProvider)
+	r.GET("/supply_providers/:id", versioned, h.GetSupplyProvider)
+	r.PATCH("/supply_providers/:id", authz.Require("supply_providers:update"), versioned, h.PatchSupplyProvider)
 
 	// Places
 	r.POST("/places", h.CreatePlace)
 	r.GET("/places", h.ListPlaces)
-	r.GET("/places/:id", h.GetPlace)
-	r.DELETE("/places/:id", middleware.ModifyAPIKeyRequired(), h.DeletePlace)
-	r.PATCH("/places/:id", middleware.ModifyAPIKeyRequired(), h.PatchPlace)
+	r.GET("/places/:id", versioned, h.GetPlace)
+	r.DELETE("/places/:id", authz.Require("places:delete"), h.DeletePlace)
+	// site coordinators of the place may update its operational fields (status, hours, contact...),
+	// not what or where it is; the signed-in owner / co-owners anything (checked in the handler)
+	r.PATCH("/places/:id", authz.Require("places:update"), versioned, h.PatchPlace)
+
+	// Map: all location-bearing resources in one normalized list
+	r.GET("/map/features", h.ListMapFeatures)
 
 	// Requirements HR
 	r.POST("/requirements_hr", h.CreateRequirementsHR)
 	r.GET("/requirements_hr", h.ListRequirementsHR)
-	r.GET("/requirements_hr/:id", h.GetRequirementsHR)
-	r.DELETE("/requirements_hr/:id", middleware.ModifyAPIKeyRequired(), h.DeleteRequirementsHR)
-	r.PATCH("/requirements_hr/:id", middleware.ModifyAPIKeyRequired(), h.PatchRequirementsHR)
+	r.GET("/requirements_hr/:id", versioned, h.GetRequirementsHR)
+	r.DELETE("/requirements_hr/:id", authz.Require("requirements_hr:delete"), h.DeleteRequirementsHR)
+	r.PATCH("/requirements_hr/:id", authz.Require("requirements_hr:update"), versioned, h.PatchRequirementsHR)
 
 	// Requirements Supplies
 	r.POST("/requirements_supplies", h.CreateRequirementsSupplies)
 	r.GET("/requirements_supplies", h.ListRequirementsSupplies)
-	r.GET("/requirements_supplies/:id", h.GetRequirementsSupplies)
-	r.DELETE("/requirements_supplies/:id", middleware.ModifyAPIKeyRequired(), h.DeleteRequirementsSupplies)
-	r.PATCH("/requirements_supplies/:id", middleware.ModifyAPIKeyRequired(), h.PatchRequirementsSupplies)
+	r.GET("/requirements_supplies/:id", versioned, h.GetRequirementsSupplies)
+	r.DELETE("/requirements_supplies/:id", authz.Require("requirements_supplies:delete"), h.DeleteRequirementsSupplies)
+	r.PATCH("/requirements_supplies/:id", authz.Require("requirements_supplies:update"), versioned, h.PatchRequirementsSupplies)
+	r.GET("/requirements_supplies/:id/matches", h.ListRequirementMatches)
+
+	// Allocations: coordinators accept or reject the pledges matched with a requirement and track
+	// the accepted ones until they arrive
+	r.POST("/allocations", authz.Require("allocations:create"), h.CreateAllocation)
+	r.GET("/allocations", h.ListAllocations)
+	r.GET("/allocations/:id", h.GetAllocation)
+	r.PATCH("/allocations/:id", authz.Require("allocations:update"), h.PatchAllocation)
+
+	// Entity history (entity_versions), admin revert and restore of soft-deleted rows, for every resource above
+	for _, res := range handlers.HistoryResources() {
+		r.GET("/"+res+"/:id/history", h.GetEntityHistory)
+		r.POST("/"+res+"/:id/revert", authz.Require(res+":revert"), h.RevertEntity)
+		r.POST("/"+res+"/:id/restore", authz.Require(res+":revert"), h.RestoreEntity)
+	}
+
+	// Trend charts: periodic samples of values overwritten in place (shelter occupancy, vacancy, received counts)
+	for _, res := range handlers.TimeseriesResources() {
+		r.GET("/"+res+"/:id/timeseries", h.GetTimeseries)
+	}
+
+	// Co-owners: other signed-in users the owner lets edit the record (owner or <resource>:write key only)
+	for _, res := range handlers.CoOwnerResources() {
+		r.GET("/"+res+"/:id/co_owners", h.ListCoOwners)
+		r.POST("/"+res+"/:id/co_owners", h.AddCoOwner)
+		r.DELETE("/"+res+"/:id/co_owners/:user_id", h.RemoveCoOwner)
+	}
 
 	// Turnstile test endpoint (POST only): echo JSON payload for frontend debugging
 	r.POST("/__test_turnstile", middleware.TurnstileVerifier(), func(c *gin.Context) {
@@ -245,3 +402,19 @@ func main() {
 		log.Fatalf("server error: %v", err)
 	}
 }
+
+// rateLimitRules are the write limits, first match wins. Anonymous creates are what spam floods
+// use, so they get the tightest buckets; partners with an API key sync in bulk and get their own.
+var rateLimitRules = []middleware.RateLimitRule{
+	{Method: http.MethodPost, Path: "/supplies", PerMinute: 5, Burst: 10, KeyPerMinute: 300, KeyBurst: 100},
+	{Method: http.MethodPost, Path: "/supplies/:id", PerMinute: 20, Burst: 20, KeyPerMinute: 300, KeyBurst: 100},
+	{Method: http.MethodPost, Path: "/supply_items", PerMinute: 30, Burst: 30, KeyPerMinute: 600, KeyBurst: 200},
+	{Method: http.MethodPost, Path: "/human_resources", PerMinute: 5, Burst: 10, KeyPerMinute: 300, KeyBurst: 100},
+	{Method: http.MethodPost, Path: "/reports", PerMinute: 5, Burst: 10, KeyPerMinute: 300, KeyBurst: 100},
+	{Method: http.MethodPost, Path: "/supply_providers", PerMinute: 5, Burst: 10, KeyPerMinute: 300, KeyBurst: 100},
+	{Method: http.MethodPost, Path: "/places", PerMinute: 5, Burst: 10, KeyPerMinute: 300, KeyBurst: 100},
+	{Method: http.MethodPost, Path: "/requirements_hr", PerMinute: 10, Burst: 20, KeyPerMinute: 300, KeyBurst: 100},
+	{Method: http.MethodPost, Path: "/requirements_supplies", PerMinute: 10, Burst: 20, KeyPerMinute: 300, KeyBurst: 100},
+	// every other write (other creates, PATCH, DELETE, admin); API keys unlimited
+	{Method: "*", PerMinute: 30, Burst: 30},
+}
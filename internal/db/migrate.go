@@ -2,428 +2,290 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
 
+	"guangfu250923/internal/legacy"
+
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// Simple idempotent migrations.
+// Migrations live in migrations/NNNN_name.up.sql (+ optional NNNN_name.down.sql) and are
+// compiled into the binary. Applied versions are recorded in schema_migrations.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// MigrationsDir is where cmd/migrate create writes new files (relative to the repo root).
+const MigrationsDir = "internal/db/migrations"
+
+// migrationLockKey is the pg_advisory_lock key serializing migrations across instances.
+const migrationLockKey int64 = 250923
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up
+}
+
+// MigrationStatus pairs a known or applied migration with its state in schema_migrations.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	// Modified is set when the applied checksum differs from the embedded file.
+	Modified bool
+	// Missing is set for versions recorded in the database but unknown to this binary.
+	Missing bool
+}
+
+// LoadMigrations returns the embedded migrations ordered by version.
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		m := migrationFileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: bad file name (want NNNN_name.up.sql / NNNN_name.down.sql)", e.Name())
+		}
+		v, _ := strconv.ParseInt(m[1], 10, 64)
+		b, err := migrationFiles.ReadFile("migrations/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[v]
+		if !ok {
+			mig = &Migration{Version: v, Name: m[2]}
+			byVersion[v] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d: conflicting names %s and %s", v, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+			sum := sha256.Sum256(b)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(b)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing .up.sql", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Migrate applies every pending migration. It is what the server runs on boot.
 func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
-	stmts := []string{
-		`create table if not exists volunteer_organizations (
-            id text primary key default gen_random_uuid()::text,
-            last_updated timestamptz,
-            registration_status text,
-            organization_nature text,
-            organization_name text,
-            coordinator text,
-            contact_info text,
-            registration_method text,
-            service_content text,
-            meeting_info text,
-            notes text,
-            image_url text
-        )`,
-		`create index if not exists idx_vol_org_updated on volunteer_organizations(last_updated)`,
-		`create table if not exists shelters (
-            id text primary key default gen_random_uuid()::text,
-            name text not null,
-            location text not null,
-            phone text not null,
-            link text,
-            status text not null,
-            capacity int,
-            current_occupancy int,
-            available_spaces int,
-            facilities text[],
-            contact_person text,
-            notes text,
-            opening_hours text,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_shelters_status on shelters(status)`,
-		`alter table if exists shelters add column if not exists coordinates jsonb`,
-		`create table if not exists medical_stations (
-            id text primary key default gen_random_uuid()::text,
-            station_type text not null,
-            name text not null,
-            location text not null,
-            detailed_address text,
-            phone text,
-            contact_person text,
-            status text not null,
-            services text[],
-            equipment text[],
-            operating_hours text,
-            medical_staff int,
-            daily_capacity int,
-            affiliated_organization text,
-            notes text,
-            link text,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_medical_stations_status on medical_stations(status)`,
-		`create index if not exists idx_medical_stations_station_type on medical_stations(station_type)`,
-		`alter table if exists medical_stations add column if not exists coordinates jsonb`,
-		`create table if not exists mental_health_resources (
-            id text primary key default gen_random_uuid()::text,
-            duration_type text not null,
-            name text not null,
-            service_format text not null,
-            service_hours text not null,
-            contact_info text not null,
-            website_url text,
-            target_audience text[],
-            specialties text[],
-            languages text[],
-            is_free boolean not null,
-            location text,
-            status text not null,
-            capacity int,
-            waiting_time text,
-            notes text,
-            emergency_support boolean not null,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_mh_resources_status on mental_health_resources(status)`,
-		`create index if not exists idx_mh_resources_duration_type on mental_health_resources(duration_type)`,
-		`alter table if exists mental_health_resources add column if not exists coordinates jsonb`,
-		`create table if not exists accommodations (
-            id text primary key default gen_random_uuid()::text,
-            township text not null,
-            name text not null,
-            has_vacancy text not null,
-            available_period text not null,
-            restrictions text,
-            contact_info text not null,
-            room_info text,
-            address text not null,
-            pricing text not null,
-            info_source text,
-            notes text,
-            capacity int,
-            status text not null,
-            registration_method text,
-            facilities text[],
-            distance_to_disaster_area text,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_accommodations_status on accommodations(status)`,
-		`create index if not exists idx_accommodations_township on accommodations(township)`,
-		`create index if not exists idx_accommodations_has_vacancy on accommodations(has_vacancy)`,
-		`alter table if exists accommodations add column if not exists coordinates jsonb`,
-		`create table if not exists shower_stations (
-            id text primary key default gen_random_uuid()::text,
-            name text not null,
-            address text not null,
-            phone text,
-            facility_type text not null,
-            time_slots text not null,
-            gender_schedule jsonb,
-            available_period text not null,
-            capacity int,
-            is_free boolean not null,
-            pricing text,
-            notes text,
-            info_source text,
-            status text not null,
-            facilities text[],
-            distance_to_guangfu text,
-            requires_appointment boolean not null,
-            contact_method text,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_shower_stations_status on shower_stations(status)`,
-		`create index if not exists idx_shower_stations_facility_type on shower_stations(facility_type)`,
-		`create index if not exists idx_shower_stations_is_free on shower_stations(is_free)`,
-		`create index if not exists idx_shower_stations_requires_appointment on shower_stations(requires_appointment)`,
-		`alter table if exists shower_stations add column if not exists coordinates jsonb`,
-		`create table if not exists water_refill_stations (
-            id text primary key default gen_random_uuid()::text,
-            name text not null,
-            address text not null,
-            phone text,
-            water_type text not null,
-            opening_hours text not null,
-            is_free boolean not null,
-            container_required text,
-            daily_capacity int,
-            status text not null,
-            water_quality text,
-            facilities text[],
-            accessibility boolean not null,
-            distance_to_disaster_area text,
-            notes text,
-            info_source text,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_water_refill_status on water_refill_stations(status)`,
-		`create index if not exists idx_water_refill_water_type on water_refill_stations(water_type)`,
-		`create index if not exists idx_water_refill_is_free on water_refill_stations(is_free)`,
-		`create index if not exists idx_water_refill_accessibility on water_refill_stations(accessibility)`,
-		`alter table if exists water_refill_stations add column if not exists coordinates jsonb`,
-		`create table if not exists restrooms (
-            id text primary key default gen_random_uuid()::text,
-            name text not null,
-            address text not null,
-            phone text,
-            facility_type text not null,
-            opening_hours text not null,
-            is_free boolean not null,
-            male_units int,
-            female_units int,
-            unisex_units int,
-            accessible_units int,
-            has_water boolean not null,
-            has_lighting boolean not null,
-            status text not null,
-            cleanliness text,
-            last_cleaned timestamptz,
-            facilities text[],
-            distance_to_disaster_area text,
-            notes text,
-            info_source text,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create table if not exists human_resources (
-            id text primary key,
-            org text not null,
-            address text not null,
-            phone text,
-            status text not null,
-            is_completed boolean not null,
-            has_medical boolean,
-        pii_date bigint,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now(),
-            role_name text not null,
-            role_type text not null,
-            skills text[],
-            certifications text[],
-            experience_level text,
-            language_requirements text[],
-            headcount_need int not null,
-            headcount_got int not null,
-            headcount_unit text,
-            role_status text not null,
-            shift_start_ts timestamptz,
-            shift_end_ts timestamptz,
-            shift_notes text,
-            assignment_timestamp timestamptz,
-            assignment_count int,
-            assignment_notes text,
-            total_roles_in_request int,
-            completed_roles_in_request int,
-            pending_roles_in_request int,
-            total_requests int,
-            active_requests int,
-            completed_requests int,
-            cancelled_requests int,
-            total_roles int,
-            completed_roles int,
-            pending_roles int,
-            urgent_requests int,
-            medical_requests int
-        )`,
-		// Add valid_pin to human_resources for edit verification (6-digit pin). Keep nullable for backward compatibility; app enforces on create/patch.
-		`alter table if exists human_resources add column if not exists valid_pin text`,
-		// Relax NOT NULL if previously set
-		`do $$ begin
-        perform 1 from information_schema.columns where table_name='human_resources' and column_name='phone' and is_nullable='NO';
-        if found then
-          alter table human_resources alter column phone drop not null;
-        end if;
-      end $$;`,
-		`create index if not exists idx_human_resources_status on human_resources(status)`,
-		`create index if not exists idx_human_resources_role_status on human_resources(role_status)`,
-		`create index if not exists idx_restrooms_status on restrooms(status)`,
-		`create index if not exists idx_restrooms_facility_type on restrooms(facility_type)`,
-		`create index if not exists idx_restrooms_is_free on restrooms(is_free)`,
-		`create index if not exists idx_restrooms_has_water on restrooms(has_water)`,
-		`create index if not exists idx_restrooms_has_lighting on restrooms(has_lighting)`,
-		`alter table if exists restrooms add column if not exists coordinates jsonb`,
-		`create table if not exists request_logs (
-            id uuid primary key default gen_random_uuid(),
-            method text not null,
-            path text not null,
-            query text,
-            ip text,
-            headers jsonb,
-            status_code int,
-            error text,
-            duration_ms int,
-            request_body jsonb,
-            original_data jsonb,
-            result_data jsonb,
-            resource_id text,
-            created_at timestamptz not null default now()
-        )`,
-		// New simplified supplies domain (replaces legacy requests/supply_items usage)
-		`create table if not exists supplies (
-            id text primary key default gen_random_uuid()::text,
-            name text,
-            address text,
-            phone text,
-            notes text,
-        pii_date bigint,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`alter table if exists supplies add column if not exists valid_pin text`,
-		`create index if not exists idx_supplies_updated_at on supplies(updated_at)`,
-		/* Renamed to supply_items (previously 'suppily_items') */
-		`create table if not exists supply_items (
-            id text primary key default gen_random_uuid()::text,
-            supply_id text not null references supplies(id) on delete cascade,
-            tag text,
-            name text,
-            received_count int not null default 0,
-            total_number int not null,
-            unit text,
-            constraint chk_supply_items_received_le_total check (received_count <= total_number)
-        )`,
-		`create index if not exists idx_supply_items_supply_id on supply_items(supply_id)`,
-		// Add new columns if migrating from older version
-		`alter table request_logs add column if not exists request_body jsonb`,
-		`alter table request_logs add column if not exists original_data jsonb`,
-		`alter table request_logs add column if not exists result_data jsonb`,
-		`alter table request_logs add column if not exists resource_id text`,
-		// If existing column is uuid, attempt to widen to text (safe no-op if already text)
-		`do $$ begin
-          perform 1 from information_schema.columns where table_name='request_logs' and column_name='resource_id' and data_type='uuid';
-          if found then
-            alter table request_logs alter column resource_id type text using resource_id::text;
-          end if;
-        end $$;`,
-		`create index if not exists idx_request_logs_created_at on request_logs(created_at)`,
-		`create index if not exists idx_request_logs_status_code on request_logs(status_code)`,
-		// Reports table
-		`create table if not exists reports (
-            id text primary key,
-            name text not null,
-            location_type text not null,
-            reason text not null,
-            notes text,
-            status text not null,
-            location_id text not null,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`alter table reports add column if not exists location_id text not null default ''`,
-		// Ensure no empty location_id remains (optional: set to placeholder if truly unknown)
-		`do $$ begin
-          update reports set location_id = 'unknown' where location_id = '';
-        end $$;`,
-		`create index if not exists idx_reports_status on reports(status)`,
-		`create index if not exists idx_reports_updated_at on reports(updated_at)`,
-		// IP denylist for middleware (single IP or CIDR patterns)
-		`create table if not exists ip_denylist (
-            id text primary key default gen_random_uuid()::text,
-            pattern text not null,
-            reason text,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_ip_denylist_pattern on ip_denylist(pattern)`,
-		// Spam detection results from LLM validation
-		`create table if not exists spam_result (
-            id text primary key,
-            target_id text not null,
-            target_type text not null,
-            target_data jsonb not null,
-            is_spam boolean not null,
-            judgment text not null,
-            validated_at bigint not null
-        )`,
-		`create index if not exists idx_spam_result_target_id on spam_result(target_id)`,
-		// Places (generic site registry)
-		`create table if not exists places (
-            id text primary key default gen_random_uuid()::text,
-            name text not null,
-            address text not null default '',
-            address_description text default '',
-            coordinates jsonb not null,
-            type text not null,
-            sub_type text default '',
-            info_sources text[],
-            verified_at bigint,
-            website_url text,
-            status text not null,
-            resources jsonb,
-            open_date text default '',
-            end_date text default '',
-            open_time text default '',
-            end_time text default '',
-            contact_name text not null,
-            contact_phone text not null,
-            notes text default '',
-            tags jsonb default '[]'::jsonb,
-            additional_info jsonb,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now(),
-            constraint chk_places_status check (status in ('開放','暫停','關閉')),
-            constraint chk_places_type check (type in ('醫療','加水','廁所','洗澡','避難','住宿','物資','心理援助'))
-        )`,
-		`create index if not exists idx_places_status on places(status)`,
-		`create index if not exists idx_places_type on places(type)`,
-		// Requirements HR (human resource needs per place)
-		`create table if not exists requirements_hr (
-            id text primary key default gen_random_uuid()::text,
-            place_id text not null references places(id) on delete cascade,
-            required_type text not null,
-            name text not null,
-            unit text not null,
-            require_count int not null,
-            received_count int not null default 0,
-            tags jsonb,
-            additional_info jsonb,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now(),
-            constraint chk_requirements_hr_required_type check (required_type in ('一般志工','專業技術','清潔整理','醫療照護','後勤支援','其他'))
-        )`,
-		`create index if not exists idx_requirements_hr_place_id on requirements_hr(place_id)`,
-		`create index if not exists idx_requirements_hr_required_type on requirements_hr(required_type)`,
-		// Requirements Supplies (material supply needs per place)
-		`create table if not exists requirements_supplies (
-            id text primary key default gen_random_uuid()::text,
-            place_id text not null references places(id) on delete cascade,
-            required_type text not null,
-            name text not null,
-            unit text not null,
-            require_count int not null,
-            received_count int not null default 0,
-            tags jsonb,
-            additional_info jsonb,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_requirements_supplies_place_id on requirements_supplies(place_id)`,
-		`create index if not exists idx_requirements_supplies_required_type on requirements_supplies(required_type)`,
-		// Supply item providers
-		`create table if not exists supply_providers (
-            id text primary key,
-            name text not null,
-            phone text not null,
-            supply_item_id text not null,
-            address text not null,
-            notes text default '',
-            provide_count int not null,
-            provide_unit text,
-            created_at timestamptz not null default now(),
-            updated_at timestamptz not null default now()
-        )`,
-		`create index if not exists idx_supply_providers_supply_item_id on supply_providers(supply_item_id)`,
+	_, err := MigrateUp(ctx, pool, 0)
+	return err
+}
+
+// MigrateUp applies up to n pending migrations (all when n <= 0) in version order and returns
+// the ones applied. Each migration runs in its own transaction.
+func MigrateUp(ctx context.Context, pool *pgxpool.Pool, n int) ([]Migration, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	err = withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if n > 0 && len(applied) >= n {
+				break
+			}
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			if err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `insert into schema_migrations(version,name,checksum) values($1,$2,$3)`, m.Version, m.Name, m.Checksum)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+		return refreshViews(ctx, conn)
+	})
+	return applied, err
+}
+
+// MigrateDown reverts the n most recently applied migrations (newest first).
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, n int) ([]Migration, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("down needs a positive count")
+	}
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	known := map[int64]Migration{}
+	for _, m := range migrations {
+		known[m.Version] = m
 	}
-	for _, s := range stmts {
-		if _, err := pool.Exec(ctx, s); err != nil {
+	var reverted []Migration
+	err = withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		rows, err := conn.Query(ctx, `select version from schema_migrations order by version desc limit $1`, n)
+		if err != nil {
 			return err
 		}
+		versions, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			m, ok := known[v]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but unknown to this binary", v)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no .down.sql", m.Version, m.Name)
+			}
+			if err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `delete from schema_migrations where version=$1`, m.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// MigrationStatuses lists every embedded migration plus any applied version this binary
+// doesn't know about, ordered by version.
+func MigrationStatuses(ctx context.Context, pool *pgxpool.Pool) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	out := []MigrationStatus{}
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := done[m.Version]; ok {
+			at := a.appliedAt
+			st.AppliedAt = &at
+			st.Modified = a.checksum != m.Checksum
+			delete(done, m.Version)
+		}
+		out = append(out, st)
+	}
+	for v, a := range done {
+		at := a.appliedAt
+		out = append(out, MigrationStatus{Version: v, Name: a.name, AppliedAt: &at, Missing: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.Query(ctx, `select version,name,checksum,applied_at from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[int64]appliedMigration{}
+	for rows.Next() {
+		var v int64
+		var a appliedMigration
+		if err := rows.Scan(&v, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		out[v] = a
+	}
+	return out, rows.Err()
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *pgxpool.Conn) error {
+	_, err := conn.Exec(ctx, `create table if not exists schema_migrations (
+            version bigint primary key,
+            name text not null,
+            checksum text not null,
+            applied_at timestamptz not null default now()
+        )`)
+	return err
+}
+
+// withMigrationLock runs fn on a single connection holding the migration advisory lock, so
+// instances booting at the same time apply migrations one after the other.
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `select pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return err
+	}
+	// unlock with a fresh context so a cancelled ctx doesn't leave the session holding the lock
+	defer conn.Exec(context.Background(), `select pg_advisory_unlock($1)`, migrationLockKey)
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// refreshViews re-creates the views generated from Go code (places_as_<table>, see internal/legacy)
+// after the versioned migrations; they are "create or replace" and follow the code, not a version.
+func refreshViews(ctx context.Context, conn *pgxpool.Conn) error {
+	var ok bool
+	if err := conn.QueryRow(ctx, `select to_regclass('places') is not null and to_regclass('legacy_place_map') is not null`).Scan(&ok); err != nil || !ok {
+		return err
+	}
+	for _, t := range legacy.Tables {
+		if _, err := conn.Exec(ctx, t.ViewSQL()); err != nil {
+			return fmt.Errorf("view %s: %w", legacy.ViewName(t.Name), err)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,6 @@
+-- Drops the whole baseline schema, including all data. Only useful on a scratch database.
+drop view if exists places_as_shelters, places_as_medical_stations, places_as_mental_health_resources, places_as_accommodations,
+    places_as_shower_stations, places_as_water_refill_stations, places_as_restrooms;
+drop table if exists legacy_place_map, supply_providers, requirements_supplies, requirements_hr, places, spam_result, ip_denylist,
+    reports, supply_items, supplies, request_logs, human_resources, restrooms, water_refill_stations, shower_stations,
+    accommodations, mental_health_resources, medical_stations, shelters, volunteer_organizations;
@@ -0,0 +1,12 @@
+do $$
+declare
+    t text;
+begin
+    foreach t in array array['volunteer_organizations','shelters','medical_stations','mental_health_resources',
+        'accommodations','shower_stations','water_refill_stations','restrooms','human_resources','supplies',
+        'supply_items','reports','spam_result','places','requirements_hr','requirements_supplies','supply_providers'] loop
+        execute format('drop trigger if exists trg_%s_versions on %I', t, t);
+    end loop;
+end $$;
+drop function if exists record_entity_version();
+drop table if exists entity_versions;
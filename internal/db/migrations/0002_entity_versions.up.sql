@@ -0,0 +1,85 @@
+-- Entity history: every insert/update/delete on a resource table appends a full row snapshot.
+-- Written by trigger so it is part of the same transaction as the change, whoever the writer is.
+-- The actor comes from the transaction-local settings app.actor_ip / app.actor_key (set per
+-- write request by middleware.RequestTx); changes made outside the API record no actor.
+
+create table if not exists entity_versions (
+    id bigserial primary key,
+    resource text not null,
+    entity_id text not null,
+    version int not null,
+    op text not null,
+    data jsonb,
+    actor_ip text,
+    actor_key text,
+    created_at timestamptz not null default now(),
+    constraint chk_entity_versions_op check (op in ('create','update','delete','snapshot')),
+    unique (resource, entity_id, version)
+);
+
+-- tg_argv[0] is the resource name used by the API (e.g. spam_results for table spam_result).
+-- PINs never go into history.
+create or replace function record_entity_version() returns trigger language plpgsql as $$
+declare
+    res text := tg_argv[0];
+    eid text;
+    snap jsonb;
+    next_version int;
+begin
+    if tg_op = 'DELETE' then
+        eid := old.id::text;
+        snap := to_jsonb(old) - 'valid_pin';
+    else
+        eid := new.id::text;
+        snap := to_jsonb(new) - 'valid_pin';
+        if tg_op = 'UPDATE'
+           and (to_jsonb(old) - 'valid_pin' - 'updated_at' - 'last_updated') = (snap - 'updated_at' - 'last_updated') then
+            return null;
+        end if;
+    end if;
+    -- serialize version numbering per entity
+    perform pg_advisory_xact_lock(hashtext(res || '/' || eid));
+    select coalesce(max(version), 0) + 1 into next_version
+      from entity_versions where resource = res and entity_id = eid;
+    insert into entity_versions (resource, entity_id, version, op, data, actor_ip, actor_key)
+    values (res, eid, next_version,
+            case tg_op when 'INSERT' then 'create' when 'UPDATE' then 'update' else 'delete' end,
+            snap,
+            nullif(current_setting('app.actor_ip', true), ''),
+            nullif(current_setting('app.actor_key', true), ''));
+    return null;
+end $$;
+
+do $$
+declare
+    t record;
+begin
+    for t in select * from (values
+        ('volunteer_organizations', 'volunteer_organizations'),
+        ('shelters', 'shelters'),
+        ('medical_stations', 'medical_stations'),
+        ('mental_health_resources', 'mental_health_resources'),
+        ('accommodations', 'accommodations'),
+        ('shower_stations', 'shower_stations'),
+        ('water_refill_stations', 'water_refill_stations'),
+        ('restrooms', 'restrooms'),
+        ('human_resources', 'human_resources'),
+        ('supplies', 'supplies'),
+        ('supply_items', 'supply_items'),
+        ('reports', 'reports'),
+        ('spam_result', 'spam_results'),
+        ('places', 'places'),
+        ('requirements_hr', 'requirements_hr'),
+        ('requirements_supplies', 'requirements_supplies'),
+        ('supply_providers', 'supply_providers')
+    ) as v(tbl, res) loop
+        execute format('drop trigger if exists trg_%s_versions on %I', t.tbl, t.tbl);
+        execute format('create trigger trg_%s_versions after insert or update or delete on %I for each row execute function record_entity_version(%L)', t.tbl, t.tbl, t.res);
+        -- existing rows start their history with a snapshot
+        execute format('insert into entity_versions (resource, entity_id, version, op, data)
+                        select %L, id::text, 1, ''snapshot'', to_jsonb(x) - ''valid_pin'' from %I x
+                        on conflict (resource, entity_id, version) do nothing', t.res, t.tbl);
+    end loop;
+end $$;
+
+create index if not exists idx_entity_versions_created_at on entity_versions(created_at);
@@ -0,0 +1,48 @@
+-- Soft-deleted rows are removed for good: without deleted_at they would come back as live rows.
+-- The places_as_<table> views read places.deleted_at; they are dropped with the column and
+-- re-created by the next db.Migrate.
+do $$
+declare
+    t text;
+begin
+    foreach t in array array['supply_items','requirements_hr','requirements_supplies','supply_providers',
+        'volunteer_organizations','shelters','medical_stations','mental_health_resources','accommodations',
+        'shower_stations','water_refill_stations','restrooms','human_resources','supplies','reports','spam_result','places'] loop
+        execute format('delete from %I where deleted_at is not null', t);
+        execute format('alter table %I drop column if exists deleted_at cascade', t);
+    end loop;
+end $$;
+
+update entity_versions set op = 'update' where op = 'restore';
+alter table entity_versions drop constraint if exists chk_entity_versions_op;
+alter table entity_versions add constraint chk_entity_versions_op check (op in ('create','update','delete','snapshot'));
+
+create or replace function record_entity_version() returns trigger language plpgsql as $$
+declare
+    res text := tg_argv[0];
+    eid text;
+    snap jsonb;
+    next_version int;
+begin
+    if tg_op = 'DELETE' then
+        eid := old.id::text;
+        snap := to_jsonb(old) - 'valid_pin';
+    else
+        eid := new.id::text;
+        snap := to_jsonb(new) - 'valid_pin';
+        if tg_op = 'UPDATE'
+           and (to_jsonb(old) - 'valid_pin' - 'updated_at' - 'last_updated') = (snap - 'updated_at' - 'last_updated') then
+            return null;
+        end if;
+    end if;
+    perform pg_advisory_xact_lock(hashtext(res || '/' || eid));
+    select coalesce(max(version), 0) + 1 into next_version
+      from entity_versions where resource = res and entity_id = eid;
+    insert into entity_versions (resource, entity_id, version, op, data, actor_ip, actor_key)
+    values (res, eid, next_version,
+            case tg_op when 'INSERT' then 'create' when 'UPDATE' then 'update' else 'delete' end,
+            snap,
+            nullif(current_setting('app.actor_ip', true), ''),
+            nullif(current_setting('app.actor_key', true), ''));
+    return null;
+end $$;
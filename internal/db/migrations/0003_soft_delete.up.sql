@@ -0,0 +1,57 @@
+-- Soft delete: DELETE endpoints set deleted_at instead of removing the row (and, for supplies and
+-- places, the rows that used to go with it through on delete cascade). Rows are removed for good
+-- by the retention purge (db.PurgeDeleted) once deleted_at is older than SOFT_DELETE_RETENTION_DAYS.
+
+do $$
+declare
+    t text;
+begin
+    foreach t in array array['volunteer_organizations','shelters','medical_stations','mental_health_resources',
+        'accommodations','shower_stations','water_refill_stations','restrooms','human_resources','supplies',
+        'supply_items','reports','spam_result','places','requirements_hr','requirements_supplies','supply_providers'] loop
+        execute format('alter table %I add column if not exists deleted_at timestamptz', t);
+        execute format('create index if not exists %I on %I(deleted_at) where deleted_at is not null', 'idx_' || t || '_deleted_at', t);
+    end loop;
+end $$;
+
+-- History: setting / clearing deleted_at is recorded as delete / restore rather than update.
+alter table entity_versions drop constraint if exists chk_entity_versions_op;
+alter table entity_versions add constraint chk_entity_versions_op check (op in ('create','update','delete','restore','snapshot'));
+
+create or replace function record_entity_version() returns trigger language plpgsql as $$
+declare
+    res text := tg_argv[0];
+    eid text;
+    snap jsonb;
+    op text;
+    next_version int;
+begin
+    if tg_op = 'DELETE' then
+        eid := old.id::text;
+        snap := to_jsonb(old) - 'valid_pin';
+        op := 'delete';
+    else
+        eid := new.id::text;
+        snap := to_jsonb(new) - 'valid_pin';
+        op := case tg_op when 'INSERT' then 'create' else 'update' end;
+        if tg_op = 'UPDATE' then
+            if (to_jsonb(old) - 'valid_pin' - 'updated_at' - 'last_updated') = (snap - 'updated_at' - 'last_updated') then
+                return null;
+            end if;
+            if to_jsonb(old)->>'deleted_at' is null and snap->>'deleted_at' is not null then
+                op := 'delete';
+            elsif to_jsonb(old)->>'deleted_at' is not null and snap->>'deleted_at' is null then
+                op := 'restore';
+            end if;
+        end if;
+    end if;
+    -- serialize version numbering per entity
+    perform pg_advisory_xact_lock(hashtext(res || '/' || eid));
+    select coalesce(max(version), 0) + 1 into next_version
+      from entity_versions where resource = res and entity_id = eid;
+    insert into entity_versions (resource, entity_id, version, op, data, actor_ip, actor_key)
+    values (res, eid, next_version, op, snap,
+            nullif(current_setting('app.actor_ip', true), ''),
+            nullif(current_setting('app.actor_key', true), ''));
+    return null;
+end $$;
@@ -0,0 +1,4 @@
+drop index if exists idx_request_logs_api_key_id;
+alter table request_logs drop column if exists api_key_name;
+alter table request_logs drop column if exists api_key_id;
+drop table if exists api_keys;
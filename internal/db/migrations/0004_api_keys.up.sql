@@ -0,0 +1,19 @@
+-- Partner API keys: only a sha256 of the key is stored; key_prefix identifies it in listings.
+create table if not exists api_keys (
+    id uuid primary key default gen_random_uuid(),
+    name text not null,
+    key_prefix text not null,
+    key_hash text not null unique,
+    scopes text[] not null default '{}',
+    expires_at timestamptz,
+    revoked boolean not null default false,
+    revoked_at timestamptz,
+    last_used_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+-- Which key (partner) made each request.
+alter table request_logs add column if not exists api_key_id uuid;
+alter table request_logs add column if not exists api_key_name text;
+create index if not exists idx_request_logs_api_key_id on request_logs(api_key_id) where api_key_id is not null;
@@ -0,0 +1,3 @@
+delete from ip_denylist where expires_at is not null;
+drop index if exists idx_ip_denylist_expires_at;
+alter table ip_denylist drop column if exists expires_at;
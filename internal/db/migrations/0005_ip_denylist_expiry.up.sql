@@ -0,0 +1,3 @@
+-- Temporary denylist entries (e.g. added by the rate limiter); null expires_at never expires.
+alter table ip_denylist add column if not exists expires_at timestamptz;
+create index if not exists idx_ip_denylist_expires_at on ip_denylist(expires_at) where expires_at is not null;
@@ -0,0 +1 @@
+alter table ip_denylist drop column if exists created_by;
@@ -0,0 +1,2 @@
+-- Who added a denylist entry: the admin API key name, or rate_limit for automatic bans.
+alter table ip_denylist add column if not exists created_by text;
@@ -0,0 +1,6 @@
+drop index if exists idx_human_resources_owner_user_id;
+drop index if exists idx_supplies_owner_user_id;
+alter table human_resources drop column if exists owner_user_id;
+alter table supplies drop column if exists owner_user_id;
+drop table if exists user_sessions;
+drop table if exists users;
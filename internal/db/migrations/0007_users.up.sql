@@ -0,0 +1,30 @@
+-- People who signed in with LINE Login, keyed by the LINE user id (id_token sub).
+create table if not exists users (
+    id uuid primary key default gen_random_uuid(),
+    line_sub text not null unique,
+    display_name text,
+    picture_url text,
+    email text,
+    last_login_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+-- Session tokens issued after a LINE login; the token itself is signed and only carries the id.
+create table if not exists user_sessions (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid not null references users(id) on delete cascade,
+    ip text,
+    user_agent text,
+    expires_at timestamptz not null,
+    revoked_at timestamptz,
+    last_used_at timestamptz,
+    created_at timestamptz not null default now()
+);
+create index if not exists idx_user_sessions_user_id on user_sessions(user_id);
+
+-- The signed-in user who created a row can edit it without the PIN.
+alter table supplies add column if not exists owner_user_id uuid references users(id) on delete set null;
+alter table human_resources add column if not exists owner_user_id uuid references users(id) on delete set null;
+create index if not exists idx_supplies_owner_user_id on supplies(owner_user_id) where owner_user_id is not null;
+create index if not exists idx_human_resources_owner_user_id on human_resources(owner_user_id) where owner_user_id is not null;
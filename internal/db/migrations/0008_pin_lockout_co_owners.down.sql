@@ -0,0 +1,8 @@
+comment on column human_resources.valid_pin is null;
+comment on column supplies.valid_pin is null;
+drop index if exists idx_supply_providers_owner_user_id;
+drop index if exists idx_places_owner_user_id;
+alter table supply_providers drop column if exists owner_user_id;
+alter table places drop column if exists owner_user_id;
+drop table if exists co_owners;
+drop table if exists pin_attempts;
@@ -0,0 +1,32 @@
+-- Failed PIN attempts per record and client IP; a row is locked_until after PIN_MAX_ATTEMPTS
+-- failures. resource / entity_id name the record holding the PIN (supplies or human_resources).
+create table if not exists pin_attempts (
+    resource text not null,
+    entity_id text not null,
+    ip text not null,
+    failures int not null default 0,
+    last_failed_at timestamptz not null default now(),
+    locked_until timestamptz,
+    primary key (resource, entity_id, ip)
+);
+
+-- Signed-in users the owner lets edit a record as if they owned it.
+create table if not exists co_owners (
+    resource text not null,
+    entity_id text not null,
+    user_id uuid not null references users(id) on delete cascade,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    primary key (resource, entity_id, user_id)
+);
+create index if not exists idx_co_owners_user_id on co_owners(user_id);
+
+alter table places add column if not exists owner_user_id uuid references users(id) on delete set null;
+alter table supply_providers add column if not exists owner_user_id uuid references users(id) on delete set null;
+create index if not exists idx_places_owner_user_id on places(owner_user_id) where owner_user_id is not null;
+create index if not exists idx_supply_providers_owner_user_id on supply_providers(owner_user_id) where owner_user_id is not null;
+
+-- valid_pin now holds a PBKDF2 hash (see internal/pin); plaintext PINs are hashed by the server
+-- on start.
+comment on column supplies.valid_pin is 'PBKDF2 hash of the edit PIN';
+comment on column human_resources.valid_pin is 'PBKDF2 hash of the edit PIN';
@@ -0,0 +1,13 @@
+-- Roles of signed-in users (see internal/policy). A site coordinator row is scoped to one place;
+-- the other roles apply everywhere.
+create table if not exists user_roles (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid not null references users(id) on delete cascade,
+    role text not null check (role in ('viewer', 'site_coordinator', 'moderator', 'partner_sync', 'admin')),
+    place_id text references places(id) on delete cascade,
+    created_by text,
+    created_at timestamptz not null default now(),
+    check ((role = 'site_coordinator') = (place_id is not null))
+);
+create unique index if not exists idx_user_roles_unique on user_roles(user_id, role, coalesce(place_id, ''));
+create index if not exists idx_user_roles_place_id on user_roles(place_id) where place_id is not null;
@@ -0,0 +1,16 @@
+-- Responses of POST requests sent with an Idempotency-Key header, replayed when the client
+-- retries with the same key (see middleware.Idempotency). scope is the caller the key belongs to:
+-- the API key name, user:<id>, or '' for anonymous callers. Rows are written in the request's
+-- transaction, so a key is only taken once the request it answered has committed.
+create table if not exists idempotency_keys (
+    scope text not null,
+    key text not null,
+    request_hash text not null,
+    status int,
+    content_type text,
+    body bytea,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    primary key (scope, key)
+);
+create index if not exists idx_idempotency_keys_expires_at on idempotency_keys(expires_at);
@@ -0,0 +1,5 @@
+drop trigger if exists trg_supply_items_received on supply_items;
+drop function if exists record_supply_received_change();
+drop table if exists supply_deliveries;
+drop function if exists apply_supply_delivery();
+drop function if exists supply_deliveries_append_only();
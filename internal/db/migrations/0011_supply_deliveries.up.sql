@@ -0,0 +1,83 @@
+-- Append-only ledger of what was delivered against a supply item. supply_items.received_count is
+-- the sum of its entries and is kept in sync by the triggers below, in the same transaction:
+--   * inserting an entry (POST /supplies/:id, a reversal) adds its quantity to received_count;
+--   * any other change of received_count (PATCH /supply_items/:id, a revert, direct SQL) is
+--     recorded as an 'adjustment' entry of the difference.
+-- A mistaken entry is never changed; it is reversed by a 'reversal' entry of the opposite
+-- quantity (reverses_id). The actor comes from app.actor_ip / app.actor_key like entity_versions.
+create table if not exists supply_deliveries (
+    id text primary key default gen_random_uuid()::text,
+    supply_item_id text not null references supply_items(id) on delete cascade,
+    kind text not null default 'delivery',
+    quantity int not null,
+    unit text,
+    provider_id text references supply_providers(id) on delete set null,
+    delivered_at timestamptz not null default now(),
+    note text,
+    reverses_id text references supply_deliveries(id) on delete cascade,
+    actor_ip text default nullif(current_setting('app.actor_ip', true), ''),
+    actor_key text default nullif(current_setting('app.actor_key', true), ''),
+    created_at timestamptz not null default now(),
+    constraint chk_supply_deliveries_kind check (kind in ('delivery','reversal','adjustment')),
+    constraint chk_supply_deliveries_quantity check (quantity <> 0),
+    constraint chk_supply_deliveries_reversal check ((kind = 'reversal') = (reverses_id is not null))
+);
+create index if not exists idx_supply_deliveries_item on supply_deliveries(supply_item_id, delivered_at);
+create unique index if not exists idx_supply_deliveries_reverses_id on supply_deliveries(reverses_id) where reverses_id is not null;
+
+-- Items received before the ledger start with one adjustment of their current count (before the
+-- triggers exist, so the count isn't added twice).
+insert into supply_deliveries (supply_item_id, kind, quantity, unit, note, actor_ip, actor_key)
+select i.id, 'adjustment', i.received_count, i.unit, 'received before the delivery ledger', null, null
+from supply_items i
+where i.received_count <> 0
+  and not exists (select 1 from supply_deliveries d where d.supply_item_id = i.id);
+
+create or replace function supply_deliveries_append_only() returns trigger language plpgsql as $$
+begin
+    raise exception 'supply_deliveries is append-only; reverse an entry instead';
+end $$;
+
+drop trigger if exists trg_supply_deliveries_append_only on supply_deliveries;
+create trigger trg_supply_deliveries_append_only before update on supply_deliveries
+    for each row execute function supply_deliveries_append_only();
+
+-- New entries move received_count. Entries written by record_supply_received_change (nested, so
+-- pg_trigger_depth() > 1) describe a change already made and are left alone.
+create or replace function apply_supply_delivery() returns trigger language plpgsql as $$
+begin
+    if pg_trigger_depth() > 1 then
+        return null;
+    end if;
+    update supply_items set received_count = received_count + new.quantity where id = new.supply_item_id;
+    return null;
+end $$;
+
+drop trigger if exists trg_supply_deliveries_apply on supply_deliveries;
+create trigger trg_supply_deliveries_apply after insert on supply_deliveries
+    for each row execute function apply_supply_delivery();
+
+-- Changes of received_count made outside the ledger become adjustment entries. Updates made by
+-- apply_supply_delivery (nested) are the ledger itself.
+create or replace function record_supply_received_change() returns trigger language plpgsql as $$
+declare
+    diff int;
+begin
+    if pg_trigger_depth() > 1 then
+        return null;
+    end if;
+    if tg_op = 'INSERT' then
+        diff := new.received_count;
+    else
+        diff := new.received_count - old.received_count;
+    end if;
+    if diff <> 0 then
+        insert into supply_deliveries (supply_item_id, kind, quantity, unit)
+        values (new.id, 'adjustment', diff, new.unit);
+    end if;
+    return null;
+end $$;
+
+drop trigger if exists trg_supply_items_received on supply_items;
+create trigger trg_supply_items_received after insert or update of received_count on supply_items
+    for each row execute function record_supply_received_change();
@@ -0,0 +1,5 @@
+drop index if exists idx_supply_providers_item_status;
+alter table supply_providers drop constraint if exists chk_supply_providers_status;
+alter table supply_providers drop column if exists delivery_id;
+alter table supply_providers drop column if exists status_changed_at;
+alter table supply_providers drop column if exists status;
@@ -0,0 +1,11 @@
+-- Pledge lifecycle of supply_providers: pledged -> confirmed -> in_transit -> delivered, or
+-- cancelled (transitions are checked by the API, see handlers.pledgeTransitions). Pledges still
+-- pledged / confirmed / in_transit count as in flight against the item's outstanding quantity.
+-- delivery_id is the supply_deliveries entry the delivered pledge was booked as, if it was.
+alter table supply_providers add column if not exists status text not null default 'pledged';
+alter table supply_providers add column if not exists status_changed_at timestamptz;
+alter table supply_providers add column if not exists delivery_id text references supply_deliveries(id) on delete set null;
+alter table supply_providers drop constraint if exists chk_supply_providers_status;
+alter table supply_providers add constraint chk_supply_providers_status
+    check (status in ('pledged','confirmed','in_transit','delivered','cancelled'));
+create index if not exists idx_supply_providers_item_status on supply_providers(supply_item_id, status) where deleted_at is null;
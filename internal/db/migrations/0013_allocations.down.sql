@@ -0,0 +1,3 @@
+drop table if exists allocations;
+drop index if exists idx_supply_providers_geo;
+alter table supply_providers drop column if exists coordinates;
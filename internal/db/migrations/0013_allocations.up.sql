@@ -0,0 +1,31 @@
+-- Matching pledges (supply_providers) with the needs of places (requirements_supplies), see
+-- GET /requirements_supplies/:id/matches. Pledges may say where they are so matches can be ranked
+-- by distance to the place in need.
+alter table supply_providers add column if not exists coordinates jsonb;
+create index if not exists idx_supply_providers_geo on supply_providers using gist (point((coordinates->>'lng')::double precision,(coordinates->>'lat')::double precision));
+
+-- A coordinator's decision on a proposed match (POST /allocations). 'accepted' sets quantity of the
+-- pledge aside for the requirement until it arrives ('fulfilled', which adds it to the
+-- requirement's received_count) or falls through ('cancelled'). 'rejected' keeps the pledge out of
+-- the requirement's matches. created_by_key comes from app.actor_key like entity_versions.
+create table if not exists allocations (
+    id text primary key default gen_random_uuid()::text,
+    requirement_id text not null references requirements_supplies(id) on delete cascade,
+    supply_provider_id text not null references supply_providers(id) on delete cascade,
+    status text not null default 'accepted',
+    quantity int not null default 0,
+    unit text,
+    score double precision,
+    distance_m double precision,
+    notes text,
+    created_by_user_id uuid references users(id) on delete set null,
+    created_by_key text default nullif(current_setting('app.actor_key', true), ''),
+    status_changed_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint chk_allocations_status check (status in ('accepted','rejected','fulfilled','cancelled')),
+    constraint chk_allocations_quantity check (quantity > 0 or (status = 'rejected' and quantity = 0))
+);
+create index if not exists idx_allocations_requirement on allocations(requirement_id, status);
+create index if not exists idx_allocations_provider on allocations(supply_provider_id, status);
+create unique index if not exists idx_allocations_rejected on allocations(requirement_id, supply_provider_id) where status = 'rejected';
@@ -0,0 +1,11 @@
+alter table allocations drop column if exists provider_quantity;
+drop trigger if exists trg_supply_providers_unit on supply_providers;
+drop trigger if exists trg_requirements_supplies_unit on requirements_supplies;
+drop trigger if exists trg_supply_items_unit on supply_items;
+drop function if exists canonicalize_provide_unit();
+drop function if exists canonicalize_unit();
+drop function if exists convert_quantity(numeric, text, text, text, text);
+drop function if exists normalize_unit(text, text, text);
+drop function if exists resolve_unit(text);
+drop table if exists unit_conversions;
+drop table if exists units;
@@ -0,0 +1,156 @@
+-- Unit catalog. units are the spellings the API knows; every unit converts to a base unit of its
+-- dimension (a base unit is its own base, factor 1): 公克 -> 公斤 x 0.001. Packaging units (箱,
+-- 包, 瓶 ...) are their own base, since how much is in them depends on the item; unit_conversions
+-- holds those per item (matched against a supply item's name or tag, or a requirement's name or
+-- required_type, lower-cased): 1 箱 of 瓶裝水 = 24 瓶. A unit's canonical form is where following
+-- both ends up; two quantities can be compared and summed when their canonical units agree.
+create table if not exists units (
+    unit text primary key,
+    base_unit text not null references units(unit) on update cascade,
+    factor numeric not null default 1,
+    aliases text[] not null default '{}',
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint chk_units_factor check (factor > 0),
+    constraint chk_units_base check (base_unit <> unit or factor = 1)
+);
+
+create table if not exists unit_conversions (
+    item text not null,
+    from_unit text not null references units(unit) on update cascade on delete cascade,
+    to_unit text not null references units(unit) on update cascade on delete cascade,
+    factor numeric not null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    primary key (item, from_unit),
+    constraint chk_unit_conversions_factor check (factor > 0),
+    constraint chk_unit_conversions_units check (from_unit <> to_unit)
+);
+
+insert into units (unit, base_unit, factor, aliases) values
+    ('個', '個', 1, '{pc,pcs,piece,pieces,ea}'),
+    ('件', '件', 1, '{}'),
+    ('瓶', '瓶', 1, '{bottle,bottles}'),
+    ('罐', '罐', 1, '{can,cans}'),
+    ('包', '包', 1, '{pack,packs}'),
+    ('袋', '袋', 1, '{bag,bags}'),
+    ('箱', '箱', 1, '{box,boxes,case,cases,carton,cartons}'),
+    ('盒', '盒', 1, '{}'),
+    ('片', '片', 1, '{}'),
+    ('條', '條', 1, '{条}'),
+    ('雙', '雙', 1, '{pair,pairs,双}'),
+    ('組', '組', 1, '{set,sets,组}'),
+    ('套', '套', 1, '{}'),
+    ('卷', '卷', 1, '{捲,roll,rolls}'),
+    ('台', '台', 1, '{臺}'),
+    ('張', '張', 1, '{张}'),
+    ('公斤', '公斤', 1, '{kg,kgs,千克}'),
+    ('公升', '公升', 1, '{l,liter,liters,litre,litres,升}'),
+    ('人', '人', 1, '{}')
+on conflict (unit) do nothing;
+insert into units (unit, base_unit, factor, aliases) values
+    ('打', '個', 12, '{dozen}'),
+    ('公克', '公斤', 0.001, '{g,克,gram,grams}'),
+    ('台斤', '公斤', 0.6, '{斤}'),
+    ('毫升', '公升', 0.001, '{ml,cc}')
+on conflict (unit) do nothing;
+insert into unit_conversions (item, from_unit, to_unit, factor) values
+    ('瓶裝水', '箱', '瓶', 24),
+    ('礦泉水', '箱', '瓶', 24),
+    ('飲用水', '箱', '瓶', 24)
+on conflict do nothing;
+
+-- The catalog unit spelled u (itself or an alias, ignoring case and surrounding spaces), or null.
+create or replace function resolve_unit(u text) returns text language sql stable as $$
+    select unit from units
+    where lower(unit) = lower(btrim(u)) or exists (select 1 from unnest(aliases) a where lower(a) = lower(btrim(u)))
+    order by lower(unit) = lower(btrim(u)) desc
+    limit 1
+$$;
+
+-- The canonical unit of u for an item, and how many of it one u is; nulls when u is unknown.
+create or replace function normalize_unit(p_item text, p_tag text, p_unit text, out canonical_unit text, out canonical_factor numeric)
+language plpgsql stable as $$
+declare
+    cur text := resolve_unit(p_unit);
+    f numeric := 1;
+    item_key text := lower(btrim(coalesce(p_item, '')));
+    tag_key text := lower(btrim(coalesce(p_tag, '')));
+    b record;
+    conv record;
+    hops int := 0;
+begin
+    if cur is null then
+        return;
+    end if;
+    loop
+        select u.base_unit, u.factor into b from units u where u.unit = cur;
+        if b.base_unit <> cur then
+            f := f * b.factor;
+            cur := b.base_unit;
+        end if;
+        exit when hops >= 4;
+        select c.to_unit, c.factor into conv from unit_conversions c
+        where c.from_unit = cur and c.item in (item_key, tag_key) and c.item <> ''
+        order by c.item = item_key desc
+        limit 1;
+        exit when not found;
+        f := f * conv.factor;
+        cur := conv.to_unit;
+        hops := hops + 1;
+    end loop;
+    canonical_unit := cur;
+    canonical_factor := f;
+end $$;
+
+-- qty in from_unit expressed in to_unit, for an item; null when the units can't be converted.
+-- A missing unit, or the same spelling on both sides, counts as the same unit.
+create or replace function convert_quantity(qty numeric, p_item text, p_tag text, from_unit text, to_unit text) returns numeric
+language plpgsql stable as $$
+declare
+    a record;
+    b record;
+begin
+    if qty is null then
+        return null;
+    end if;
+    if nullif(btrim(from_unit), '') is null or nullif(btrim(to_unit), '') is null
+       or lower(btrim(from_unit)) = lower(btrim(to_unit)) then
+        return qty;
+    end if;
+    select * into a from normalize_unit(p_item, p_tag, from_unit);
+    select * into b from normalize_unit(p_item, p_tag, to_unit);
+    if a.canonical_unit is null or b.canonical_unit is null or a.canonical_unit <> b.canonical_unit then
+        return null;
+    end if;
+    return qty * a.canonical_factor / b.canonical_factor;
+end $$;
+
+-- Units are stored in their catalog spelling ("KG" becomes 公斤); unknown units are kept as
+-- written (trimmed) and listed by GET /_admin/units/review. Rows already stored keep their spelling.
+create or replace function canonicalize_unit() returns trigger language plpgsql as $$
+begin
+    new.unit := coalesce(resolve_unit(new.unit), btrim(new.unit));
+    return new;
+end $$;
+
+create or replace function canonicalize_provide_unit() returns trigger language plpgsql as $$
+begin
+    new.provide_unit := coalesce(resolve_unit(new.provide_unit), btrim(new.provide_unit));
+    return new;
+end $$;
+
+drop trigger if exists trg_supply_items_unit on supply_items;
+create trigger trg_supply_items_unit before insert or update of unit on supply_items
+    for each row execute function canonicalize_unit();
+drop trigger if exists trg_requirements_supplies_unit on requirements_supplies;
+create trigger trg_requirements_supplies_unit before insert or update of unit on requirements_supplies
+    for each row execute function canonicalize_unit();
+drop trigger if exists trg_supply_providers_unit on supply_providers;
+create trigger trg_supply_providers_unit before insert or update of provide_unit on supply_providers
+    for each row execute function canonicalize_provide_unit();
+
+-- Allocations are counted in the requirement's unit (quantity); provider_quantity is the same
+-- amount in the pledge's unit, which is what comes off the pledge.
+alter table allocations add column if not exists provider_quantity numeric;
+update allocations set provider_quantity = quantity where provider_quantity is null;
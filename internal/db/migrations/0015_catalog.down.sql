@@ -0,0 +1,13 @@
+drop trigger if exists trg_requirements_supplies_catalog on requirements_supplies;
+drop trigger if exists trg_supply_items_catalog on supply_items;
+drop function if exists classify_supply();
+drop index if exists idx_requirements_supplies_category;
+drop index if exists idx_supply_items_category;
+alter table requirements_supplies drop column if exists catalog_item_id;
+alter table requirements_supplies drop column if exists category_id;
+alter table supply_items drop column if exists catalog_item_id;
+alter table supply_items drop column if exists category_id;
+drop function if exists resolve_catalog_item(text);
+drop function if exists resolve_catalog_category(text);
+drop table if exists catalog_items;
+drop table if exists catalog_categories;
@@ -0,0 +1,135 @@
+-- Item catalog. catalog_categories is the taxonomy supply_items.tag and
+-- requirements_supplies.required_type are mapped to; catalog_items are canonical item names
+-- (supply_items.name, requirements_supplies.name). Both match their id / name / name_en / aliases,
+-- ignoring case and surrounding spaces. tag, required_type and name stay free text; category_id
+-- and catalog_item_id are what filters and dashboards should use.
+create table if not exists catalog_categories (
+    id text primary key,
+    name text not null,
+    name_en text,
+    aliases text[] not null default '{}',
+    sort_order int not null default 0,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create table if not exists catalog_items (
+    id text primary key default gen_random_uuid()::text,
+    category_id text not null references catalog_categories(id) on update cascade,
+    name text not null,
+    name_en text,
+    aliases text[] not null default '{}',
+    default_unit text,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+create unique index if not exists idx_catalog_items_name on catalog_items(lower(name));
+create index if not exists idx_catalog_items_category on catalog_items(category_id);
+
+insert into catalog_categories (id, name, name_en, aliases, sort_order) values
+    ('food', '食物', 'Food', '{foods,食品,糧食,餐食,乾糧,即食食品,飲食}', 10),
+    ('water', '飲用水', 'Drinking water', '{水,飲水,飲料,drinks,beverages}', 20),
+    ('medical', '醫療用品', 'Medical supplies', '{medical,medicine,醫療,藥品,醫藥,急救用品}', 30),
+    ('hygiene', '衛生用品', 'Hygiene', '{衛生,個人衛生,盥洗用品,toiletries}', 40),
+    ('daily', '生活用品', 'Daily necessities', '{日用品,生活物資,daily necessities}', 50),
+    ('clothing', '衣物', 'Clothing', '{clothes,衣服,服裝}', 60),
+    ('bedding', '寢具', 'Bedding', '{寢具用品}', 70),
+    ('cleaning', '清潔用品', 'Cleaning supplies', '{cleaning,清潔,清潔工具,打掃用品}', 80),
+    ('protective', '防護用品', 'Protective equipment', '{protective,ppe,防護,防護裝備}', 90),
+    ('tools', '工具', 'Tools', '{tool,器材,工具器材,機具}', 100),
+    ('electronics', '電器', 'Electronics', '{electronics,電子產品,3c,電器用品}', 110),
+    ('baby', '嬰幼兒用品', 'Baby supplies', '{baby,嬰兒用品,幼兒用品}', 120),
+    ('pet', '寵物用品', 'Pet supplies', '{pet,pets,寵物}', 130),
+    ('other', '其他', 'Other', '{others,misc}', 1000)
+on conflict (id) do nothing;
+
+insert into catalog_items (category_id, name, name_en, aliases, default_unit) values
+    ('water', '瓶裝水', 'Bottled water', '{礦泉水,飲用水,bottled water,mineral water}', '瓶'),
+    ('food', '泡麵', 'Instant noodles', '{速食麵,方便麵,instant noodles}', '包'),
+    ('food', '罐頭', 'Canned food', '{canned food}', '罐'),
+    ('food', '餅乾', 'Biscuits', '{biscuits,crackers,cookies}', '包'),
+    ('food', '米', 'Rice', '{白米,rice}', '公斤'),
+    ('food', '便當', 'Meal box', '{餐盒,bento,meal box}', '個'),
+    ('medical', '急救包', 'First aid kit', '{first aid kit}', '個'),
+    ('medical', '酒精', 'Rubbing alcohol', '{消毒酒精,alcohol}', '瓶'),
+    ('medical', '紗布', 'Gauze', '{gauze}', '包'),
+    ('hygiene', '衛生紙', 'Toilet paper', '{toilet paper,tissue}', '包'),
+    ('hygiene', '衛生棉', 'Sanitary pads', '{sanitary pads}', '包'),
+    ('hygiene', '牙刷', 'Toothbrush', '{toothbrush}', '個'),
+    ('hygiene', '濕紙巾', 'Wet wipes', '{wet wipes,wipes}', '包'),
+    ('daily', '毛巾', 'Towel', '{towel,towels}', '條'),
+    ('clothing', '內衣褲', 'Underwear', '{underwear}', '件'),
+    ('clothing', '雨衣', 'Raincoat', '{raincoat}', '件'),
+    ('bedding', '睡袋', 'Sleeping bag', '{sleeping bag}', '個'),
+    ('bedding', '棉被', 'Blanket', '{毛毯,blanket}', '條'),
+    ('cleaning', '垃圾袋', 'Garbage bags', '{garbage bags,trash bags}', '包'),
+    ('cleaning', '水桶', 'Bucket', '{bucket}', '個'),
+    ('cleaning', '掃把', 'Broom', '{broom}', '個'),
+    ('protective', '口罩', 'Face mask', '{mask,masks,face mask}', '片'),
+    ('protective', '手套', 'Gloves', '{工作手套,gloves,work gloves}', '雙'),
+    ('protective', '雨鞋', 'Rain boots', '{雨靴,rain boots}', '雙'),
+    ('protective', '護目鏡', 'Goggles', '{goggles}', '個'),
+    ('tools', '鏟子', 'Shovel', '{圓鍬,shovel}', '個'),
+    ('tools', '手電筒', 'Flashlight', '{flashlight,torch}', '個'),
+    ('electronics', '發電機', 'Generator', '{generator}', '台'),
+    ('electronics', '行動電源', 'Power bank', '{power bank}', '個'),
+    ('baby', '尿布', 'Diapers', '{紙尿褲,diapers}', '包'),
+    ('baby', '奶粉', 'Infant formula', '{formula,milk powder}', '罐'),
+    ('pet', '寵物飼料', 'Pet food', '{狗飼料,貓飼料,pet food}', '包')
+on conflict do nothing;
+
+-- The category / item spelled t (id, name, name_en or an alias, ignoring case and surrounding
+-- spaces), or null.
+create or replace function resolve_catalog_category(t text) returns text language sql stable as $$
+    select id from catalog_categories
+    where lower(id) = lower(btrim(t)) or lower(name) = lower(btrim(t)) or lower(name_en) = lower(btrim(t))
+       or exists (select 1 from unnest(aliases) a where lower(a) = lower(btrim(t)))
+    order by lower(id) = lower(btrim(t)) desc, lower(name) = lower(btrim(t)) desc
+    limit 1
+$$;
+
+create or replace function resolve_catalog_item(t text) returns text language sql stable as $$
+    select id from catalog_items
+    where lower(name) = lower(btrim(t)) or lower(name_en) = lower(btrim(t))
+       or exists (select 1 from unnest(aliases) a where lower(a) = lower(btrim(t)))
+    order by lower(name) = lower(btrim(t)) desc
+    limit 1
+$$;
+
+alter table supply_items add column if not exists category_id text references catalog_categories(id) on update cascade on delete set null;
+alter table supply_items add column if not exists catalog_item_id text references catalog_items(id) on delete set null;
+alter table requirements_supplies add column if not exists category_id text references catalog_categories(id) on update cascade on delete set null;
+alter table requirements_supplies add column if not exists catalog_item_id text references catalog_items(id) on delete set null;
+create index if not exists idx_supply_items_category on supply_items(category_id);
+create index if not exists idx_requirements_supplies_category on requirements_supplies(category_id);
+
+-- New and edited rows are mapped as they are written: the item from name, the category from the
+-- free-text column named by the trigger argument (tag / required_type) or else the item's.
+-- category_id / catalog_item_id given explicitly are kept. Rows stored before this migration are
+-- mapped by cmd/backfill_catalog.
+create or replace function classify_supply() returns trigger language plpgsql as $$
+declare
+    new_tag text := to_jsonb(new) ->> tg_argv[0];
+begin
+    if tg_op = 'INSERT' then
+        new.catalog_item_id := coalesce(new.catalog_item_id, resolve_catalog_item(new.name));
+        new.category_id := coalesce(new.category_id, resolve_catalog_category(new_tag),
+            (select i.category_id from catalog_items i where i.id = new.catalog_item_id));
+        return new;
+    end if;
+    if new.name is distinct from old.name and new.catalog_item_id is not distinct from old.catalog_item_id then
+        new.catalog_item_id := resolve_catalog_item(new.name);
+    end if;
+    if new_tag is distinct from (to_jsonb(old) ->> tg_argv[0]) and new.category_id is not distinct from old.category_id then
+        new.category_id := coalesce(resolve_catalog_category(new_tag),
+            (select i.category_id from catalog_items i where i.id = new.catalog_item_id));
+    end if;
+    return new;
+end $$;
+
+drop trigger if exists trg_supply_items_catalog on supply_items;
+create trigger trg_supply_items_catalog before insert or update on supply_items
+    for each row execute function classify_supply('tag');
+drop trigger if exists trg_requirements_supplies_catalog on requirements_supplies;
+create trigger trg_requirements_supplies_catalog before insert or update on requirements_supplies
+    for each row execute function classify_supply('required_type');
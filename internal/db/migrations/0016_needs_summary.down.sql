@@ -0,0 +1,4 @@
+drop table if exists stats_refreshes;
+drop materialized view if exists needs_summary;
+drop function if exists place_township(text, jsonb);
+drop function if exists address_township(text);
@@ -0,0 +1,81 @@
+-- Township (鄉鎮市區) of a Taiwanese address, or null: "970花蓮縣光復鄉中正路" -> 光復鄉,
+-- "花蓮市中山路" -> 花蓮市, "台北市大安區" -> 大安區.
+create or replace function address_township(addr text) returns text language sql immutable as $$
+    select substring(
+        regexp_replace(regexp_replace(coalesce(addr, ''), '^[0-9\s]*(台灣|臺灣)?[0-9\s]*', ''),
+            '^[^\s0-9]{2}縣|^[^\s0-9]{2}市(?=[^\s0-9鄉鎮市區]{1,3}區)', ''),
+        '^([^\s0-9鄉鎮市區縣]{1,3}[鄉鎮市區])')
+$$;
+
+-- A place's township: additional_info.township when set (legacy accommodations carry one),
+-- otherwise from its address.
+create or replace function place_township(addr text, info jsonb) returns text language sql immutable as $$
+    select coalesce(nullif(btrim(info ->> 'township'), ''), nullif(btrim(info #>> '{legacy,township}'), ''), address_township(addr))
+$$;
+
+-- Outstanding needs, pre-aggregated for GET /stats/needs: supply items (total_number -
+-- received_count), place supply requirements and place HR requirements (require_count -
+-- received_count), per category, catalog item, unit, place, township, fulfilment status and the
+-- hour the need was posted. Supply quantities are in their canonical unit where the unit catalog
+-- knows it. Refreshed in the background (db.StartNeedsSummaryLoop); stats_refreshes records when.
+create materialized view if not exists needs_summary as
+with needs as (
+    select 'supply_items'::text as kind, i.category_id, coalesce(cc.name, nullif(btrim(i.tag), '')) as category,
+        i.catalog_item_id, coalesce(ci.name, nullif(btrim(i.name), '')) as item,
+        null::text as place_id, null::text as place, address_township(s.address) as township,
+        i.total_number as required, i.received_count as received, i.unit, n.canonical_unit, n.canonical_factor, s.created_at
+    from supply_items i
+    join supplies s on s.id = i.supply_id and s.deleted_at is null
+    left join catalog_categories cc on cc.id = i.category_id
+    left join catalog_items ci on ci.id = i.catalog_item_id
+    left join lateral normalize_unit(i.name, i.tag, i.unit) n on true
+    where i.deleted_at is null
+    union all
+    select 'requirements_supplies', r.category_id, coalesce(cc.name, nullif(btrim(r.required_type), '')),
+        r.catalog_item_id, coalesce(ci.name, nullif(btrim(r.name), '')),
+        p.id, p.name, place_township(p.address, p.additional_info),
+        r.require_count, r.received_count, r.unit, n.canonical_unit, n.canonical_factor, r.created_at
+    from requirements_supplies r
+    join places p on p.id = r.place_id and p.deleted_at is null
+    left join catalog_categories cc on cc.id = r.category_id
+    left join catalog_items ci on ci.id = r.catalog_item_id
+    left join lateral normalize_unit(r.name, r.required_type, r.unit) n on true
+    where r.deleted_at is null
+    union all
+    select 'requirements_hr', null, r.required_type, null, nullif(btrim(r.name), ''),
+        p.id, p.name, place_township(p.address, p.additional_info),
+        r.require_count, r.received_count, r.unit, null, null, r.created_at
+    from requirements_hr r
+    join places p on p.id = r.place_id and p.deleted_at is null
+    where r.deleted_at is null
+), flat as (
+    select kind, category_id, category, catalog_item_id, item, place_id, place, township,
+        coalesce(canonical_unit, nullif(btrim(unit), '')) as unit,
+        kind <> 'requirements_hr' and canonical_unit is null and nullif(btrim(unit), '') is not null as unit_review,
+        case when received >= required then 'met' when received > 0 then 'partial' else 'unmet' end as status,
+        date_trunc('hour', created_at) as bucket,
+        required * coalesce(canonical_factor, 1) as required,
+        received * coalesce(canonical_factor, 1) as received,
+        greatest(required - received, 0) * coalesce(canonical_factor, 1) as missing
+    from needs
+)
+select md5(row(kind, category_id, category, catalog_item_id, item, place_id, place, township, unit, unit_review, status, bucket)::text) as key,
+    kind, category_id, category, catalog_item_id, item, place_id, place, township, unit, unit_review, status, bucket,
+    count(*)::int as needs,
+    sum(required)::double precision as required,
+    sum(received)::double precision as received,
+    sum(missing)::double precision as missing
+from flat
+group by kind, category_id, category, catalog_item_id, item, place_id, place, township, unit, unit_review, status, bucket;
+
+-- the unique index is what lets the view be refreshed concurrently, without blocking readers
+create unique index if not exists idx_needs_summary_key on needs_summary(key);
+create index if not exists idx_needs_summary_bucket on needs_summary(bucket);
+
+-- When each background-maintained summary was last recomputed and how long that took.
+create table if not exists stats_refreshes (
+    name text primary key,
+    refreshed_at timestamptz not null default now(),
+    duration_ms int not null default 0
+);
+insert into stats_refreshes (name) values ('needs_summary') on conflict (name) do nothing;
@@ -0,0 +1 @@
+drop table if exists metric_samples;
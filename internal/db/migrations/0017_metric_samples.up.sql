@@ -0,0 +1,13 @@
+-- Values that are overwritten in place (shelter occupancy, accommodation vacancy, supply item
+-- received_count), sampled periodically by db.StartSnapshotLoop for GET /{resource}/{id}/timeseries.
+-- A sample is only stored when the value differs from the previous one of its series, so a series
+-- is a step function: the value holds until the next sample.
+create table if not exists metric_samples (
+    resource text not null,
+    entity_id text not null,
+    metric text not null,
+    ts timestamptz not null,
+    value double precision not null,
+    primary key (resource, entity_id, metric, ts)
+);
+create index if not exists idx_metric_samples_ts on metric_samples(ts);
@@ -0,0 +1,4 @@
+create or replace function supply_deliveries_append_only() returns trigger language plpgsql as $$
+begin
+    raise exception 'supply_deliveries is append-only; reverse an entry instead';
+end $$;
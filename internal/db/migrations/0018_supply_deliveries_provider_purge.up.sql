@@ -0,0 +1,11 @@
+-- supply_deliveries.provider_id is "on delete set null", which Postgres carries out as an UPDATE of
+-- the ledger; purging a supply provider with a booked delivery used to fail on the append-only
+-- trigger. That one update (provider_id to null, nothing else) is allowed now.
+create or replace function supply_deliveries_append_only() returns trigger language plpgsql as $$
+begin
+    if old.provider_id is not null and new.provider_id is null
+       and (to_jsonb(new) - 'provider_id') = (to_jsonb(old) - 'provider_id') then
+        return new;
+    end if;
+    raise exception 'supply_deliveries is append-only; reverse an entry instead';
+end $$;
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SoftDeleteTables are the tables with a deleted_at column (migration 0003_soft_delete). Children
+// come before their parents so a purge removes them explicitly instead of through the cascade.
+var SoftDeleteTables = []string{
+	"supply_items", "requirements_hr", "requirements_supplies", "supply_providers",
+	"volunteer_organizations", "shelters", "medical_stations", "mental_health_resources", "accommodations",
+	"shower_stations", "water_refill_stations", "restrooms", "human_resources", "supplies", "reports",
+	"spam_result", "places",
+}
+
+// PurgeDeleted permanently removes rows that were soft-deleted more than retention ago and returns
+// the number of rows removed per table. The removal is recorded in entity_versions like any delete.
+// A table that fails is skipped and reported in the returned error; the others are still purged.
+func PurgeDeleted(ctx context.Context, pool *pgxpool.Pool, retention time.Duration) (map[string]int64, error) {
+	cutoff := time.Now().Add(-retention)
+	out := map[string]int64{}
+	var errs []error
+	for _, t := range SoftDeleteTables {
+		tag, err := pool.Exec(ctx, "delete from "+t+" where deleted_at is not null and deleted_at < $1", cutoff)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t, err))
+			continue
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			out[t] = n
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// StartPurgeLoop runs PurgeDeleted every interval until ctx is cancelled.
+func StartPurgeLoop(ctx context.Context, pool *pgxpool.Pool, retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			purged, err := PurgeDeleted(ctx, pool, retention)
+			if err != nil {
+				log.Printf("purge deleted rows: %v", err)
+			}
+			if len(purged) > 0 {
+				log.Printf("purged soft-deleted rows older than %s: %v", retention, purged)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DBTX is the query surface shared by *pgxpool.Pool and pgx.Tx.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// RequestTxKey is the gin context key holding the *RequestTx of a write request.
+const RequestTxKey = "db.request_tx"
+
+// Actor identifies who made a change; it is exposed to triggers (entity_versions) through the
+// transaction-local settings app.actor_ip / app.actor_key.
+type Actor struct {
+	IP     string
+	APIKey string
+}
+
+// RequestTx is a transaction shared by everything a single write request does. It is begun on
+// first use, so requests that never touch the database don't hold a connection.
+type RequestTx struct {
+	pool  *pgxpool.Pool
+	actor Actor
+	mu    sync.Mutex
+	tx    pgx.Tx
+	err   error
+	after []func()
+}
+
+func NewRequestTx(pool *pgxpool.Pool, actor Actor) *RequestTx {
+	return &RequestTx{pool: pool, actor: actor}
+}
+
+// DB returns the request transaction, beginning it on first call. If the transaction can't be
+// started every statement run through the returned DBTX fails with that error.
+func (r *RequestTx) DB(ctx context.Context) DBTX {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tx == nil && r.err == nil {
+		tx, err := r.pool.Begin(ctx)
+		if err == nil {
+			_, err = tx.Exec(ctx, `select set_config('app.actor_ip', $1, true), set_config('app.actor_key', $2, true)`, r.actor.IP, r.actor.APIKey)
+			if err != nil {
+				_ = tx.Rollback(ctx)
+			}
+		}
+		r.tx, r.err = tx, err
+	}
+	if r.err != nil {
+		return failedDB{r.err}
+	}
+	return r.tx
+}
+
+// AfterCommit registers fn to run once the transaction has been committed, for side effects
+// (cache refreshes) that must not see the data before other connections can.
+func (r *RequestTx) AfterCommit(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.after = append(r.after, fn)
+}
+
+// Finish commits (commit=true) or rolls back the transaction, if one was started, and runs the
+// AfterCommit functions when the request's changes were committed.
+func (r *RequestTx) Finish(ctx context.Context, commit bool) error {
+	r.mu.Lock()
+	tx, after := r.tx, r.after
+	r.tx, r.after = nil, nil
+	failed := r.err != nil
+	r.mu.Unlock()
+	if failed {
+		return nil
+	}
+	if tx != nil && !commit {
+		return tx.Rollback(ctx)
+	}
+	if tx != nil {
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+	} else if !commit {
+		return nil
+	}
+	for _, fn := range after {
+		fn()
+	}
+	return nil
+}
+
+type failedDB struct{ err error }
+
+func (f failedDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, f.err
+}
+func (f failedDB) Query(context.Context, string, ...any) (pgx.Rows, error) { return nil, f.err }
+func (f failedDB) QueryRow(context.Context, string, ...any) pgx.Row        { return failedRow(f) }
+func (f failedDB) Begin(context.Context) (pgx.Tx, error)                   { return nil, f.err }
+
+type failedRow struct{ err error }
+
+func (f failedRow) Scan(...any) error { return f.err }
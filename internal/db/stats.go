@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// statsLockKey is the pg_advisory_lock key that keeps several instances from recomputing the
+// same summary at once.
+const statsLockKey int64 = 250924
+
+// RefreshNeedsSummary recomputes the needs_summary materialized view (migration 0016) without
+// blocking its readers and records the time in stats_refreshes. It reports false, doing nothing,
+// when another instance is refreshing it already.
+func RefreshNeedsSummary(ctx context.Context, pool *pgxpool.Pool) (bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	var locked bool
+	if err := tx.QueryRow(ctx, `select pg_try_advisory_xact_lock($1)`, statsLockKey).Scan(&locked); err != nil || !locked {
+		return false, err
+	}
+	start := time.Now()
+	if _, err := tx.Exec(ctx, `refresh materialized view concurrently needs_summary`); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `insert into stats_refreshes(name,refreshed_at,duration_ms) values('needs_summary',now(),$1)
+		on conflict (name) do update set refreshed_at=excluded.refreshed_at,duration_ms=excluded.duration_ms`, time.Since(start).Milliseconds()); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// StartNeedsSummaryLoop runs RefreshNeedsSummary every interval until ctx is cancelled.
+func StartNeedsSummaryLoop(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := RefreshNeedsSummary(ctx, pool); err != nil && ctx.Err() == nil {
+				log.Printf("refresh needs summary: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// snapshotLockKey is the pg_advisory_lock key that keeps several instances from taking the same
+// snapshot.
+const snapshotLockKey int64 = 250925
+
+// TimeseriesMetric is a value SnapshotMetrics records for every live row of a resource.
+type TimeseriesMetric struct {
+	Resource string // resource path segment, which is also the table
+	Name     string
+	Expr     string // SQL giving the value from the row; rows where it is null are skipped
+}
+
+// TimeseriesMetrics are the values recorded into metric_samples (migration 0017).
+var TimeseriesMetrics = []TimeseriesMetric{
+	{"shelters", "current_occupancy", "current_occupancy"},
+	{"shelters", "available_spaces", "available_spaces"},
+	// has_vacancy is free text; 1 while rooms are available, 0 when full, nothing when unknown
+	{"accommodations", "has_vacancy", "case lower(btrim(has_vacancy)) when 'available' then 1 when '有空房' then 1 when 'full' then 0 when '已滿' then 0 end"},
+	{"supply_items", "received_count", "received_count"},
+}
+
+// SnapshotMetrics samples every TimeseriesMetrics value that changed since its last sample and
+// returns how many samples it stored. It stores nothing, reporting 0, while another instance is
+// taking a snapshot.
+func SnapshotMetrics(ctx context.Context, pool *pgxpool.Pool) (int64, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	var locked bool
+	if err := tx.QueryRow(ctx, `select pg_try_advisory_xact_lock($1)`, snapshotLockKey).Scan(&locked); err != nil || !locked {
+		return 0, err
+	}
+	var n int64
+	for _, m := range TimeseriesMetrics {
+		// now() is the transaction's start, so one snapshot shares one timestamp
+		tag, err := tx.Exec(ctx, `insert into metric_samples(resource,entity_id,metric,ts,value)
+			select $1,t.id,$2,now(),v.value from `+m.Resource+` t cross join lateral (select (`+m.Expr+`)::double precision as value) v
+			where t.deleted_at is null and v.value is not null
+			and v.value is distinct from (select s.value from metric_samples s where s.resource=$1 and s.entity_id=t.id and s.metric=$2 order by s.ts desc limit 1)`,
+			m.Resource, m.Name)
+		if err != nil {
+			return 0, err
+		}
+		n += tag.RowsAffected()
+	}
+	return n, tx.Commit(ctx)
+}
+
+// PurgeMetricSamples removes samples older than retention, except the last one before the cutoff
+// of each series, which still holds at the cutoff. It returns how many it removed.
+func PurgeMetricSamples(ctx context.Context, pool *pgxpool.Pool, retention time.Duration) (int64, error) {
+	tag, err := pool.Exec(ctx, `delete from metric_samples s where s.ts < $1 and exists (
+		select 1 from metric_samples l where l.resource=s.resource and l.entity_id=s.entity_id and l.metric=s.metric and l.ts > s.ts and l.ts <= $1)`,
+		time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// StartSnapshotLoop runs SnapshotMetrics every interval until ctx is cancelled, and
+// PurgeMetricSamples once a day unless retention is 0.
+func StartSnapshotLoop(ctx context.Context, pool *pgxpool.Pool, interval, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var lastPurge time.Time
+		for {
+			if _, err := SnapshotMetrics(ctx, pool); err != nil && ctx.Err() == nil {
+				log.Printf("snapshot metrics: %v", err)
+			}
+			if retention > 0 && time.Since(lastPurge) >= 24*time.Hour {
+				if n, err := PurgeMetricSamples(ctx, pool, retention); err != nil && ctx.Err() == nil {
+					log.Printf("purge metric samples: %v", err)
+				} else if n > 0 {
+					log.Printf("purged %d metric samples older than %s", n, retention)
+				}
+				lastPurge = time.Now()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
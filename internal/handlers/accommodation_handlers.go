@@ -52,7 +52,7 @@ func (h *Handler) CreateAccommodation(c *gin.Context) {
 	}
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into accommodations(township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15::text[],$16,$17::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+	err := h.db(c).QueryRow(ctx, `insert into accommodations(township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15::text[],$16,$17::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
 		in.Township, in.Name, in.HasVacancy, in.AvailablePeriod, in.Restrictions, in.ContactInfo, in.RoomInfo, in.Address, in.Pricing, in.InfoSource, in.Notes, in.Capacity, in.Status, in.RegistrationMethod, in.Facilities, in.DistanceToDisaster, coordsJSON).Scan(&id, &created, &updated)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -162,9 +162,9 @@ func (h *Handler) PatchAccommodation(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update accommodations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update accommodations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var a models.Accommodation
 	var restrictions, roomInfo, infoSource, notes, regMethod, distance *string
 	var facilities []string
@@ -201,14 +201,14 @@ func (h *Handler) PatchAccommodation(c *gin.Context) {
 func (h *Handler) GetAccommodation(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from accommodations where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from ` + h.legacySource("accommodations") + ` where id=$1`+liveCond(c), id)
 	var a models.Accommodation
 	var restrictions, roomInfo, infoSource, notes, regMethod, distance *string
 	var facilities []string
 	var capacity *int
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&a.ID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&a.ID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated, &a.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -241,6 +241,11 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 	status := c.Query("status")
 	township := c.Query("township")
 	hasVacancy := c.Query("has_vacancy")
+	geo, err := parseGeoQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := context.Background()
 	filters := []string{}
 	args := []interface{}{}
@@ -256,21 +261,23 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 		filters = append(filters, "has_vacancy=$"+strconv.Itoa(len(args)+1))
 		args = append(args, hasVacancy)
 	}
-	countQ := "select count(*) from accommodations"
-	dataQ := "select id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from accommodations"
+	filters = appendLiveFilter(c, filters)
+	filters, args = geo.apply(filters, args)
+	countQ := "select count(*) from " + h.legacySource("accommodations")
+	dataQ := "select id,township,name,has_vacancy,available_period,restrictions,contact_info,room_info,address,pricing,info_source,notes,capacity,status,registration_method,facilities,distance_to_disaster_area,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint," + geo.distanceColumn() + " from " + h.legacySource("accommodations")
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
 		dataQ += where
 	}
 	var total int
-	if err := h.pool.QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	args = append(args, limit, offset)
-	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
-	rows, err := h.pool.Query(ctx, dataQ, args...)
+	dataQ += " order by " + geo.orderBy("updated_at desc") + " limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
+	rows, err := h.db(c).Query(ctx, dataQ, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -284,7 +291,7 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 		var capacity *int
 		var lat, lng *float64
 		var created, updated int64
-		if err := rows.Scan(&a.ID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated); err != nil {
+		if err := rows.Scan(&a.ID, &a.Township, &a.Name, &a.HasVacancy, &a.AvailablePeriod, &restrictions, &a.ContactInfo, &roomInfo, &a.Address, &a.Pricing, &infoSource, &notes, &capacity, &a.Status, &regMethod, &facilities, &distance, &lat, &lng, &created, &updated, &a.DeletedAt, &a.DistanceM); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -323,5 +330,5 @@ func (h *Handler) ListAccommodations(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
-	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+	writeLocationCollection(c, list, total, limit, offset, next, prev)
 }
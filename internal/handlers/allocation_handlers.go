@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Allocation is a coordinator's decision on a proposed match between a requirement
+// (requirements_supplies) and a pledge (supply_providers), see migration 0013_allocations.
+type Allocation struct {
+	ID               string   `json:"id"`
+	RequirementID    string   `json:"requirement_id"`
+	SupplyProviderID string   `json:"supply_provider_id"`
+	Status           string   `json:"status"`
+	Quantity         int      `json:"quantity"`
+	Unit             *string  `json:"unit"`              // the requirement's
+	ProviderQuantity *float64 `json:"provider_quantity"` // quantity in the pledge's unit
+	Score            *float64 `json:"score"`      // of the match when it was decided
+	DistanceM        *float64 `json:"distance_m"` // likewise
+	Notes            *string  `json:"notes"`
+	CreatedByUserID  *string  `json:"created_by_user_id"`
+	CreatedByKey     *string  `json:"created_by_key"`
+	StatusChangedAt  *int64   `json:"status_changed_at"`
+	CreatedAt        int64    `json:"created_at"`
+	UpdatedAt        int64    `json:"updated_at"`
+}
+
+const allocationColumns = `id,requirement_id,supply_provider_id,status,quantity,unit,provider_quantity::double precision,score,distance_m,notes,created_by_user_id::text,created_by_key,
+	extract(epoch from status_changed_at)::bigint,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+func scanAllocation(row pgx.Row) (Allocation, error) {
+	var a Allocation
+	err := row.Scan(&a.ID, &a.RequirementID, &a.SupplyProviderID, &a.Status, &a.Quantity, &a.Unit, &a.ProviderQuantity, &a.Score, &a.DistanceM, &a.Notes,
+		&a.CreatedByUserID, &a.CreatedByKey, &a.StatusChangedAt, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// allocationStatuses are the statuses of an allocation.
+var allocationStatuses = []string{"accepted", "rejected", "fulfilled", "cancelled"}
+
+// allocationTransitions are the statuses an allocation may move to; rejected, fulfilled and
+// cancelled are final.
+var allocationTransitions = map[string][]string{
+	"accepted": {"fulfilled", "cancelled"},
+}
+
+type allocationCreateInput struct {
+	RequirementID    string  `json:"requirement_id" binding:"required"`
+	SupplyProviderID string  `json:"supply_provider_id" binding:"required"`
+	Status           string  `json:"status"`   // accepted (default) or rejected
+	Quantity         *int    `json:"quantity"` // defaults to the match's suggested_quantity
+	Notes            *string `json:"notes"`
+}
+
+// CreateAllocation records a coordinator's decision on a proposed match. Accepting sets quantity of
+// the pledge aside for the requirement; it can't be more than the pledge has left or than the
+// requirement still lacks (409), and the pledge's unit must convert to the requirement's (400). Rejecting keeps the pledge out of
+// the requirement's matches. The requirement and the pledge stay locked until the request commits,
+// so concurrent decisions can't allocate the same quantity twice.
+func (h *Handler) CreateAllocation(c *gin.Context) {
+	var in allocationCreateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if in.Status == "" {
+		in.Status = "accepted"
+	}
+	if in.Status != "accepted" && in.Status != "rejected" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be accepted or rejected"})
+		return
+	}
+	ctx := context.Background()
+	need, err := h.loadMatchNeed(c, in.RequirementID, true)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found", "reason": "requirement not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := scanMatchOffer(h.db(c).QueryRow(ctx, `select `+matchOfferColumns+matchOfferFrom+` and p.id=$1 for update of p`, in.SupplyProviderID, need.Unit))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found", "reason": "supply provider not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	scoreMatch(need, &m)
+
+	quantity := 0
+	if in.Status == "rejected" {
+		var rejected bool
+		if err := h.db(c).QueryRow(ctx, `select exists(select 1 from allocations where requirement_id=$1 and supply_provider_id=$2 and status='rejected')`, need.ID, m.SupplyProviderID).Scan(&rejected); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if rejected {
+			c.JSON(http.StatusConflict, gin.H{"error": "already rejected for this requirement"})
+			return
+		}
+	} else {
+		if m.Status != "pledged" && m.Status != "confirmed" { // see pledgeMatchable
+			c.JSON(http.StatusConflict, gin.H{"error": "the pledge is " + m.Status + " and can't be allocated", "status": m.Status})
+			return
+		}
+		if !m.convertible {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the pledge's unit does not convert to the requirement's unit", "unit": need.Unit, "provide_unit": m.Unit})
+			return
+		}
+		quantity = min(need.Outstanding, m.AvailableCount)
+		if in.Quantity != nil {
+			quantity = *in.Quantity
+		}
+		if quantity <= 0 || quantity > m.AvailableCount || quantity > need.Outstanding {
+			c.JSON(http.StatusConflict, gin.H{"error": "quantity must be > 0 and within what the pledge has left and the requirement still lacks",
+				"quantity": quantity, "available_count": m.AvailableCount, "outstanding_count": max(need.Outstanding, 0)})
+			return
+		}
+		// an earlier rejection no longer applies
+		if _, err := h.db(c).Exec(ctx, `delete from allocations where requirement_id=$1 and supply_provider_id=$2 and status='rejected'`, need.ID, m.SupplyProviderID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// what the allocation takes off the pledge, in the pledge's unit
+	var providerQuantity *float64
+	if err := h.db(c).QueryRow(ctx, `select convert_quantity($1::numeric,i.name,i.tag,$2::text,coalesce(nullif(p.provide_unit,''),i.unit))::double precision
+		from supply_providers p join supply_items i on i.id=p.supply_item_id where p.id=$3`, quantity, need.Unit, m.SupplyProviderID).Scan(&providerQuantity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	a, err := scanAllocation(h.db(c).QueryRow(ctx, `insert into allocations(requirement_id,supply_provider_id,status,quantity,unit,provider_quantity,score,distance_m,notes,created_by_user_id,status_changed_at)
+		values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,now()) returning `+allocationColumns,
+		need.ID, m.SupplyProviderID, in.Status, quantity, need.Unit, providerQuantity, m.Score, m.DistanceM, in.Notes, currentUserID(c)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, a)
+}
+
+// ListAllocations lists allocations, latest first, by requirement_id, place_id,
+// supply_provider_id and status.
+func (h *Handler) ListAllocations(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := context.Background()
+	filters := []string{}
+	args := []interface{}{}
+	for _, f := range []string{"requirement_id", "supply_provider_id", "status"} {
+		if v := c.Query(f); v != "" {
+			filters = append(filters, f+"=$"+strconv.Itoa(len(args)+1))
+			args = append(args, v)
+		}
+	}
+	if v := c.Query("place_id"); v != "" {
+		filters = append(filters, "requirement_id in (select id from requirements_supplies where place_id=$"+strconv.Itoa(len(args)+1)+")")
+		args = append(args, v)
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, `select count(*) from allocations`+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	args = append(args, limit, offset)
+	rows, err := h.db(c).Query(ctx, `select `+allocationColumns+` from allocations`+where+` order by created_at desc limit $`+strconv.Itoa(len(args)-1)+` offset $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []Allocation{}
+	for rows.Next() {
+		a, err := scanAllocation(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, a)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prev *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		s := build(max(offset-limit, 0))
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+func (h *Handler) GetAllocation(c *gin.Context) {
+	a, err := scanAllocation(h.db(c).QueryRow(context.Background(), `select `+allocationColumns+` from allocations where id=$1`, c.Param("id")))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+type allocationPatchInput struct {
+	Status *string `json:"status"` // see allocationTransitions
+	Notes  *string `json:"notes"`
+}
+
+// PatchAllocation tracks an accepted allocation: fulfilled when the goods arrived, which adds the
+// quantity to the requirement's received_count, or cancelled when they won't, which gives it back
+// to the pledge.
+func (h *Handler) PatchAllocation(c *gin.Context) {
+	id := c.Param("id")
+	var in allocationPatchInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if in.Status == nil && in.Notes == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
+		return
+	}
+	ctx := context.Background()
+	cur, err := scanAllocation(h.db(c).QueryRow(ctx, `select `+allocationColumns+` from allocations where id=$1 for update`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	setParts := []string{}
+	args := []interface{}{}
+	if in.Status != nil && *in.Status != cur.Status {
+		to := *in.Status
+		if !slices.Contains(allocationStatuses, to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown status: " + to, "allowed": allocationStatuses})
+			return
+		}
+		if !slices.Contains(allocationTransitions[cur.Status], to) {
+			c.JSON(http.StatusConflict, gin.H{"error": "cannot change status from " + cur.Status + " to " + to, "status": cur.Status, "allowed": allocationTransitions[cur.Status]})
+			return
+		}
+		if to == "fulfilled" {
+			if _, err := h.db(c).Exec(ctx, `update requirements_supplies set received_count=received_count+$2,updated_at=now() where id=$1`, cur.RequirementID, cur.Quantity); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		args = append(args, to)
+		setParts = append(setParts, "status=$"+strconv.Itoa(len(args)), "status_changed_at=now()")
+	}
+	if in.Notes != nil {
+		args = append(args, *in.Notes)
+		setParts = append(setParts, "notes=$"+strconv.Itoa(len(args)))
+	}
+	if len(setParts) == 0 { // an unchanged status still answers with the allocation
+		c.JSON(http.StatusOK, cur)
+		return
+	}
+	setParts = append(setParts, "updated_at=now()")
+	args = append(args, id)
+	a, err := scanAllocation(h.db(c).QueryRow(ctx, `update allocations set `+strings.Join(setParts, ",")+` where id=$`+strconv.Itoa(len(args))+` returning `+allocationColumns, args...))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/middleware"
+	"guangfu250923/internal/models"
+	"guangfu250923/internal/policy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `id,name,key_prefix,scopes,extract(epoch from expires_at)::bigint,revoked,extract(epoch from revoked_at)::bigint,
+	extract(epoch from last_used_at)::bigint,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var k models.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.KeyPrefix, &k.Scopes, &k.ExpiresAt, &k.Revoked, &k.RevokedAt, &k.LastUsedAt, &k.CreatedAt, &k.UpdatedAt)
+	return k, err
+}
+
+// validAPIKeyScope accepts "*", "admin" (the /_admin endpoints), "<resource>:write" for the
+// resources that can be changed through the API, and roles as "role:<role>" (site coordinators as
+// "role:site_coordinator:<place id>").
+func validAPIKeyScope(s string) bool {
+	if s == "*" || s == "admin" {
+		return true
+	}
+	if g, ok := strings.CutPrefix(s, "role:"); ok {
+		_, valid := policy.ParseGrant(g)
+		return valid
+	}
+	res, ok := strings.CutSuffix(s, ":write")
+	_, known := historyTables[res]
+	return ok && (known || res == "allocations")
+}
+
+func checkAPIKeyScopes(scopes []string) string {
+	if len(scopes) == 0 {
+		return "scopes is required"
+	}
+	for _, s := range scopes {
+		if !validAPIKeyScope(s) {
+			return "unknown scope: " + s
+		}
+	}
+	return ""
+}
+
+// generateAPIKey returns a new plain key and the prefix shown in listings.
+func generateAPIKey() (string, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key := "gf_" + hex.EncodeToString(b)
+	return key, key[:11], nil
+}
+
+type apiKeyCreateInput struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt *int64   `json:"expires_at"`
+}
+
+type apiKeyPatchInput struct {
+	Name      *string   `json:"name"`
+	Scopes    *[]string `json:"scopes"`
+	ExpiresAt *int64    `json:"expires_at"` // 0 removes the expiry
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := context.Background()
+	filters := []string{}
+	args := []interface{}{}
+	if v := c.Query("revoked"); v == "true" || v == "false" {
+		filters = append(filters, "revoked=$"+strconv.Itoa(len(args)+1))
+		args = append(args, v == "true")
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, "select count(*) from api_keys"+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	args = append(args, limit, offset)
+	rows, err := h.db(c).Query(ctx, "select "+apiKeyColumns+" from api_keys"+where+" order by created_at desc limit $"+strconv.Itoa(len(args)-1)+" offset $"+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, k)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prev *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		po := offset - limit
+		if po < 0 {
+			po = 0
+		}
+		s := build(po)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+func (h *Handler) GetAPIKey(c *gin.Context) {
+	k, err := scanAPIKey(h.db(c).QueryRow(context.Background(), "select "+apiKeyColumns+" from api_keys where id=$1", c.Param("id")))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, k)
+}
+
+// CreateAPIKey issues a key for a partner. The plain key is in the response and nowhere else.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var in apiKeyCreateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if msg := checkAPIKeyScopes(in.Scopes); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	var expires interface{}
+	if in.ExpiresAt != nil && *in.ExpiresAt > 0 {
+		expires = *in.ExpiresAt
+	}
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	k, err := scanAPIKey(h.db(c).QueryRow(context.Background(), `insert into api_keys(name,key_prefix,key_hash,scopes,expires_at) values($1,$2,$3,$4,to_timestamp($5::bigint))
+		returning `+apiKeyColumns, in.Name, prefix, middleware.HashAPIKey(key), in.Scopes, expires))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	k.Key = &key
+	middleware.ForgetAPIKeys()
+	c.JSON(http.StatusCreated, k)
+}
+
+func (h *Handler) PatchAPIKey(c *gin.Context) {
+	var in apiKeyPatchInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	set := []string{}
+	args := []interface{}{}
+	add := func(col string, v interface{}) {
+		args = append(args, v)
+		set = append(set, col+"$"+strconv.Itoa(len(args)))
+	}
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+		add("name=", name)
+	}
+	if in.Scopes != nil {
+		if msg := checkAPIKeyScopes(*in.Scopes); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		add("scopes=", *in.Scopes)
+	}
+	if in.ExpiresAt != nil {
+		if *in.ExpiresAt > 0 {
+			args = append(args, *in.ExpiresAt)
+			set = append(set, "expires_at=to_timestamp($"+strconv.Itoa(len(args))+"::bigint)")
+		} else {
+			set = append(set, "expires_at=null")
+		}
+	}
+	if len(set) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
+		return
+	}
+	set = append(set, "updated_at=now()")
+	args = append(args, c.Param("id"))
+	k, err := scanAPIKey(h.db(c).QueryRow(context.Background(), "update api_keys set "+strings.Join(set, ",")+" where id=$"+strconv.Itoa(len(args))+" returning "+apiKeyColumns, args...))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.ForgetAPIKeys()
+	c.JSON(http.StatusOK, k)
+}
+
+// RotateAPIKey replaces the secret of a key, keeping its id, name and scopes. The old key stops
+// working immediately on this instance and within a short cache window on the others.
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	k, err := scanAPIKey(h.db(c).QueryRow(context.Background(), `update api_keys set key_hash=$2,key_prefix=$3,updated_at=now() where id=$1 and not revoked returning `+apiKeyColumns,
+		c.Param("id"), middleware.HashAPIKey(key), prefix))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	k.Key = &key
+	middleware.ForgetAPIKeys()
+	c.JSON(http.StatusOK, k)
+}
+
+// RevokeAPIKey permanently disables a key. Revoking a revoked key is a no-op.
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	k, err := scanAPIKey(h.db(c).QueryRow(context.Background(), `update api_keys set revoked=true,revoked_at=coalesce(revoked_at,now()),updated_at=now() where id=$1 returning `+apiKeyColumns, c.Param("id")))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.ForgetAPIKeys()
+	c.JSON(http.StatusOK, k)
+}
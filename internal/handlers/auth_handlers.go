@@ -4,22 +4,32 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 
+	"guangfu250923/internal/lineauth"
+	"guangfu250923/internal/middleware"
+	"guangfu250923/internal/models"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 // contract:
 // - GET /auth/line/start?state=<frontend_state>&redirect_uri=<backend_redirect>
-//   returns 302 to LINE authorize URL with signed state (JWT-like compact JWS, HMAC-SHA256)
-// - POST /auth/line/token { code, state } -> exchanges code for access_token at LINE, validates state signature, returns tokens
+//   returns 302 to LINE authorize URL with signed state (JWT-like compact JWS, HMAC-SHA256) carrying the nonce
+// - POST /auth/line/token { code, state } -> exchanges code for tokens at LINE, validates state signature,
+//   verifies the id_token, upserts the user and returns LINE's tokens plus our own session_token
+// - GET /auth/me, POST /auth/logout -> the signed-in user (Authorization: Bearer <session_token>)
 
 // simple JWT-like compact token: base64url(header).base64url(payload).base64url(hmac)
 // header is fixed: {"alg":"HS256","typ":"JWT"}
@@ -27,9 +37,36 @@ var headerB64 = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ
 
 type lineStatePayload struct {
 	FrontendState string `json:"fs"`
+	Nonce         string `json:"n,omitempty"`
 	Exp           int64  `json:"exp"`
 }
 
+// lineURL returns the LINE endpoint configured in env, or def. Pointing these at cmd/fake_line
+// allows logging in locally without a LINE channel.
+func lineURL(env, def string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return def
+}
+
+var lineVerifier struct {
+	once sync.Once
+	v    *lineauth.Verifier
+}
+
+func lineIDTokenVerifier() *lineauth.Verifier {
+	lineVerifier.once.Do(func() {
+		lineVerifier.v = lineauth.NewVerifier(lineauth.Options{
+			ChannelID:     os.Getenv("LINE_CHANNEL_ID"),
+			ChannelSecret: os.Getenv("LINE_CHANNEL_SECRET"),
+			Issuer:        os.Getenv("LINE_ISSUER"),
+			JWKSURL:       os.Getenv("LINE_JWKS_URL"),
+		})
+	})
+	return lineVerifier.v
+}
+
 func (h *Handler) signState(p lineStatePayload) (string, error) {
 	if os.Getenv("LINE_JWT_STATE_SECRET") == "" {
 		return "", errors.New("missing JWT secret")
@@ -89,7 +126,13 @@ func (h *Handler) StartLineAuth(c *gin.Context) {
 	}
 	frontendState := c.Query("state")
 	exp := time.Now().Add(10 * time.Minute).Unix()
-	tok, err := h.signState(lineStatePayload{FrontendState: frontendState, Exp: exp})
+	nb := make([]byte, 16)
+	if _, err := rand.Read(nb); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	nonce := hex.EncodeToString(nb)
+	tok, err := h.signState(lineStatePayload{FrontendState: frontendState, Nonce: nonce, Exp: exp})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -105,8 +148,9 @@ func (h *Handler) StartLineAuth(c *gin.Context) {
 		v.Set("redirect_uri", os.Getenv("LINE_REDIRECT_URI"))
 	}
 	v.Set("state", tok)
+	v.Set("nonce", nonce)
 	v.Set("scope", "profile openid email")
-	authURL := "https://access.line.me/oauth2/v2.1/authorize?" + v.Encode()
+	authURL := lineURL("LINE_AUTHORIZE_URL", lineauth.DefaultAuthorizeURL) + "?" + v.Encode()
 	c.Redirect(http.StatusFound, authURL)
 }
 
@@ -125,7 +169,25 @@ type lineTokenResp struct {
 	TokenType    string `json:"token_type"`
 }
 
-// ExchangeLineToken validates state and exchanges code for tokens via LINE API.
+type lineLoginResp struct {
+	lineTokenResp
+	SessionToken     string      `json:"session_token"`
+	SessionExpiresAt int64       `json:"session_expires_at"`
+	User             models.User `json:"user"`
+}
+
+const userColumns = `id,line_sub,display_name,picture_url,email,extract(epoch from last_login_at)::bigint,
+	extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+func scanUser(row pgx.Row) (models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.LineSub, &u.DisplayName, &u.PictureURL, &u.Email, &u.LastLoginAt, &u.CreatedAt, &u.UpdatedAt)
+	return u, err
+}
+
+// ExchangeLineToken validates state, exchanges code for tokens via LINE API and verifies the
+// id_token (signature, aud, nonce, exp). The LINE user is upserted into users and a session
+// started; the response is LINE's token response plus session_token and the user.
 func (h *Handler) ExchangeLineToken(c *gin.Context) {
 	if os.Getenv("LINE_CHANNEL_ID") == "" || os.Getenv("LINE_CHANNEL_SECRET") == "" || os.Getenv("LINE_REDIRECT_URI") == "" {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "LINE config missing"})
@@ -140,7 +202,8 @@ func (h *Handler) ExchangeLineToken(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
 		return
 	}
-	if _, err := h.verifyState(in.State); err != nil {
+	st, err := h.verifyState(in.State)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid state: " + err.Error()})
 		return
 	}
@@ -156,7 +219,7 @@ func (h *Handler) ExchangeLineToken(c *gin.Context) {
 	form.Set("client_id", os.Getenv("LINE_CHANNEL_ID"))
 	form.Set("client_secret", os.Getenv("LINE_CHANNEL_SECRET"))
 
-	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "https://api.line.me/oauth2/v2.1/token", bytes.NewBufferString(form.Encode()))
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, lineURL("LINE_TOKEN_URL", lineauth.DefaultTokenURL), bytes.NewBufferString(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -175,6 +238,64 @@ func (h *Handler) ExchangeLineToken(c *gin.Context) {
 		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
 		return
 	}
-	// Return raw tokens to frontend to continue its flow.
-	c.JSON(http.StatusOK, out)
+	claims, err := lineIDTokenVerifier().Verify(out.IDToken, st.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid id_token: " + err.Error()})
+		return
+	}
+
+	ctx := context.Background()
+	user, err := scanUser(h.db(c).QueryRow(ctx, `insert into users(line_sub,display_name,picture_url,email,last_login_at) values($1,$2,$3,$4,now())
+		on conflict (line_sub) do update set display_name=coalesce(excluded.display_name,users.display_name),picture_url=coalesce(excluded.picture_url,users.picture_url),
+		email=coalesce(excluded.email,users.email),last_login_at=now(),updated_at=now()
+		returning `+userColumns, claims.Subject, nilIfEmpty(claims.Name), nilIfEmpty(claims.Picture), nilIfEmpty(claims.Email)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	expires := time.Now().Add(middleware.SessionTTL())
+	var sessionID string
+	if err := h.db(c).QueryRow(ctx, `insert into user_sessions(user_id,ip,user_agent,expires_at) values($1,$2,$3,$4) returning id`,
+		user.ID, middleware.ClientIP(c), nilIfEmpty(c.Request.UserAgent()), expires).Scan(&sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sessionToken, err := middleware.IssueSessionToken(sessionID, user.ID, expires)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Return LINE's tokens too so the frontend can keep calling LINE APIs.
+	c.JSON(http.StatusOK, lineLoginResp{lineTokenResp: out, SessionToken: sessionToken, SessionExpiresAt: expires.Unix(), User: user})
+}
+
+// GetMe returns the signed-in user.
+func (h *Handler) GetMe(c *gin.Context) {
+	u, err := scanUser(h.db(c).QueryRow(context.Background(), "select "+userColumns+" from users where id=$1", middleware.CurrentUser(c).ID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	roles, err := h.loadUserRoles(c, []string{u.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	u.Roles = roles[u.ID]
+	c.JSON(http.StatusOK, u)
+}
+
+// Logout revokes the session the request was made with.
+func (h *Handler) Logout(c *gin.Context) {
+	sid := middleware.CurrentUser(c).SessionID
+	if _, err := h.db(c).Exec(context.Background(), `update user_sessions set revoked_at=now() where id=$1 and revoked_at is null`, sid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.afterCommit(c, func() { middleware.ForgetSession(sid) })
+	c.Status(http.StatusNoContent)
 }
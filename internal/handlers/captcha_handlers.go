@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+
+	"guangfu250923/internal/turnstile"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CaptchaMetrics returns the captcha verification counters of this instance since it started,
+// per provider. Counters live in memory, so every instance reports its own.
+func (h *Handler) CaptchaMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": turnstile.Snapshot()})
+}
@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const catalogCategoryColumns = `c.id,c.name,c.name_en,c.aliases,c.sort_order,(select count(*) from catalog_items i where i.category_id=c.id)::int,
+	extract(epoch from c.created_at)::bigint,extract(epoch from c.updated_at)::bigint`
+
+func scanCatalogCategory(row pgx.Row) (models.CatalogCategory, error) {
+	var cc models.CatalogCategory
+	err := row.Scan(&cc.ID, &cc.Name, &cc.NameEn, &cc.Aliases, &cc.SortOrder, &cc.ItemCount, &cc.CreatedAt, &cc.UpdatedAt)
+	return cc, err
+}
+
+const catalogItemColumns = `i.id,i.category_id,i.name,i.name_en,i.aliases,i.default_unit,extract(epoch from i.created_at)::bigint,extract(epoch from i.updated_at)::bigint`
+
+func scanCatalogItem(row pgx.Row) (models.CatalogItem, error) {
+	var ci models.CatalogItem
+	err := row.Scan(&ci.ID, &ci.CategoryID, &ci.Name, &ci.NameEn, &ci.Aliases, &ci.DefaultUnit, &ci.CreatedAt, &ci.UpdatedAt)
+	return ci, err
+}
+
+// checkCatalogRefs answers 400 and reports false when a category_id or catalog_item_id given on a
+// supply item or requirement isn't in the catalog. Empty strings clear the mapping and pass.
+func (h *Handler) checkCatalogRefs(c *gin.Context, categoryID, catalogItemID *string) bool {
+	ctx := context.Background()
+	if categoryID != nil && *categoryID != "" {
+		var ok bool
+		if err := h.db(c).QueryRow(ctx, `select exists(select 1 from catalog_categories where id=$1)`, *categoryID).Scan(&ok); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown category_id, see GET /catalog/categories", "category_id": *categoryID})
+			return false
+		}
+	}
+	if catalogItemID != nil && *catalogItemID != "" {
+		var ok bool
+		if err := h.db(c).QueryRow(ctx, `select exists(select 1 from catalog_items where id=$1)`, *catalogItemID).Scan(&ok); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown catalog_item_id, see GET /catalog/items", "catalog_item_id": *catalogItemID})
+			return false
+		}
+	}
+	return true
+}
+
+// ListCatalogCategories lists every category of the item catalog in display order.
+func (h *Handler) ListCatalogCategories(c *gin.Context) {
+	rows, err := h.db(c).Query(context.Background(), `select `+catalogCategoryColumns+` from catalog_categories c order by c.sort_order, c.id`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []models.CatalogCategory{}
+	for rows.Next() {
+		cc, err := scanCatalogCategory(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, cc)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": len(list), "member": list})
+}
+
+// ListCatalogItems lists catalog items by category_id and q, a part of their name, English name or
+// an alias; exact spellings come first.
+func (h *Handler) ListCatalogItems(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 100, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := context.Background()
+	filters := []string{}
+	args := []interface{}{}
+	if v := c.Query("category_id"); v != "" {
+		filters = append(filters, "i.category_id=$"+strconv.Itoa(len(args)+1))
+		args = append(args, v)
+	}
+	order := "i.category_id, i.name"
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		n := strconv.Itoa(len(args) + 1)
+		filters = append(filters, "(strpos(lower(i.name),lower($"+n+"))>0 or strpos(lower(coalesce(i.name_en,'')),lower($"+n+"))>0 or exists(select 1 from unnest(i.aliases) a where strpos(lower(a),lower($"+n+"))>0))")
+		order = "i.id=resolve_catalog_item($" + n + ") desc, " + order
+		args = append(args, q)
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, `select count(*) from catalog_items i`+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	args = append(args, limit, offset)
+	rows, err := h.db(c).Query(ctx, `select `+catalogItemColumns+` from catalog_items i`+where+` order by `+order+` limit $`+strconv.Itoa(len(args)-1)+` offset $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []models.CatalogItem{}
+	for rows.Next() {
+		ci, err := scanCatalogItem(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, ci)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prev *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		s := build(max(offset-limit, 0))
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+func (h *Handler) GetCatalogItem(c *gin.Context) {
+	ci, err := scanCatalogItem(h.db(c).QueryRow(context.Background(), `select `+catalogItemColumns+` from catalog_items i where i.id=$1`, c.Param("id")))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ci)
+}
+
+// catalogSuggestion is a catalog entry proposed for a free-text tag or name, with how well it
+// matches (1 for one of its spellings).
+type catalogSuggestion struct {
+	Kind       string  `json:"kind"` // category or item
+	ID         string  `json:"id"`
+	CategoryID string  `json:"category_id"`
+	Name       string  `json:"name"`
+	NameEn     *string `json:"name_en"`
+	Score      float64 `json:"score"`
+}
+
+// spellingScore is how well q matches the best of spellings, scored like item names in matching.
+func spellingScore(q string, spellings ...string) float64 {
+	best := 0.0
+	for _, s := range spellings {
+		best = math.Max(best, nameSimilarity(q, s))
+	}
+	return math.Round(best*1000) / 1000
+}
+
+// SuggestCatalog proposes catalog categories and items for free text (GET /catalog/suggest?q=),
+// best first, for forms to offer while a tag or name is typed and for mapping unknown values.
+// The catalog is small, so every entry is scored in memory.
+func (h *Handler) SuggestCatalog(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit := parsePositiveInt(c.Query("limit"), 5, 1, 50)
+	ctx := context.Background()
+	list := []catalogSuggestion{}
+	rows, err := h.db(c).Query(ctx, `select c.id,c.name,c.name_en,c.aliases from catalog_categories c`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for rows.Next() {
+		var s catalogSuggestion
+		var aliases []string
+		if err := rows.Scan(&s.ID, &s.Name, &s.NameEn, &aliases); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		s.Kind, s.CategoryID = "category", s.ID
+		spellings := append([]string{s.ID, s.Name}, aliases...)
+		if s.NameEn != nil {
+			spellings = append(spellings, *s.NameEn)
+		}
+		if s.Score = spellingScore(q, spellings...); s.Score >= matchMinNameScore {
+			list = append(list, s)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rows, err = h.db(c).Query(ctx, `select i.id,i.category_id,i.name,i.name_en,i.aliases from catalog_items i`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s catalogSuggestion
+		var aliases []string
+		if err := rows.Scan(&s.ID, &s.CategoryID, &s.Name, &s.NameEn, &aliases); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		s.Kind = "item"
+		spellings := append([]string{s.Name}, aliases...)
+		if s.NameEn != nil {
+			spellings = append(spellings, *s.NameEn)
+		}
+		if s.Score = spellingScore(q, spellings...); s.Score >= matchMinNameScore {
+			list = append(list, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].Name < list[j].Name
+	})
+	c.JSON(http.StatusOK, gin.H{"q": q, "member": list[:min(limit, len(list))]})
+}
+
+type catalogCategoryInput struct {
+	ID        string   `json:"id" binding:"required"`
+	Name      string   `json:"name" binding:"required"`
+	NameEn    *string  `json:"name_en"`
+	Aliases   []string `json:"aliases"`
+	SortOrder *int     `json:"sort_order"`
+}
+
+// catalogSpellingTaken returns the other entry of table (catalog_categories or catalog_items) that
+// already answers to one of spellings, or "" when none does.
+func (h *Handler) catalogSpellingTaken(c *gin.Context, table, id string, spellings []string) (string, error) {
+	cond := "lower(name)=any($2) or lower(coalesce(name_en,''))=any($2) or exists(select 1 from unnest(aliases) a where lower(a)=any($2))"
+	if table == "catalog_categories" {
+		cond = "lower(id)=any($2) or " + cond
+	}
+	var taken string
+	err := h.db(c).QueryRow(context.Background(), `select id from `+table+` where id<>$1 and (`+cond+`) limit 1`, id, spellings).Scan(&taken)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	return taken, err
+}
+
+// catalogSpellings trims aliases, drops empty and repeated ones, and returns them with every
+// spelling of the entry lower-cased for the conflict check.
+func catalogSpellings(names []string, aliases []string) ([]string, []string) {
+	spellings := []string{}
+	for _, n := range names {
+		if n = strings.ToLower(strings.TrimSpace(n)); n != "" {
+			spellings = append(spellings, n)
+		}
+	}
+	out := []string{}
+	for _, a := range aliases {
+		a = strings.TrimSpace(a)
+		if a == "" || slices.Contains(spellings, strings.ToLower(a)) {
+			continue
+		}
+		out = append(out, a)
+		spellings = append(spellings, strings.ToLower(a))
+	}
+	return out, spellings
+}
+
+// PutCatalogCategory adds a category or replaces it (POST /_admin/catalog/categories), keyed by
+// its id, a short English slug ("food"). Its spellings may not be another category's.
+func (h *Handler) PutCatalogCategory(c *gin.Context) {
+	var in catalogCategoryInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	in.ID, in.Name = strings.ToLower(strings.TrimSpace(in.ID)), strings.TrimSpace(in.Name)
+	if in.ID == "" || in.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id and name are required"})
+		return
+	}
+	names := []string{in.ID, in.Name}
+	if in.NameEn != nil {
+		names = append(names, *in.NameEn)
+	}
+	aliases, spellings := catalogSpellings(names, in.Aliases)
+	taken, err := h.catalogSpellingTaken(c, "catalog_categories", in.ID, spellings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if taken != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "spelling already belongs to another category", "category_id": taken})
+		return
+	}
+	ctx := context.Background()
+	var existed bool
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from catalog_categories where id=$1)`, in.ID).Scan(&existed); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sortOrder := 0
+	if in.SortOrder != nil {
+		sortOrder = *in.SortOrder
+	}
+	if _, err := h.db(c).Exec(ctx, `insert into catalog_categories(id,name,name_en,aliases,sort_order) values($1,$2,$3,$4,$5)
+		on conflict (id) do update set name=excluded.name,name_en=excluded.name_en,aliases=excluded.aliases,sort_order=excluded.sort_order,updated_at=now()`,
+		in.ID, in.Name, in.NameEn, aliases, sortOrder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cc, err := scanCatalogCategory(h.db(c).QueryRow(ctx, `select `+catalogCategoryColumns+` from catalog_categories c where c.id=$1`, in.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	c.JSON(status, cc)
+}
+
+// DeleteCatalogCategory removes an empty category; supply items and requirements mapped to it
+// become unmapped.
+func (h *Handler) DeleteCatalogCategory(c *gin.Context) {
+	id := c.Param("id")
+	ctx := context.Background()
+	var items int
+	if err := h.db(c).QueryRow(ctx, `select count(*) from catalog_items where category_id=$1`, id).Scan(&items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if items > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "the category still has catalog items", "item_count": items})
+		return
+	}
+	tag, err := h.db(c).Exec(ctx, `delete from catalog_categories where id=$1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type catalogItemInput struct {
+	CategoryID  *string  `json:"category_id"`
+	Name        *string  `json:"name"`
+	NameEn      *string  `json:"name_en"`
+	Aliases     []string `json:"aliases"`
+	DefaultUnit *string  `json:"default_unit"`
+}
+
+// saveCatalogItem checks in against the catalog and writes it: a new item when id is "", else the
+// changes to item id. It answers the request itself.
+func (h *Handler) saveCatalogItem(c *gin.Context, id string, in catalogItemInput) {
+	ctx := context.Background()
+	cur := models.CatalogItem{Aliases: []string{}}
+	if id != "" {
+		var err error
+		if cur, err = scanCatalogItem(h.db(c).QueryRow(ctx, `select `+catalogItemColumns+` from catalog_items i where i.id=$1 for update`, id)); err != nil {
+			if err == pgx.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if in.CategoryID != nil {
+		cur.CategoryID = *in.CategoryID
+	}
+	if in.Name != nil {
+		cur.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.NameEn != nil {
+		cur.NameEn = in.NameEn
+	}
+	if in.Aliases != nil {
+		cur.Aliases = in.Aliases
+	}
+	if in.DefaultUnit != nil {
+		cur.DefaultUnit = in.DefaultUnit
+	}
+	if cur.Name == "" || cur.CategoryID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and category_id are required"})
+		return
+	}
+	if !h.checkCatalogRefs(c, &cur.CategoryID, nil) {
+		return
+	}
+	names := []string{cur.Name}
+	if cur.NameEn != nil {
+		names = append(names, *cur.NameEn)
+	}
+	aliases, spellings := catalogSpellings(names, cur.Aliases)
+	taken, err := h.catalogSpellingTaken(c, "catalog_items", id, spellings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if taken != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "spelling already belongs to another catalog item", "catalog_item_id": taken})
+		return
+	}
+	if id == "" {
+		ci, err := scanCatalogItem(h.db(c).QueryRow(ctx, `insert into catalog_items as i(category_id,name,name_en,aliases,default_unit) values($1,$2,$3,$4,$5) returning `+catalogItemColumns,
+			cur.CategoryID, cur.Name, cur.NameEn, aliases, cur.DefaultUnit))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, ci)
+		return
+	}
+	ci, err := scanCatalogItem(h.db(c).QueryRow(ctx, `update catalog_items i set category_id=$1,name=$2,name_en=$3,aliases=$4,default_unit=$5,updated_at=now() where i.id=$6 returning `+catalogItemColumns,
+		cur.CategoryID, cur.Name, cur.NameEn, aliases, cur.DefaultUnit, id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ci)
+}
+
+// CreateCatalogItem adds a canonical item (POST /_admin/catalog/items). Its name, English name and
+// aliases may not be another item's.
+func (h *Handler) CreateCatalogItem(c *gin.Context) {
+	var in catalogItemInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.saveCatalogItem(c, "", in)
+}
+
+func (h *Handler) PatchCatalogItem(c *gin.Context) {
+	var in catalogItemInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.saveCatalogItem(c, c.Param("id"), in)
+}
+
+// DeleteCatalogItem removes a catalog item; supply items and requirements mapped to it become
+// unmapped (their category stays).
+func (h *Handler) DeleteCatalogItem(c *gin.Context) {
+	tag, err := h.db(c).Exec(context.Background(), `delete from catalog_items where id=$1`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
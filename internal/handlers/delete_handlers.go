@@ -3,24 +3,171 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/middleware"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
+// DELETE endpoints soft-delete: they set deleted_at and the row disappears from every read
+// endpoint. POST /{resource}/:id/restore brings it back until the retention purge
+// (db.PurgeDeleted) removes it for good.
+
+type softDeleteChild struct {
+	table string
+	fk    string
+}
+
+// softDeleteChildren mirrors the on delete cascade foreign keys (and pledges, which belong to
+// their supply item): these rows are deleted and restored together with their parent, and
+// their own children with them.
+var softDeleteChildren = map[string][]softDeleteChild{
+	"supplies":     {{"supply_items", "supply_id"}},
+	"supply_items": {{"supply_providers", "supply_item_id"}},
+	"places":       {{"requirements_hr", "place_id"}, {"requirements_supplies", "place_id"}},
+}
+
+// softDeleteParent is the reverse of softDeleteChildren: a child can't be restored while its
+// parent is deleted.
+var softDeleteParent = map[string]softDeleteChild{
+	"supply_items":          {"supplies", "supply_id"},
+	"supply_providers":      {"supply_items", "supply_item_id"},
+	"requirements_hr":       {"places", "place_id"},
+	"requirements_supplies": {"places", "place_id"},
+}
+
+// includeDeleted reports whether soft-deleted rows should be returned as well: only for
+// ?include_deleted=true from a caller allowed <resource>:audit (viewers, moderators, write keys).
+func includeDeleted(c *gin.Context) bool {
+	if c.Query("include_deleted") != "true" {
+		return false
+	}
+	res := strings.Split(strings.TrimPrefix(c.FullPath(), "/"), "/")[0]
+	return middleware.Can(c, res+":audit")
+}
+
+// liveCond is the " and deleted_at is null" suffix for single-row reads (empty when the caller
+// may see deleted rows).
+func liveCond(c *gin.Context) string {
+	if includeDeleted(c) {
+		return ""
+	}
+	return " and deleted_at is null"
+}
+
+// appendLiveFilter adds the deleted_at filter to a list query's filters.
+func appendLiveFilter(c *gin.Context, filters []string) []string {
+	if includeDeleted(c) {
+		return filters
+	}
+	return append(filters, "deleted_at is null")
+}
+
 func deleteByID(c *gin.Context, h *Handler, table string) {
 	id := c.Param("id")
-	tag, err := h.pool.Exec(context.Background(), "delete from "+table+" where id=$1", id)
+	ctx := context.Background()
+	var deletedAt time.Time
+	err := h.db(c).QueryRow(ctx, "update "+table+" set deleted_at=now() where id=$1 and deleted_at is null returning deleted_at", id).Scan(&deletedAt)
+	if err == pgx.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if tag.RowsAffected() == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	// children share the parent's deleted_at so restore can tell them from rows deleted on their own
+	if _, err := h.softDeleteTree(c, table, "$1", id, deletedAt, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
 
+// softDeleteTree deletes (or, with restore, restores) the children of the table rows parents
+// selects ($1 is the top row's id, $2 its deleted_at), down to their own children. Restored are
+// only the rows that share the top row's deleted_at, i.e. were deleted with it. It returns how
+// many rows changed.
+func (h *Handler) softDeleteTree(c *gin.Context, table, parents, id string, deletedAt time.Time, restore bool) (int64, error) {
+	ctx := context.Background()
+	var n int64
+	for _, ch := range softDeleteChildren[table] {
+		if restore {
+			// grandchildren first, while the children still carry deleted_at
+			k, err := h.softDeleteTree(c, ch.table, "select id from "+ch.table+" where "+ch.fk+" in ("+parents+") and deleted_at=$2", id, deletedAt, true)
+			if err != nil {
+				return n, err
+			}
+			n += k
+			tag, err := h.db(c).Exec(ctx, "update "+ch.table+" set deleted_at=null where "+ch.fk+" in ("+parents+") and deleted_at=$2", id, deletedAt)
+			if err != nil {
+				return n, err
+			}
+			n += tag.RowsAffected()
+			continue
+		}
+		tag, err := h.db(c).Exec(ctx, "update "+ch.table+" set deleted_at=$2 where "+ch.fk+" in ("+parents+") and deleted_at is null", id, deletedAt)
+		if err != nil {
+			return n, err
+		}
+		n += tag.RowsAffected()
+		k, err := h.softDeleteTree(c, ch.table, "select id from "+ch.table+" where "+ch.fk+" in ("+parents+") and deleted_at=$2", id, deletedAt, false)
+		if err != nil {
+			return n, err
+		}
+		n += k
+	}
+	return n, nil
+}
+
+// RestoreEntity undoes a soft delete, together with the child rows that were deleted with it.
+func (h *Handler) RestoreEntity(c *gin.Context) {
+	res, table, ok := historyResource(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	id := c.Param("id")
+	ctx := context.Background()
+	var deletedAt *time.Time
+	if err := h.db(c).QueryRow(ctx, "select deleted_at from "+table+" where id=$1 for update", id).Scan(&deletedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if deletedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "not deleted"})
+		return
+	}
+	if p, ok := softDeleteParent[table]; ok {
+		var parentDeleted bool
+		if err := h.db(c).QueryRow(ctx, "select exists(select 1 from "+p.table+" where id=(select "+p.fk+" from "+table+" where id=$1) and deleted_at is not null)", id).Scan(&parentDeleted); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if parentDeleted {
+			c.JSON(http.StatusConflict, gin.H{"error": "parent " + p.table + " is deleted; restore it first"})
+			return
+		}
+	}
+	if _, err := h.db(c).Exec(ctx, "update "+table+" set deleted_at=null where id=$1", id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	children, err := h.softDeleteTree(c, table, "$1", id, *deletedAt, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"resource": res, "id": id, "restored": true, "restored_children": children})
+}
+
 func (h *Handler) DeleteShelter(c *gin.Context)        { deleteByID(c, h, "shelters") }
 func (h *Handler) DeleteMedicalStation(c *gin.Context) { deleteByID(c, h, "medical_stations") }
 func (h *Handler) DeleteMentalHealthResource(c *gin.Context) {
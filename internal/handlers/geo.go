@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// coordPointExpr must stay byte-for-byte identical to the expression used by the
+// idx_<table>_geo gist indexes (0001_baseline migration), otherwise the planner won't use them.
+const coordPointExpr = "point((coordinates->>'lng')::double precision,(coordinates->>'lat')::double precision)"
+
+const (
+	defaultRadiusM = 5000
+	maxRadiusM     = 50000
+	metersPerDeg   = 111320.0
+)
+
+// geoQuery holds the optional spatial parameters of a list request:
+// "near me" (?lat=&lng=&radius_m=) and/or a map viewport (?bbox=minLng,minLat,maxLng,maxLat).
+type geoQuery struct {
+	near    bool
+	lat     float64
+	lng     float64
+	radiusM float64
+	bbox    []float64
+	// placeholder indexes of lat/lng once apply() has run
+	latIdx, lngIdx int
+}
+
+// parseGeoQuery reads lat/lng/radius_m and bbox from the query string. Both lat and lng must be
+// given for a radius search; radius_m defaults to 5km and is capped at 50km.
+func parseGeoQuery(c *gin.Context) (*geoQuery, error) {
+	g := &geoQuery{}
+	if raw := c.Query("bbox"); raw != "" {
+		bbox, err := parseBBox(raw)
+		if err != nil {
+			return nil, err
+		}
+		g.bbox = bbox
+	}
+	latRaw, lngRaw := c.Query("lat"), c.Query("lng")
+	if latRaw == "" && lngRaw == "" {
+		if c.Query("radius_m") != "" {
+			return nil, errors.New("radius_m requires lat and lng")
+		}
+		return g, nil
+	}
+	lat, err := strconv.ParseFloat(latRaw, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, errors.New("invalid lat")
+	}
+	lng, err := strconv.ParseFloat(lngRaw, 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return nil, errors.New("invalid lng")
+	}
+	radius := float64(defaultRadiusM)
+	if raw := c.Query("radius_m"); raw != "" {
+		r, err := strconv.ParseFloat(raw, 64)
+		if err != nil || r <= 0 {
+			return nil, errors.New("invalid radius_m")
+		}
+		radius = math.Min(r, maxRadiusM)
+	}
+	g.near, g.lat, g.lng, g.radiusM = true, lat, lng, radius
+	return g, nil
+}
+
+// parseBBox parses "minLng,minLat,maxLng,maxLat" (the GeoJSON bbox order).
+// Boxes crossing the antimeridian are not supported.
+func parseBBox(raw string) ([]float64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("invalid bbox")
+	}
+	v := make([]float64, 4)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, errors.New("invalid bbox")
+		}
+		v[i] = f
+	}
+	if v[0] < -180 || v[2] > 180 || v[1] < -90 || v[3] > 90 || v[0] > v[2] || v[1] > v[3] {
+		return nil, errors.New("invalid bbox")
+	}
+	return v, nil
+}
+
+// apply appends the geo filters to an existing filters/args pair.
+// The radius search is a bounding box test (served by the gist index) followed by an exact
+// great-circle distance check on the remaining rows.
+func (g *geoQuery) apply(filters []string, args []interface{}) ([]string, []interface{}) {
+	if g.bbox != nil {
+		filters, args = appendBoxFilter(filters, args, g.bbox[0], g.bbox[1], g.bbox[2], g.bbox[3])
+	}
+	if !g.near {
+		return filters, args
+	}
+	dLat := g.radiusM / metersPerDeg
+	dLng := g.radiusM / (metersPerDeg * math.Max(math.Cos(g.lat*math.Pi/180), 0.01))
+	filters, args = appendBoxFilter(filters, args, g.lng-dLng, g.lat-dLat, g.lng+dLng, g.lat+dLat)
+	n := len(args)
+	g.latIdx, g.lngIdx = n+1, n+2
+	args = append(args, g.lat, g.lng, g.radiusM)
+	filters = append(filters, g.distanceExpr()+" <= $"+strconv.Itoa(n+3))
+	return filters, args
+}
+
+// appendBoxFilter adds an index-backed "coordinates inside box" filter.
+func appendBoxFilter(filters []string, args []interface{}, minLng, minLat, maxLng, maxLat float64) ([]string, []interface{}) {
+	n := len(args)
+	filters = append(filters, coordPointExpr+" <@ box(point($"+strconv.Itoa(n+1)+",$"+strconv.Itoa(n+2)+"),point($"+strconv.Itoa(n+3)+",$"+strconv.Itoa(n+4)+"))")
+	args = append(args, minLng, minLat, maxLng, maxLat)
+	return filters, args
+}
+
+// distanceExpr is the haversine distance in meters between the row and the requested point.
+func (g *geoQuery) distanceExpr() string {
+	lat := "(coordinates->>'lat')::double precision"
+	lng := "(coordinates->>'lng')::double precision"
+	pLat := "$" + strconv.Itoa(g.latIdx) + "::double precision"
+	pLng := "$" + strconv.Itoa(g.lngIdx) + "::double precision"
+	return "(12742000*asin(least(1,sqrt(power(sin(radians(" + lat + "-" + pLat + ")/2),2)+cos(radians(" + pLat + "))*cos(radians(" + lat + "))*power(sin(radians(" + lng + "-" + pLng + ")/2),2)))))"
+}
+
+// haversineM is the great-circle distance in meters between two points, as distanceExpr computes it.
+func haversineM(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	h := math.Pow(math.Sin((lat2-lat1)*rad/2), 2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Pow(math.Sin((lng2-lng1)*rad/2), 2)
+	return 12742000 * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// distanceColumn is the distance_m select expression (null when not searching by radius).
+func (g *geoQuery) distanceColumn() string {
+	if !g.near {
+		return "null::double precision as distance_m"
+	}
+	return "round(" + g.distanceExpr() + "::numeric,1)::double precision as distance_m"
+}
+
+// orderBy returns the ORDER BY expression: nearest first for radius searches, def otherwise.
+func (g *geoQuery) orderBy(def string) string {
+	if g.near {
+		return "distance_m asc, " + def
+	}
+	return def
+}
+
+// wantsGeoJSON reports whether the client asked for a GeoJSON FeatureCollection
+// (?format=geojson or Accept: application/geo+json).
+func wantsGeoJSON(c *gin.Context) bool {
+	if f := c.Query("format"); f != "" {
+		return strings.EqualFold(f, "geojson")
+	}
+	return strings.Contains(c.GetHeader("Accept"), "application/geo+json")
+}
+
+// writeLocationCollection writes a list page of location-bearing resources either as the
+// usual JSON-LD Collection or, when requested, as a GeoJSON FeatureCollection.
+func writeLocationCollection(c *gin.Context, list interface{}, total, limit, offset int, next, prev *string) {
+	if !wantsGeoJSON(c) {
+		c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+		return
+	}
+	writeFeatureCollection(c, list, gin.H{"totalItems": total, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+// writeFeatureCollection writes list as a GeoJSON FeatureCollection. Paging fields are passed
+// in paging and kept as foreign members so map clients can still page.
+func writeFeatureCollection(c *gin.Context, list interface{}, paging gin.H) {
+	features, err := toFeatures(list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := gin.H{"type": "FeatureCollection", "features": features}
+	for k, v := range paging {
+		out[k] = v
+	}
+	body, err := json.Marshal(out)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/geo+json; charset=utf-8", body)
+}
+
+// geoFeature is a GeoJSON Feature with a Point geometry (or null when the row has no coordinates).
+type geoFeature struct {
+	Type       string                     `json:"type"`
+	ID         json.RawMessage            `json:"id,omitempty"`
+	Geometry   *geoPoint                  `json:"geometry"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+type geoPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// toFeatures converts a slice of models into GeoJSON features. It goes through the JSON
+// encoding so every resource keeps exactly the property names of its regular representation;
+// "coordinates" becomes the geometry and everything else ends up in properties.
+func toFeatures(list interface{}) ([]geoFeature, error) {
+	raw, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	features := make([]geoFeature, 0, len(items))
+	for _, props := range items {
+		f := geoFeature{Type: "Feature", ID: props["id"], Properties: props}
+		if rc, ok := props["coordinates"]; ok {
+			var pt struct {
+				Lat *float64 `json:"lat"`
+				Lng *float64 `json:"lng"`
+			}
+			if json.Unmarshal(rc, &pt) == nil && pt.Lat != nil && pt.Lng != nil {
+				f.Geometry = &geoPoint{Type: "Point", Coordinates: [2]float64{*pt.Lng, *pt.Lat}}
+			}
+			delete(props, "coordinates")
+		}
+		features = append(features, f)
+	}
+	return features, nil
+}
@@ -1,9 +1,44 @@
 package handlers
 
-import "github.com/jackc/pgx/v5/pgxpool"
+import (
+	"context"
+	"os"
+
+	"guangfu250923/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
 
 type Handler struct {
 	pool *pgxpool.Pool
+	// legacyFromPlaces is LEGACY_READ_FROM_PLACES, read once at startup (see legacySource).
+	legacyFromPlaces bool
+}
+
+func New(pool *pgxpool.Pool) *Handler {
+	return &Handler{pool: pool, legacyFromPlaces: os.Getenv("LEGACY_READ_FROM_PLACES") == "true"}
 }
 
-func New(pool *pgxpool.Pool) *Handler { return &Handler{pool: pool} }
+// db returns what a handler should run its statements on: the write request's transaction
+// (see middleware.RequestTx) when there is one, the pool otherwise.
+func (h *Handler) db(c *gin.Context) db.DBTX {
+	if v, ok := c.Get(db.RequestTxKey); ok {
+		if rt, ok := v.(*db.RequestTx); ok {
+			return rt.DB(context.Background())
+		}
+	}
+	return h.pool
+}
+
+// afterCommit runs fn once the write request's transaction has committed (right away for
+// requests without one).
+func (h *Handler) afterCommit(c *gin.Context, fn func()) {
+	if v, ok := c.Get(db.RequestTxKey); ok {
+		if rt, ok := v.(*db.RequestTx); ok {
+			rt.AfterCommit(fn)
+			return
+		}
+	}
+	fn()
+}
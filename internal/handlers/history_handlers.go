@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// historyTables maps the resource path segment onto the table whose changes entity_versions
+// records (see migration 0002_entity_versions).
+var historyTables = map[string]string{
+	"volunteer_organizations": "volunteer_organizations",
+	"shelters":                "shelters",
+	"medical_stations":        "medical_stations",
+	"mental_health_resources": "mental_health_resources",
+	"accommodations":          "accommodations",
+	"shower_stations":         "shower_stations",
+	"water_refill_stations":   "water_refill_stations",
+	"restrooms":               "restrooms",
+	"human_resources":         "human_resources",
+	"supplies":                "supplies",
+	"supply_items":            "supply_items",
+	"reports":                 "reports",
+	"spam_results":            "spam_result",
+	"places":                  "places",
+	"requirements_hr":         "requirements_hr",
+	"requirements_supplies":   "requirements_supplies",
+	"supply_providers":        "supply_providers",
+}
+
+// HistoryResources lists the resources that have /{resource}/:id/history, /revert and /restore routes.
+func HistoryResources() []string {
+	list := make([]string, 0, len(historyTables))
+	for r := range historyTables {
+		list = append(list, r)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// historyIgnored are bookkeeping columns left out of field-level diffs.
+var historyIgnored = map[string]bool{"updated_at": true, "last_updated": true}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type HistoryActor struct {
+	IP     *string `json:"ip"`
+	APIKey *string `json:"api_key"`
+}
+
+type EntityVersion struct {
+	Version   int           `json:"version"`
+	Op        string        `json:"op"`
+	Changes   []FieldChange `json:"changes"`
+	Actor     HistoryActor  `json:"actor"`
+	CreatedAt int64         `json:"created_at"`
+}
+
+// historyResource resolves the resource of a /{resource}/:id/... route.
+func historyResource(c *gin.Context) (string, string, bool) {
+	res := strings.Split(strings.TrimPrefix(c.FullPath(), "/"), "/")[0]
+	table, ok := historyTables[res]
+	return res, table, ok
+}
+
+// diffSnapshots lists the fields that differ between two row snapshots (either may be nil).
+func diffSnapshots(prev, cur map[string]interface{}) []FieldChange {
+	fields := map[string]bool{}
+	for k := range prev {
+		fields[k] = true
+	}
+	for k := range cur {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		if !historyIgnored[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	changes := []FieldChange{}
+	for _, k := range names {
+		from, to := prev[k], cur[k]
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: k, From: from, To: to})
+	}
+	return changes
+}
+
+// maskIP keeps the network part of an address (a.b.c.x, first three IPv6 groups) for public history.
+func maskIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "x"
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return strconv.Itoa(int(v4[0])) + "." + strconv.Itoa(int(v4[1])) + "." + strconv.Itoa(int(v4[2])) + ".x"
+	}
+	groups := strings.Split(parsed.String(), ":")
+	if len(groups) > 3 {
+		groups = groups[:3]
+	}
+	return strings.Join(groups, ":") + ":x"
+}
+
+// GetEntityHistory returns the versions of one entity, newest first, each with the field-level
+// changes against the version before it. Actor IPs are masked unless the caller may audit the
+// resource (<resource>:audit).
+func (h *Handler) GetEntityHistory(c *gin.Context) {
+	res, _, ok := historyResource(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	id := c.Param("id")
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := context.Background()
+	var total int
+	if err := h.db(c).QueryRow(ctx, `select count(*) from entity_versions where resource=$1 and entity_id=$2`, res, id).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if total == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	// lag() is evaluated before limit/offset, so the first entry of a page still diffs against
+	// the version just below it.
+	rows, err := h.db(c).Query(ctx, `select version,op,data,lag(data) over (order by version),actor_ip,actor_key,extract(epoch from created_at)::bigint
+		from entity_versions where resource=$1 and entity_id=$2 order by version desc limit $3 offset $4`, res, id, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	showIP := middleware.Can(c, res+":audit")
+	list := []EntityVersion{}
+	for rows.Next() {
+		var v EntityVersion
+		var data, prev map[string]interface{}
+		if err := rows.Scan(&v.Version, &v.Op, &data, &prev, &v.Actor.IP, &v.Actor.APIKey, &v.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		switch {
+		case v.Op == "delete" && data["deleted_at"] == nil:
+			// hard delete (retention purge): data is the row as it was removed
+			v.Changes = diffSnapshots(data, nil)
+		case v.Op == "create" || v.Op == "snapshot":
+			v.Changes = diffSnapshots(nil, data)
+		default:
+			v.Changes = diffSnapshots(prev, data)
+		}
+		if v.Actor.IP != nil && !showIP {
+			m := maskIP(*v.Actor.IP)
+			v.Actor.IP = &m
+		}
+		list = append(list, v)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prevPage *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		po := offset - limit
+		if po < 0 {
+			po = 0
+		}
+		s := build(po)
+		prevPage = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prevPage})
+}
+
+// RevertEntity restores an entity to the state recorded in ?version=N. The revert is itself an
+// ordinary update (or re-insert, when the entity was deleted since) and shows up in the history.
+// PINs are not part of history and are left as they are.
+func (h *Handler) RevertEntity(c *gin.Context) {
+	res, table, ok := historyResource(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	id := c.Param("id")
+	version, err := strconv.Atoi(c.Query("version"))
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a positive integer"})
+		return
+	}
+	ctx := context.Background()
+	var data []byte
+	if err := h.db(c).QueryRow(ctx, `select data from entity_versions where resource=$1 and entity_id=$2 and version=$3`, res, id, version).Scan(&data); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var snapshot map[string]json.RawMessage
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Only restore columns that still exist and were captured; newer columns keep their value.
+	colRows, err := h.db(c).Query(ctx, `select column_name from information_schema.columns where table_schema=current_schema() and table_name=$1 order by ordinal_position`, table)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cols := []string{}
+	hasUpdatedAt := false
+	for colRows.Next() {
+		var name string
+		if err := colRows.Scan(&name); err != nil {
+			colRows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if name == "updated_at" {
+			hasUpdatedAt = true
+			continue
+		}
+		if _, captured := snapshot[name]; captured && name != "id" && name != "valid_pin" {
+			cols = append(cols, pgx.Identifier{name}.Sanitize())
+		}
+	}
+	colRows.Close()
+	if err := colRows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(cols) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "nothing to restore"})
+		return
+	}
+	tbl := pgx.Identifier{table}.Sanitize()
+	var exists bool
+	if err := h.db(c).QueryRow(ctx, "select exists(select 1 from "+tbl+" where id=$1)", id).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	colList := strings.Join(cols, ",")
+	var q string
+	if exists {
+		q = "update " + tbl + " set (" + colList + ") = (select " + colList + " from jsonb_populate_record(null::" + tbl + ", $1::jsonb))"
+		if hasUpdatedAt {
+			q += ", updated_at=now()"
+		}
+		q += " where id=$2"
+	} else {
+		q = "insert into " + tbl + " (id," + colList
+		sel := "$2," + colList
+		if hasUpdatedAt {
+			q += ",updated_at"
+			sel += ",now()"
+		}
+		q += ") select " + sel + " from jsonb_populate_record(null::" + tbl + ", $1::jsonb)"
+	}
+	if _, err := h.db(c).Exec(ctx, q, string(data), id); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "23") {
+			c.JSON(http.StatusConflict, gin.H{"error": "cannot revert: " + pgErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var current int
+	if err := h.db(c).QueryRow(ctx, `select coalesce(max(version),0) from entity_versions where resource=$1 and entity_id=$2`, res, id).Scan(&current); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"resource": res, "id": id, "reverted_to": version, "version": current, "restored": !exists})
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"context"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -12,7 +11,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 
-	"guangfu250923/internal/middleware"
 	"guangfu250923/internal/models"
 )
 
@@ -51,8 +49,9 @@ func (h *Handler) ListHumanResources(c *gin.Context) {
 	if roleType != "" {
 		add("role_type=", roleType)
 	}
+	where = appendLiveFilter(c, where)
 
-	base := `select id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests from human_resources`
+	base := `select id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests,extract(epoch from deleted_at)::bigint from human_resources`
 	countSQL := `select count(*) from human_resources`
 	if len(where) > 0 {
 		clause := " where " + join(where, " and ")
@@ -64,12 +63,12 @@ func (h *Handler) ListHumanResources(c *gin.Context) {
 
 	ctx := context.Background()
 	var total int
-	if err := h.pool.QueryRow(ctx, countSQL, args[:len(args)-2]...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countSQL, args[:len(args)-2]...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	rows, err := h.pool.Query(ctx, base, args...)
+	rows, err := h.db(c).Query(ctx, base, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -90,7 +89,7 @@ func (h *Handler) ListHumanResources(c *gin.Context) {
 		var totalRoles, completedRoles, pendingRoles *int
 		var urgentReq, medicalReq *int
 		var piiDate *int64
-		if err := rows.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStart, &shiftEnd, &shiftNotes, &assignmentTs, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq); err != nil {
+		if err := rows.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStart, &shiftEnd, &shiftNotes, &assignmentTs, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq, &hr.DeletedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -148,7 +147,7 @@ func join(parts []string, sep string) string {
 // GetHumanResource fetch single by id
 func (h *Handler) GetHumanResource(c *gin.Context) {
 	id := c.Param("id")
-	row := h.pool.QueryRow(context.Background(), `select id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests from human_resources where id=$1`, id)
+	row := h.db(c).QueryRow(context.Background(), `select id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests,extract(epoch from deleted_at)::bigint from human_resources where id=$1`+liveCond(c), id)
 	var hr models.HumanResource
 	var skills, certs, langs []string
 	var hasMedical *bool
@@ -161,7 +160,7 @@ func (h *Handler) GetHumanResource(c *gin.Context) {
 	var totalRoles, completedRoles, pendingRoles *int
 	var urgentReq, medicalReq *int
 	var piiDate *int64
-	if err := row.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStart, &shiftEnd, &shiftNotes, &assignmentTs, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq); err != nil {
+	if err := row.Scan(&hr.ID, &hr.Org, &hr.Address, &hr.Phone, &hr.Status, &hr.IsCompleted, &hasMedical, &piiDate, &hr.CreatedAt, &hr.UpdatedAt, &hr.RoleName, &hr.RoleType, &skills, &certs, &expLevel, &langs, &hr.HeadcountNeed, &hr.HeadcountGot, &headUnit, &hr.RoleStatus, &shiftStart, &shiftEnd, &shiftNotes, &assignmentTs, &hr.AssignmentCount, &assignmentNotes, &totalRolesInReq, &completedRolesInReq, &pendingRolesInReq, &totalReq, &activeReq, &completedReq, &cancelledReq, &totalRoles, &completedRoles, &pendingRoles, &urgentReq, &medicalReq, &hr.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -261,6 +260,11 @@ func (h *Handler) CreateHumanResource(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_pin must be 6 digits, with 1 - 9"})
 		return
 	}
+	pinHash, err := hashPinInput(in.ValidPin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	if in.HeadcountNeed <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "headcount_need must be > 0"})
 		return
@@ -290,18 +294,18 @@ func (h *Handler) CreateHumanResource(c *gin.Context) {
 
 	// NOTE: keep column count in sync with values placeholders. If you add/remove a column update both lists.
 	sql := `insert into human_resources (
-			id,org,address,phone,status,is_completed,has_medical,pii_date,role_name,role_type,skills,certifications,experience_level,language_requirements,headcount_need,headcount_got,headcount_unit,role_status,shift_start_ts,shift_end_ts,shift_notes,assignment_timestamp,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests,valid_pin
+			id,org,address,phone,status,is_completed,has_medical,pii_date,role_name,role_type,skills,certifications,experience_level,language_requirements,headcount_need,headcount_got,headcount_unit,role_status,shift_start_ts,shift_end_ts,shift_notes,assignment_timestamp,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests,valid_pin,owner_user_id
 		) values (
-			$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,$32,$33,$34,$35,$36,$37
+			$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,$32,$33,$34,$35,$36,$37,$38
 		) returning id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests`
 
-	row := h.pool.QueryRow(context.Background(), sql,
+	row := h.db(c).QueryRow(context.Background(), sql,
 		id, in.Org, in.Address, in.Phone, in.Status, in.IsCompleted, in.HasMedical, in.PiiDate, in.RoleName, in.RoleType,
 		sliceOrNil(in.Skills), sliceOrNil(in.Certifications), in.ExperienceLevel, sliceOrNil(in.LanguageRequirements),
 		in.HeadcountNeed, in.HeadcountGot, in.HeadcountUnit, in.RoleStatus,
 		shiftStart, shiftEnd, in.ShiftNotes, assignmentTs, in.AssignmentCount, in.AssignmentNotes,
 		in.TotalRolesInRequest, in.CompletedRolesInRequest, in.PendingRolesInRequest, in.TotalRequests, in.ActiveRequests,
-		in.CompletedRequests, in.CancelledRequests, in.TotalRoles, in.CompletedRoles, in.PendingRoles, in.UrgentRequests, in.MedicalRequests, in.ValidPin,
+		in.CompletedRequests, in.CancelledRequests, in.TotalRoles, in.CompletedRoles, in.PendingRoles, in.UrgentRequests, in.MedicalRequests, pinHash, currentUserID(c),
 	)
 
 	var hr models.HumanResource
@@ -396,42 +400,11 @@ func (h *Handler) PatchHumanResource(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// API key requirement: if this patch is not limited to status/is_completed/headcount_got, require API key to be allowed.
-	if !isOnlyUpdateStatusIsCompletedHeadcountGot(in) {
-		if !middleware.IsAPIKeyAllowed(c) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "api key required"})
-			return
-		}
-	}
-	// Optional verification (controlled by VERIFY_HR_PIN)
-	if os.Getenv("VERIFY_HR_PIN") == "true" {
-		// Fetch stored pin (if any)
-		var storedPin *string
-		if err := h.pool.QueryRow(context.Background(), `select valid_pin from human_resources where id=$1`, id).Scan(&storedPin); err != nil {
-			if err == pgx.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		// PIN behavior:
-		// - If VERIFY_HR_PIN=false, do not enforce matching; still generate/set one if missing to onboard records.
-		// - If VERIFY_HR_PIN=true, enforce match once a pin exists; else generate/set as needed.
-		if storedPin == nil || strings.TrimSpace(*storedPin) == "" {
-			// bypass
-		} else {
-			// PIN exists already in record
-			// Determine if this PATCH only updates the allowed trio of fields:
-			// status, is_completed, headcount_got. If so, we'll bypass PIN verification.
-			if !isOnlyUpdateStatusIsCompletedHeadcountGot(in) {
-				// Must provide and match
-				if !isValidPin6(in.ValidPin) || *in.ValidPin != *storedPin {
-					c.JSON(http.StatusForbidden, gin.H{"error": "invalid pin"})
-					return
-				}
-			}
-		}
+	// status/is_completed/headcount_got are open to anyone (policy); other fields need a role or key
+	// granting human_resources:update, the signed-in owner (or a co-owner) or, when VERIFY_HR_PIN=true,
+	// the record's PIN.
+	if !h.authorizeEdit(c, "human_resources", id, in.ValidPin) {
+		return
 	}
 	setParts := []string{}
 	args := []interface{}{}
@@ -560,9 +533,9 @@ func (h *Handler) PatchHumanResource(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update human_resources set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests"
+	query := "update human_resources set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,org,address,phone,status,is_completed,has_medical,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,role_name,role_type,coalesce(skills,'{}'),coalesce(certifications,'{}'),experience_level,coalesce(language_requirements,'{}'),headcount_need,headcount_got,headcount_unit,role_status,extract(epoch from shift_start_ts)::bigint,extract(epoch from shift_end_ts)::bigint,shift_notes,extract(epoch from assignment_timestamp)::bigint,assignment_count,assignment_notes,total_roles_in_request,completed_roles_in_request,pending_roles_in_request,total_requests,active_requests,completed_requests,cancelled_requests,total_roles,completed_roles,pending_roles,urgent_requests,medical_requests"
 	args = append(args, id)
-	row := h.pool.QueryRow(context.Background(), query, args...)
+	row := h.db(c).QueryRow(context.Background(), query, args...)
 
 	var hr models.HumanResource
 	var skills, certs, langs []string
@@ -618,32 +591,3 @@ func sliceOrNil(s []string) interface{} {
 	}
 	return s
 }
-
-func isOnlyUpdateStatusIsCompletedHeadcountGot(in humanResourcePatchInput) bool {
-	// Track if any of the allowed fields are actually being updated
-	hasAnyAllowed := false
-	if in.Status != nil {
-		hasAnyAllowed = true
-	}
-	if in.IsCompleted != nil {
-		hasAnyAllowed = true
-	}
-	if in.HeadcountGot != nil {
-		hasAnyAllowed = true
-	}
-
-	// If any other updatable field is present in the payload, it's not a limited update
-	if in.Org != nil || in.Address != nil || in.Phone != nil || in.HasMedical != nil || in.PiiDate != nil ||
-		in.RoleName != nil || in.RoleType != nil || in.Skills != nil || in.Certifications != nil ||
-		in.ExperienceLevel != nil || in.LanguageRequirements != nil || in.HeadcountNeed != nil ||
-		in.HeadcountUnit != nil || in.RoleStatus != nil || in.ShiftStartTs != nil || in.ShiftEndTs != nil ||
-		in.ShiftNotes != nil || in.AssignmentTimestamp != nil || in.AssignmentCount != nil ||
-		in.AssignmentNotes != nil || in.TotalRolesInRequest != nil || in.CompletedRolesInRequest != nil ||
-		in.PendingRolesInRequest != nil || in.TotalRequests != nil || in.ActiveRequests != nil ||
-		in.CompletedRequests != nil || in.CancelledRequests != nil || in.TotalRoles != nil ||
-		in.CompletedRoles != nil || in.PendingRoles != nil || in.UrgentRequests != nil ||
-		in.MedicalRequests != nil {
-		return false
-	}
-	return hasAnyAllowed
-}
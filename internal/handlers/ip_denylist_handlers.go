@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/middleware"
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const ipDenyColumns = `id,pattern,reason,created_by,extract(epoch from expires_at)::bigint,(expires_at is null or expires_at > now()),
+	extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+func scanIPDenyEntry(row pgx.Row) (models.IPDenyEntry, error) {
+	var e models.IPDenyEntry
+	err := row.Scan(&e.ID, &e.Pattern, &e.Reason, &e.CreatedBy, &e.ExpiresAt, &e.Active, &e.CreatedAt, &e.UpdatedAt)
+	return e, err
+}
+
+// normalizeDenyPattern validates a single IP or CIDR and returns it in the canonical form IPFilter
+// compares against. Ranges wider than /8 (IPv4) or /16 (IPv6) are refused as almost certainly a typo.
+func normalizeDenyPattern(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if strings.Contains(p, "/") {
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return "", errors.New("pattern is not a valid CIDR")
+		}
+		ones, bits := network.Mask.Size()
+		if (bits == 32 && ones < 8) || (bits == 128 && ones < 16) {
+			return "", errors.New("CIDR range too wide")
+		}
+		return network.String(), nil
+	}
+	ip := net.ParseIP(p)
+	if ip == nil {
+		return "", errors.New("pattern is not a valid IP or CIDR")
+	}
+	return ip.String(), nil
+}
+
+type ipDenyCreateInput struct {
+	Pattern   string  `json:"pattern"`
+	Reason    *string `json:"reason"`
+	ExpiresAt *int64  `json:"expires_at"`
+}
+
+type requestLogBlockInput struct {
+	Reason    *string `json:"reason"`
+	ExpiresAt *int64  `json:"expires_at"`
+}
+
+// insertDenyEntry adds pattern to ip_denylist unless an active entry for it exists (409), and
+// makes IPFilter reload once the request commits. An expires_at that has already passed would add
+// an entry that never blocks anything, so it is refused (400).
+func (h *Handler) insertDenyEntry(c *gin.Context, pattern string, reason *string, expiresAt *int64) {
+	ctx := context.Background()
+	var expires interface{}
+	if expiresAt != nil && *expiresAt > 0 {
+		if *expiresAt <= time.Now().Unix() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+		expires = *expiresAt
+	}
+	// the API key's name, or user:<id> for a signed-in moderator
+	createdBy := actorName(c)
+	var exists bool
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from ip_denylist where pattern=$1 and (expires_at is null or expires_at > now()))`, pattern).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "pattern already denied", "pattern": pattern})
+		return
+	}
+	e, err := scanIPDenyEntry(h.db(c).QueryRow(ctx, `insert into ip_denylist(pattern,reason,created_by,expires_at) values($1,$2,$3,to_timestamp($4::bigint)) returning `+ipDenyColumns,
+		pattern, reason, createdBy, expires))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.afterCommit(c, middleware.RefreshDenylist)
+	c.JSON(http.StatusCreated, e)
+}
+
+func (h *Handler) ListIPDenylist(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := context.Background()
+	filters := []string{}
+	args := []interface{}{}
+	switch c.Query("active") {
+	case "true":
+		filters = append(filters, "(expires_at is null or expires_at > now())")
+	case "false":
+		filters = append(filters, "expires_at <= now()")
+	}
+	if v := c.Query("pattern"); v != "" {
+		filters = append(filters, "pattern=$"+strconv.Itoa(len(args)+1))
+		args = append(args, v)
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, "select count(*) from ip_denylist"+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	args = append(args, limit, offset)
+	rows, err := h.db(c).Query(ctx, "select "+ipDenyColumns+" from ip_denylist"+where+" order by created_at desc limit $"+strconv.Itoa(len(args)-1)+" offset $"+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []models.IPDenyEntry{}
+	for rows.Next() {
+		e, err := scanIPDenyEntry(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, e)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prev *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		po := offset - limit
+		if po < 0 {
+			po = 0
+		}
+		s := build(po)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+func (h *Handler) CreateIPDenyEntry(c *gin.Context) {
+	var in ipDenyCreateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pattern, err := normalizeDenyPattern(in.Pattern)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.insertDenyEntry(c, pattern, in.Reason, in.ExpiresAt)
+}
+
+func (h *Handler) DeleteIPDenyEntry(c *gin.Context) {
+	tag, err := h.db(c).Exec(context.Background(), `delete from ip_denylist where id=$1`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	h.afterCommit(c, middleware.RefreshDenylist)
+	c.Status(http.StatusNoContent)
+}
+
+// BlockRequestLogIP denies the client IP recorded in a request log, e.g. for the source of a spam
+// submission found in /_admin/request_logs.
+func (h *Handler) BlockRequestLogIP(c *gin.Context) {
+	var in requestLogBlockInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	id := c.Param("id")
+	// request_logs.id is a uuid; anything else can't match a log
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var ip *string
+	var method, path string
+	if err := h.db(c).QueryRow(context.Background(), `select ip,method,path from request_logs where id=$1`, id).Scan(&ip, &method, &path); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if ip == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "request log has no ip"})
+		return
+	}
+	pattern, err := normalizeDenyPattern(*ip)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	reason := in.Reason
+	if reason == nil || strings.TrimSpace(*reason) == "" {
+		r := "request log " + id + ": " + method + " " + path
+		reason = &r
+	}
+	h.insertDenyEntry(c, pattern, reason, in.ExpiresAt)
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/legacy"
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mapFeatureSource maps one resource table onto the normalized map feature columns.
+// kind doubles as the resource path, so the detail link is "/<kind>/<id>".
+type mapFeatureSource struct {
+	kind    string
+	table   string
+	name    string
+	address string
+	status  string
+	coords  string
+}
+
+var mapFeatureSources = []mapFeatureSource{
+	{kind: "shelters", table: "shelters", name: "name", address: "location", status: "status", coords: "coordinates"},
+	{kind: "medical_stations", table: "medical_stations", name: "name", address: "coalesce(detailed_address, location)", status: "status", coords: "coordinates"},
+	{kind: "mental_health_resources", table: "mental_health_resources", name: "name", address: "location", status: "status", coords: "coordinates"},
+	{kind: "accommodations", table: "accommodations", name: "name", address: "address", status: "status", coords: "coordinates"},
+	{kind: "shower_stations", table: "shower_stations", name: "name", address: "address", status: "status", coords: "coordinates"},
+	{kind: "water_refill_stations", table: "water_refill_stations", name: "name", address: "address", status: "status", coords: "coordinates"},
+	{kind: "restrooms", table: "restrooms", name: "name", address: "address", status: "status", coords: "coordinates"},
+	{kind: "places", table: "places", name: "name", address: "address", status: "status", coords: "coordinates"},
+	// supplies have an address but no coordinates or status yet
+	{kind: "supplies", table: "supplies", name: "coalesce(name, '')", address: "address", status: "null::text", coords: "null::jsonb"},
+}
+
+// mapFeaturesUnion builds the union all over the requested kinds (all when kinds is empty),
+// leaving out soft-deleted rows. Each legacy facility is listed once: while legacy reads come
+// from places (LEGACY_READ_FROM_PLACES) the legacy tables are left out, otherwise the places
+// rows cmd/migrate_places copied from them (legacy_place_map) are.
+func (h *Handler) mapFeaturesUnion(kinds map[string]bool) string {
+	parts := []string{}
+	for _, s := range mapFeatureSources {
+		if len(kinds) > 0 && !kinds[s.kind] {
+			continue
+		}
+		if _, ok := legacy.Lookup(s.table); ok && h.legacyFromPlaces {
+			continue
+		}
+		where := "deleted_at is null"
+		if s.table == "places" && !h.legacyFromPlaces {
+			where += " and not exists (select 1 from legacy_place_map m where m.place_id=places.id)"
+		}
+		parts = append(parts, "select id,'"+s.kind+"'::text as kind,"+s.name+" as name,"+s.address+" as address,"+s.status+" as status,"+s.coords+" as coordinates,(extract(epoch from updated_at)*1000000)::bigint as updated_us from "+s.table+" where "+where)
+	}
+	if len(parts) == 0 {
+		// only legacy kinds were asked for and they are served as places
+		parts = append(parts, "select null::text as id,null::text as kind,null::text as name,null::text as address,null::text as status,null::jsonb as coordinates,null::bigint as updated_us where false")
+	}
+	return "(" + strings.Join(parts, " union all ") + ") f"
+}
+
+// encodeMapCursor / decodeMapCursor carry the (updated_us, kind, id) keyset of the last row.
+func encodeMapCursor(updatedUs int64, kind, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(updatedUs, 10) + "|" + kind + "|" + id))
+}
+
+func decodeMapCursor(raw string) (int64, string, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return 0, "", "", errors.New("invalid cursor")
+	}
+	parts := strings.SplitN(string(b), "|", 3)
+	if len(parts) != 3 {
+		return 0, "", "", errors.New("invalid cursor")
+	}
+	us, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", "", errors.New("invalid cursor")
+	}
+	return us, parts[1], parts[2], nil
+}
+
+// ListMapFeatures unions every location-bearing resource into one feature list for the map.
+// Filters: kind (comma separated), status (comma separated), bbox. Paging uses an opaque cursor
+// (most recently updated first) instead of offset so pages stay stable while data changes.
+func (h *Handler) ListMapFeatures(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 200, 1, 1000)
+	kinds := map[string]bool{}
+	if raw := c.Query("kind"); raw != "" {
+		known := map[string]bool{}
+		for _, s := range mapFeatureSources {
+			known[s.kind] = true
+		}
+		for _, k := range strings.Split(raw, ",") {
+			k = strings.TrimSpace(k)
+			if k == "" {
+				continue
+			}
+			if !known[k] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown kind: " + k})
+				return
+			}
+			kinds[k] = true
+		}
+	}
+	filters := []string{}
+	args := []interface{}{}
+	if raw := c.Query("status"); raw != "" {
+		statuses := []string{}
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				statuses = append(statuses, s)
+			}
+		}
+		filters = append(filters, "status = any($"+strconv.Itoa(len(args)+1)+")")
+		args = append(args, statuses)
+	}
+	if raw := c.Query("bbox"); raw != "" {
+		bbox, err := parseBBox(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filters, args = appendBoxFilter(filters, args, bbox[0], bbox[1], bbox[2], bbox[3])
+	}
+	from := " from " + h.mapFeaturesUnion(kinds)
+	ctx := context.Background()
+	countQ := "select count(*)" + from
+	if len(filters) > 0 {
+		countQ += " where " + strings.Join(filters, " and ")
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		us, kind, id, err := decodeMapCursor(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		n := len(args)
+		filters = append(filters, "(updated_us, kind, id) < ($"+strconv.Itoa(n+1)+"::bigint, $"+strconv.Itoa(n+2)+"::text, $"+strconv.Itoa(n+3)+"::text)")
+		args = append(args, us, kind, id)
+	}
+	dataQ := "select id,kind,name,address,status,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,updated_us" + from
+	if len(filters) > 0 {
+		dataQ += " where " + strings.Join(filters, " and ")
+	}
+	// fetch one extra row to know whether there is a next page
+	args = append(args, limit+1)
+	dataQ += " order by updated_us desc, kind desc, id desc limit $" + strconv.Itoa(len(args))
+	rows, err := h.db(c).Query(ctx, dataQ, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []models.MapFeature{}
+	var lastUs int64
+	hasMore := false
+	for rows.Next() {
+		if len(list) == limit {
+			hasMore = true
+			break
+		}
+		var f models.MapFeature
+		var lat, lng *float64
+		var us int64
+		if err := rows.Scan(&f.ID, &f.Kind, &f.Name, &f.Address, &f.Status, &lat, &lng, &us); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if lat != nil || lng != nil {
+			f.Coordinates = &struct {
+				Lat *float64 `json:"lat"`
+				Lng *float64 `json:"lng"`
+			}{Lat: lat, Lng: lng}
+		}
+		f.UpdatedAt = us / 1000000
+		f.Link = "/" + f.Kind + "/" + f.ID
+		lastUs = us
+		list = append(list, f)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var next *string
+	var nextCursor *string
+	if hasMore {
+		last := list[len(list)-1]
+		cur := encodeMapCursor(lastUs, last.Kind, last.ID)
+		q := c.Request.URL.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("cursor", cur)
+		s := c.Request.URL.Path + "?" + q.Encode()
+		next, nextCursor = &s, &cur
+	}
+	if wantsGeoJSON(c) {
+		writeFeatureCollection(c, list, gin.H{"totalItems": total, "limit": limit, "next": next, "next_cursor": nextCursor})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "next": next, "next_cursor": nextCursor})
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Weights of the parts of a match score (they add up to 1).
+const (
+	matchWeightName     = 0.5
+	matchWeightType     = 0.15
+	matchWeightUnit     = 0.15
+	matchWeightDistance = 0.2
+	// pledges whose item name is less similar than this are only proposed when the type matches
+	matchMinNameScore = 0.3
+	// beyond this distance a pledge gets no distance score at all
+	matchMaxDistanceM = maxRadiusM
+)
+
+// pledgeMatchable are the pledge statuses that can still be redirected to a place in need.
+const pledgeMatchable = `('pledged','confirmed')`
+
+// MatchScores are the parts of a match score, each from 0 to 1. Unit is 1 when the pledge's unit
+// converts to the requirement's and 0.5 when either has none. Distance is null when the pledge or
+// the place has no coordinates (the score counts it as 0.5).
+type MatchScores struct {
+	Name     float64  `json:"name"`
+	Type     float64  `json:"type"`
+	Unit     float64  `json:"unit"`
+	Distance *float64 `json:"distance"`
+}
+
+// SupplyMatch is a pledge (supply_providers) proposed for a requirement (requirements_supplies).
+type SupplyMatch struct {
+	SupplyProviderID string  `json:"supply_provider_id"`
+	SupplyItemID     string  `json:"supply_item_id"`
+	Name             *string `json:"name"` // of the pledged item
+	Tag              *string `json:"tag"`
+	Unit             *string `json:"unit"`
+	Address          string  `json:"address"`
+	Coordinates      *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"`
+	Status            string      `json:"status"`          // of the pledge
+	AvailableCount    int         `json:"available_count"` // not allocated yet, in the requirement's unit
+	SuggestedQuantity int         `json:"suggested_quantity"`
+	DistanceM         *float64    `json:"distance_m"`
+	Score             float64     `json:"score"`
+	Scores            MatchScores `json:"scores"`
+	convertible       bool        // the pledge's quantity converts to the requirement's unit
+}
+
+// matchNeed is the requirement side of a match. Outstanding is what neither arrived nor was
+// allocated yet.
+type matchNeed struct {
+	ID, PlaceID, RequiredType, Name, Unit string
+	Outstanding                           int
+	Lat, Lng                              *float64
+}
+
+const matchNeedQuery = `select r.id,r.place_id,r.required_type,r.name,r.unit,
+	r.require_count-r.received_count-coalesce((select sum(a.quantity) from allocations a where a.requirement_id=r.id and a.status='accepted'),0)::int,
+	(p.coordinates->>'lat')::double precision,(p.coordinates->>'lng')::double precision
+	from requirements_supplies r join places p on p.id=r.place_id where r.id=$1 and r.deleted_at is null`
+
+// loadMatchNeed reads requirement id, locking it for the rest of the request when lock is set.
+func (h *Handler) loadMatchNeed(c *gin.Context, id string, lock bool) (matchNeed, error) {
+	q := matchNeedQuery
+	if lock {
+		q += " for update of r"
+	}
+	var n matchNeed
+	err := h.db(c).QueryRow(context.Background(), q, id).Scan(&n.ID, &n.PlaceID, &n.RequiredType, &n.Name, &n.Unit, &n.Outstanding, &n.Lat, &n.Lng)
+	return n, err
+}
+
+// matchOfferColumns are the columns of a pledge offered for a need; what it has left is converted
+// to the need's unit, the query's $2 (null when the units don't convert, see convert_quantity).
+var matchOfferColumns = `p.id,p.supply_item_id,i.name,i.tag,coalesce(nullif(p.provide_unit,''),i.unit),p.address,
+	(p.coordinates->>'lat')::double precision,(p.coordinates->>'lng')::double precision,p.status,
+	floor(convert_quantity(p.provide_count-` + pledgeAllocated("p") + `,i.name,i.tag,coalesce(nullif(p.provide_unit,''),i.unit),$2::text))::int`
+
+const matchOfferFrom = ` from supply_providers p join supply_items i on i.id=p.supply_item_id where p.deleted_at is null and i.deleted_at is null`
+
+func scanMatchOffer(row pgx.Row) (SupplyMatch, error) {
+	var m SupplyMatch
+	var lat, lng *float64
+	var available *int
+	err := row.Scan(&m.SupplyProviderID, &m.SupplyItemID, &m.Name, &m.Tag, &m.Unit, &m.Address, &lat, &lng, &m.Status, &available)
+	if available != nil {
+		m.AvailableCount, m.convertible = *available, true
+	}
+	if lat != nil || lng != nil {
+		m.Coordinates = &struct {
+			Lat *float64 `json:"lat"`
+			Lng *float64 `json:"lng"`
+		}{Lat: lat, Lng: lng}
+	}
+	return m, err
+}
+
+// scoreMatch fills in the scores of pledge m for need n and reports whether it is worth proposing:
+// a similar item name or the same type, and a unit that converts to the need's.
+func scoreMatch(n matchNeed, m *SupplyMatch) bool {
+	if m.Name != nil {
+		m.Scores.Name = nameSimilarity(n.Name, *m.Name)
+	}
+	if m.Tag != nil && n.RequiredType != "" && strings.EqualFold(strings.TrimSpace(*m.Tag), strings.TrimSpace(n.RequiredType)) {
+		m.Scores.Type = 1
+	}
+	switch {
+	case m.Unit == nil || strings.TrimSpace(*m.Unit) == "" || strings.TrimSpace(n.Unit) == "":
+		m.Scores.Unit = 0.5
+	case m.convertible:
+		m.Scores.Unit = 1
+	}
+	distance := 0.5
+	m.DistanceM = nil
+	if n.Lat != nil && n.Lng != nil && m.Coordinates != nil && m.Coordinates.Lat != nil && m.Coordinates.Lng != nil {
+		d := math.Round(haversineM(*n.Lat, *n.Lng, *m.Coordinates.Lat, *m.Coordinates.Lng)*10) / 10
+		m.DistanceM = &d
+		distance = math.Max(0, 1-d/matchMaxDistanceM)
+		m.Scores.Distance = &distance
+	}
+	score := matchWeightName*m.Scores.Name + matchWeightType*m.Scores.Type + matchWeightUnit*m.Scores.Unit + matchWeightDistance*distance
+	m.Score = math.Round(score*1000) / 1000
+	return m.Scores.Unit > 0 && (m.Scores.Name >= matchMinNameScore || m.Scores.Type == 1)
+}
+
+// nameSimilarity scores how alike two item names are, from 0 to 1: the Dice coefficient of their
+// character bigrams (which works for Chinese as well as for words), at least 0.8 when one name
+// contains the other ("水" and "礦泉水").
+func nameSimilarity(a, b string) float64 {
+	ra, rb := matchText(a), matchText(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	sa, sb := string(ra), string(rb)
+	if sa == sb {
+		return 1
+	}
+	score := 0.0
+	if len(ra) > 1 && len(rb) > 1 {
+		bigrams := map[[2]rune]int{}
+		for i := 0; i+1 < len(ra); i++ {
+			bigrams[[2]rune{ra[i], ra[i+1]}]++
+		}
+		common := 0
+		for i := 0; i+1 < len(rb); i++ {
+			k := [2]rune{rb[i], rb[i+1]}
+			if bigrams[k] > 0 {
+				bigrams[k]--
+				common++
+			}
+		}
+		score = 2 * float64(common) / float64(len(ra)+len(rb)-2)
+	}
+	if strings.Contains(sa, sb) || strings.Contains(sb, sa) {
+		score = math.Max(score, 0.8)
+	}
+	return score
+}
+
+// matchText lowercases s and keeps only its letters and digits.
+func matchText(s string) []rune {
+	var out []rune
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// ListRequirementMatches proposes pledges for what a requirement still lacks, best match first:
+// pledges not yet on their way (pledged or confirmed) with something left to allocate, scored by
+// item name, type (the item's tag against required_type), unit and distance from the place. Pledges
+// in another unit are offered when it converts (箱 of bottled water for a need counted in 瓶), with
+// quantities in the requirement's unit.
+// Pledges a coordinator rejected for the requirement are left out. Accept or reject a proposal
+// with POST /allocations.
+func (h *Handler) ListRequirementMatches(c *gin.Context) {
+	id := c.Param("id")
+	limit := parsePositiveInt(c.Query("limit"), 20, 1, 100)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := context.Background()
+	need, err := h.loadMatchNeed(c, id, false)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	list := []SupplyMatch{}
+	if need.Outstanding > 0 {
+		rows, err := h.db(c).Query(ctx, `select `+matchOfferColumns+matchOfferFrom+` and p.status in `+pledgeMatchable+`
+			and p.provide_count-`+pledgeAllocated("p")+`>0
+			and not exists (select 1 from allocations a where a.supply_provider_id=p.id and a.requirement_id=$1 and a.status='rejected')`, id, need.Unit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			m, err := scanMatchOffer(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !scoreMatch(need, &m) || m.AvailableCount <= 0 {
+				continue
+			}
+			m.SuggestedQuantity = min(need.Outstanding, m.AvailableCount)
+			list = append(list, m)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].SupplyProviderID < list[j].SupplyProviderID
+	})
+	total := len(list)
+	page := list[min(offset, total):min(offset+limit, total)]
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prev *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		s := build(max(offset-limit, 0))
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": page, "limit": limit, "offset": offset, "next": next, "previous": prev,
+		"requirement_id": need.ID, "outstanding_count": max(need.Outstanding, 0)})
+}
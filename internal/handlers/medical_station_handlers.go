@@ -54,7 +54,7 @@ func (h *Handler) CreateMedicalStation(c *gin.Context) {
 	}
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into medical_stations(station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,affiliated_organization,notes,link,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8::text[],$9::text[],$10,$11,$12,$13,$14,$15,$16::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+	err := h.db(c).QueryRow(ctx, `insert into medical_stations(station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,affiliated_organization,notes,link,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8::text[],$9::text[],$10,$11,$12,$13,$14,$15,$16::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
 		in.StationType, in.Name, in.Location, in.DetailedAddress, in.Phone, in.ContactPerson, in.Status, in.Services, in.Equipment, in.OperatingHours, in.MedicalStaff, in.DailyCapacity, in.AffiliatedOrganization, in.Notes, in.Link, coordsJSON).Scan(&id, &created, &updated)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -70,6 +70,11 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	status := c.Query("status")
 	stationType := c.Query("station_type")
+	geo, err := parseGeoQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := context.Background()
 
 	// Build filters
@@ -84,8 +89,11 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 		args = append(args, stationType)
 	}
 
-	countQuery := "select count(*) from medical_stations"
-	dataQuery := "select id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from medical_stations"
+	filters = appendLiveFilter(c, filters)
+	filters, args = geo.apply(filters, args)
+
+	countQuery := "select count(*) from " + h.legacySource("medical_stations")
+	dataQuery := "select id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint," + geo.distanceColumn() + " from " + h.legacySource("medical_stations")
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQuery += where
@@ -93,15 +101,15 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 	}
 
 	var total int
-	if err := h.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	argsWithPage := append(args, limit, offset)
-	dataQuery += " order by updated_at desc limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
+	dataQuery += " order by " + geo.orderBy("updated_at desc") + " limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
 
-	rows, err := h.pool.Query(ctx, dataQuery, argsWithPage...)
+	rows, err := h.db(c).Query(ctx, dataQuery, argsWithPage...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -116,7 +124,7 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 		var services, equipment []string
 		var lat, lng *float64
 		var created, updated int64
-	if err := rows.Scan(&m.ID, &m.StationType, &m.Name, &m.Location, &detailedAddr, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated); err != nil {
+	if err := rows.Scan(&m.ID, &m.StationType, &m.Name, &m.Location, &detailedAddr, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated, &m.DeletedAt, &m.DistanceM); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -158,7 +166,7 @@ func (h *Handler) ListMedicalStations(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
-	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+	writeLocationCollection(c, list, total, limit, offset, next, prev)
 }
 
 type medicalStationPatchInput struct {
@@ -256,9 +264,9 @@ func (h *Handler) PatchMedicalStation(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update medical_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update medical_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var m models.MedicalStation
 	var detailedAddr, phone, contactPerson, operatingHours, affiliatedOrg, notes, link *string
 	var medStaff, dailyCap *int
@@ -298,14 +306,14 @@ func (h *Handler) PatchMedicalStation(c *gin.Context) {
 func (h *Handler) GetMedicalStation(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from medical_stations where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,station_type,name,location,detailed_address,phone,contact_person,status,services,equipment,operating_hours,medical_staff,daily_capacity,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,affiliated_organization,notes,link,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from ` + h.legacySource("medical_stations") + ` where id=$1`+liveCond(c), id)
 	var m models.MedicalStation
 	var detailedAddr, phone, contactPerson, operatingHours, affiliatedOrg, notes, link *string
 	var medStaff, dailyCap *int
 	var services, equipment []string
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&m.ID, &m.StationType, &m.Name, &m.Location, &detailedAddr, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated); err != nil {
+	if err := row.Scan(&m.ID, &m.StationType, &m.Name, &m.Location, &detailedAddr, &phone, &contactPerson, &m.Status, &services, &equipment, &operatingHours, &medStaff, &dailyCap, &lat, &lng, &affiliatedOrg, &notes, &link, &created, &updated, &m.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
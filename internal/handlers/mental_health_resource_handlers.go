@@ -60,7 +60,7 @@ func (h *Handler) CreateMentalHealthResource(c *gin.Context) {
 	}
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into mental_health_resources(duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,coordinates,status,capacity,waiting_time,notes,emergency_support) values($1,$2,$3,$4,$5,$6,$7::text[],$8::text[],$9::text[],$10,$11,$12::jsonb,$13,$14,$15,$16,$17) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+	err := h.db(c).QueryRow(ctx, `insert into mental_health_resources(duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,coordinates,status,capacity,waiting_time,notes,emergency_support) values($1,$2,$3,$4,$5,$6,$7::text[],$8::text[],$9::text[],$10,$11,$12::jsonb,$13,$14,$15,$16,$17) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
 		in.DurationType, in.Name, in.ServiceFormat, in.ServiceHours, in.ContactInfo, in.WebsiteURL, in.TargetAudience, in.Specialties, in.Languages, isFree, in.Location, coordsJSON, in.Status, in.Capacity, in.WaitingTime, in.Notes, emergency).Scan(&id, &created, &updated)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -170,9 +170,9 @@ func (h *Handler) PatchMentalHealthResource(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update mental_health_resources set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update mental_health_resources set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var m models.MentalHealthResource
 	var websiteURL, location, waitingTime, notes *string
 	var lat, lng *float64
@@ -209,14 +209,14 @@ func (h *Handler) PatchMentalHealthResource(c *gin.Context) {
 func (h *Handler) GetMentalHealthResource(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from mental_health_resources where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from ` + h.legacySource("mental_health_resources") + ` where id=$1`+liveCond(c), id)
 	var m models.MentalHealthResource
 	var websiteURL, location, waitingTime, notes *string
 	var lat, lng *float64
 	var capacity *int
 	var targetAudience, specialties, languages []string
 	var created, updated int64
-	if err := row.Scan(&m.ID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated); err != nil {
+	if err := row.Scan(&m.ID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated, &m.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -249,6 +249,11 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 	status := c.Query("status")
 	duration := c.Query("duration_type")
 	serviceFormat := c.Query("service_format")
+	geo, err := parseGeoQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := context.Background()
 	filters := []string{}
 	args := []interface{}{}
@@ -264,21 +269,23 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 		filters = append(filters, "service_format=$"+strconv.Itoa(len(args)+1))
 		args = append(args, serviceFormat)
 	}
-	countQ := "select count(*) from mental_health_resources"
-	dataQ := "select id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from mental_health_resources"
+	filters = appendLiveFilter(c, filters)
+	filters, args = geo.apply(filters, args)
+	countQ := "select count(*) from " + h.legacySource("mental_health_resources")
+	dataQ := "select id,duration_type,name,service_format,service_hours,contact_info,website_url,target_audience,specialties,languages,is_free,location,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,status,capacity,waiting_time,notes,emergency_support,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint," + geo.distanceColumn() + " from " + h.legacySource("mental_health_resources")
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
 		dataQ += where
 	}
 	var total int
-	if err := h.pool.QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	args = append(args, limit, offset)
-	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
-	rows, err := h.pool.Query(ctx, dataQ, args...)
+	dataQ += " order by " + geo.orderBy("updated_at desc") + " limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
+	rows, err := h.db(c).Query(ctx, dataQ, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -292,7 +299,7 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 		var capacity *int
 		var targetAudience, specialties, languages []string
 		var created, updated int64
-		if err := rows.Scan(&m.ID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated); err != nil {
+		if err := rows.Scan(&m.ID, &m.DurationType, &m.Name, &m.ServiceFormat, &m.ServiceHours, &m.ContactInfo, &websiteURL, &targetAudience, &specialties, &languages, &m.IsFree, &location, &lat, &lng, &m.Status, &capacity, &waitingTime, &notes, &m.EmergencySupport, &created, &updated, &m.DeletedAt, &m.DistanceM); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -331,5 +338,5 @@ func (h *Handler) ListMentalHealthResources(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
-	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+	writeLocationCollection(c, list, total, limit, offset, next, prev)
 }
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/middleware"
+	"guangfu250923/internal/pin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// editTarget describes how edits of a resource are authorised. Supply items and supply providers
+// belong to a supply, so its PIN, owner and co-owners apply to them too.
+type editTarget struct {
+	root   string // resource holding the PIN and co-owners
+	pinEnv string // VERIFY_*_PIN switch; "" when the root has no PIN
+	// lookup selects the root id ('' when there is none), its valid_pin and the owner ids of the
+	// row being edited, by the row's id
+	lookup string
+}
+
+var editTargets = map[string]editTarget{
+	"supplies": {root: "supplies", pinEnv: "VERIFY_SUPPLY_PIN",
+		lookup: `select s.id::text,s.valid_pin,array_remove(array[s.owner_user_id::text],null) from supplies s where s.id=$1 and s.deleted_at is null`},
+	"supply_items": {root: "supplies", pinEnv: "VERIFY_SUPPLY_PIN",
+		lookup: `select s.id::text,s.valid_pin,array_remove(array[s.owner_user_id::text],null) from supply_items i join supplies s on s.id=i.supply_id
+			where i.id=$1 and i.deleted_at is null`},
+	"supply_providers": {root: "supplies", pinEnv: "VERIFY_SUPPLY_PIN",
+		lookup: `select coalesce(s.id::text,''),s.valid_pin,array_remove(array[p.owner_user_id::text,s.owner_user_id::text],null) from supply_providers p
+			left join supply_items i on i.id=p.supply_item_id left join supplies s on s.id=i.supply_id where p.id=$1 and p.deleted_at is null`},
+	"human_resources": {root: "human_resources", pinEnv: "VERIFY_HR_PIN",
+		lookup: `select id::text,valid_pin,array_remove(array[owner_user_id::text],null) from human_resources where id=$1 and deleted_at is null`},
+	"places": {root: "places",
+		lookup: `select id::text,null::text,array_remove(array[owner_user_id::text],null) from places where id=$1 and deleted_at is null`},
+}
+
+type editRow struct {
+	target editTarget
+	rootID string
+	pin    *string
+	owners []string
+}
+
+// pinRequired reports whether VERIFY_*_PIN is on for the resource.
+func pinRequired(resource string) bool {
+	t := editTargets[resource]
+	return t.pinEnv != "" && os.Getenv(t.pinEnv) == "true"
+}
+
+func pinMaxAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("PIN_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return 5
+}
+
+func pinLockout() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("PIN_LOCKOUT_MINUTES")); err == nil && n > 0 {
+		return time.Duration(n) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// currentUserID is the owner_user_id new rows get: the signed-in user, or NULL.
+func currentUserID(c *gin.Context) interface{} {
+	if u := middleware.CurrentUser(c); u != nil {
+		return u.ID
+	}
+	return nil
+}
+
+// hashPinInput replaces a plaintext valid_pin from a request with its hash.
+func hashPinInput(p *string) (*string, error) {
+	if p == nil {
+		return nil, nil
+	}
+	h, err := pin.Hash(*p)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (h *Handler) loadEditRow(c *gin.Context, resource, id string) (*editRow, error) {
+	row := &editRow{target: editTargets[resource]}
+	err := h.db(c).QueryRow(context.Background(), row.target.lookup, id).Scan(&row.rootID, &row.pin, &row.owners)
+	return row, err
+}
+
+// ownsRow reports whether the signed-in user owns the row or is a co-owner of its root.
+func (h *Handler) ownsRow(c *gin.Context, row *editRow) (bool, error) {
+	u := middleware.CurrentUser(c)
+	if u == nil {
+		return false, nil
+	}
+	for _, o := range row.owners {
+		if o == u.ID {
+			return true, nil
+		}
+	}
+	if row.rootID == "" {
+		return false, nil
+	}
+	var ok bool
+	err := h.db(c).QueryRow(context.Background(), `select exists(select 1 from co_owners where resource=$1 and entity_id=$2 and user_id=$3)`,
+		row.target.root, row.rootID, u.ID).Scan(&ok)
+	return ok, err
+}
+
+// authorizeEdit is the owner rule of <resource>:update (see internal/policy): requests the route's
+// Require did not grant by role may still edit row id when they come from the signed-in owner or
+// a co-owner or, when VERIFY_*_PIN is on, carry the record's PIN. It answers 403 / 404 / 429
+// itself when not. Wrong PINs are counted per record and client IP; after PIN_MAX_ATTEMPTS the IP
+// is locked out of that record for PIN_LOCKOUT_MINUTES.
+func (h *Handler) authorizeEdit(c *gin.Context, resource, id string, given *string) bool {
+	if middleware.Granted(c) {
+		return true
+	}
+	row, err := h.loadEditRow(c, resource, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	owner, err := h.ownsRow(c, row)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if owner {
+		return true
+	}
+	if pinRequired(resource) && row.pin != nil && strings.TrimSpace(*row.pin) != "" && given != nil {
+		ip := middleware.ClientIP(c)
+		if wait := h.pinLockedFor(row.target.root, row.rootID, ip); wait > 0 {
+			retry := int(wait.Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retry))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many pin attempts", "retry_after": retry})
+			return false
+		}
+		if isValidPin6(given) && pin.Verify(*row.pin, *given) {
+			h.clearPinFailures(row.target.root, row.rootID, ip)
+			return true
+		}
+		h.recordPinFailure(row.target.root, row.rootID, ip)
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid pin"})
+		return false
+	}
+	if pinRequired(resource) && row.pin != nil && strings.TrimSpace(*row.pin) != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid pin"})
+		return false
+	}
+	resp := gin.H{"error": "not the owner"}
+	if fields := middleware.DeniedFields(c); len(fields) > 0 {
+		resp["fields"] = fields
+	}
+	c.JSON(http.StatusForbidden, resp)
+	return false
+}
+
+// pinLockedFor is how long ip stays locked out of the record. PIN attempts are read and written
+// on the pool rather than the request transaction, which rolls back with the 403.
+func (h *Handler) pinLockedFor(resource, id, ip string) time.Duration {
+	var secs *float64
+	err := h.pool.QueryRow(context.Background(), `select extract(epoch from locked_until-now())::float8 from pin_attempts
+		where resource=$1 and entity_id=$2 and ip=$3 and locked_until > now()`, resource, id, ip).Scan(&secs)
+	if err != nil || secs == nil {
+		return 0
+	}
+	return time.Duration(*secs * float64(time.Second))
+}
+
+func (h *Handler) recordPinFailure(resource, id, ip string) {
+	window := pinLockout()
+	_, _ = h.pool.Exec(context.Background(), `insert into pin_attempts(resource,entity_id,ip,failures,last_failed_at,locked_until)
+		values($1,$2,$3,1,now(),case when $5 <= 1 then now()+make_interval(secs => $4) end)
+		on conflict (resource,entity_id,ip) do update set
+			failures=case when pin_attempts.last_failed_at < now()-make_interval(secs => $4) then 1 else pin_attempts.failures+1 end,
+			last_failed_at=now(),
+			locked_until=case when pin_attempts.last_failed_at >= now()-make_interval(secs => $4) and pin_attempts.failures+1 >= $5
+				then now()+make_interval(secs => $4) else pin_attempts.locked_until end`,
+		resource, id, ip, window.Seconds(), pinMaxAttempts())
+}
+
+func (h *Handler) clearPinFailures(resource, id, ip string) {
+	_, _ = h.pool.Exec(context.Background(), `delete from pin_attempts where resource=$1 and entity_id=$2 and ip=$3`, resource, id, ip)
+}
+
+// ownerOnly answers 403 / 404 unless the caller may update the resource outright (by role or
+// <resource>:write key) or is the owner or a co-owner. PIN holders are not enough for managing
+// the PIN and co-owners.
+func (h *Handler) ownerOnly(c *gin.Context, resource, id string) (*editRow, bool) {
+	row, err := h.loadEditRow(c, resource, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if middleware.Can(c, resource+":update") {
+		return row, true
+	}
+	owner, err := h.ownsRow(c, row)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !owner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not the owner"})
+		return nil, false
+	}
+	return row, true
+}
+
+// CoOwnerResources lists the resources that have /{resource}/:id/co_owners routes.
+func CoOwnerResources() []string {
+	return []string{"human_resources", "places", "supplies"}
+}
+
+// ownershipResource is the resource of a /<resource>/:id/... route.
+func ownershipResource(c *gin.Context) string {
+	return strings.Split(strings.TrimPrefix(c.FullPath(), "/"), "/")[0]
+}
+
+type pinResetInput struct {
+	ValidPin *string `json:"valid_pin"` // optional; generated when missing
+}
+
+// ResetPin sets a new PIN on a supply or human resource and returns it once, clearing lockouts.
+// For whoever lost the PIN: the owner (signed in) or a coordinator with <resource>:write does it.
+func (h *Handler) ResetPin(c *gin.Context) {
+	resource := ownershipResource(c)
+	var in pinResetInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if in.ValidPin == nil || strings.TrimSpace(*in.ValidPin) == "" {
+		tmp := GeneratePin(6)
+		in.ValidPin = &tmp
+	} else if !isValidPin6(in.ValidPin) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_pin must be 6 digits, with 1 - 9"})
+		return
+	}
+	id := c.Param("id")
+	if _, ok := h.ownerOnly(c, resource, id); !ok {
+		return
+	}
+	hashed, err := hashPinInput(in.ValidPin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := context.Background()
+	if _, err := h.db(c).Exec(ctx, `update `+resource+` set valid_pin=$2,updated_at=now() where id=$1 and deleted_at is null`, id, hashed); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.db(c).Exec(ctx, `delete from pin_attempts where resource=$1 and entity_id=$2`, resource, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "valid_pin": *in.ValidPin})
+}
+
+type coOwnerInput struct {
+	UserID string `json:"user_id"`
+}
+
+type coOwner struct {
+	UserID      string  `json:"user_id"`
+	DisplayName *string `json:"display_name"`
+	CreatedBy   *string `json:"created_by"`
+	CreatedAt   int64   `json:"created_at"`
+}
+
+// ListCoOwners lists the users who may edit the record besides its owner.
+func (h *Handler) ListCoOwners(c *gin.Context) {
+	resource := ownershipResource(c)
+	id := c.Param("id")
+	if _, ok := h.ownerOnly(c, resource, id); !ok {
+		return
+	}
+	rows, err := h.db(c).Query(context.Background(), `select o.user_id::text,u.display_name,o.created_by::text,extract(epoch from o.created_at)::bigint
+		from co_owners o join users u on u.id=o.user_id where o.resource=$1 and o.entity_id=$2 order by o.created_at`, resource, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []coOwner{}
+	for rows.Next() {
+		var o coOwner
+		if err := rows.Scan(&o.UserID, &o.DisplayName, &o.CreatedBy, &o.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, o)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"member": list, "totalItems": len(list)})
+}
+
+// AddCoOwner lets another signed-in user (by their /auth/me id) edit the record.
+func (h *Handler) AddCoOwner(c *gin.Context) {
+	resource := ownershipResource(c)
+	var in coOwnerInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(in.UserID) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		return
+	}
+	id := c.Param("id")
+	if _, ok := h.ownerOnly(c, resource, id); !ok {
+		return
+	}
+	var o coOwner
+	err := h.db(c).QueryRow(context.Background(), `with ins as (
+			insert into co_owners(resource,entity_id,user_id,created_by) select $1,$2,u.id,$4 from users u where u.id::text=$3
+			on conflict (resource,entity_id,user_id) do update set resource=excluded.resource returning *)
+		select ins.user_id::text,u.display_name,ins.created_by::text,extract(epoch from ins.created_at)::bigint from ins join users u on u.id=ins.user_id`,
+		resource, id, in.UserID, currentUserID(c)).Scan(&o.UserID, &o.DisplayName, &o.CreatedBy, &o.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, o)
+}
+
+// RemoveCoOwner revokes a co-owner's edit access.
+func (h *Handler) RemoveCoOwner(c *gin.Context) {
+	resource := ownershipResource(c)
+	id := c.Param("id")
+	if _, ok := h.ownerOnly(c, resource, id); !ok {
+		return
+	}
+	tag, err := h.db(c).Exec(context.Background(), `delete from co_owners where resource=$1 and entity_id=$2 and user_id::text=$3`, resource, id, c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -74,11 +74,11 @@ func (h *Handler) CreatePlace(c *gin.Context) {
     id := newID.String()
     ctx := context.Background()
     var created, updated int64
-    err := h.pool.QueryRow(ctx, `insert into places(
-        id,name,address,address_description,coordinates,type,sub_type,info_sources,verified_at,website_url,status,resources,open_date,end_date,open_time,end_time,contact_name,contact_phone,notes,tags,additional_info
-    ) values($1,$2,$3,$4,$5::jsonb,$6,$7,$8::text[],$9,$10,$11,$12::jsonb,$13,$14,$15,$16,$17,$18,$19,$20::jsonb,$21::jsonb)
+    err := h.db(c).QueryRow(ctx, `insert into places(
+        id,name,address,address_description,coordinates,type,sub_type,info_sources,verified_at,website_url,status,resources,open_date,end_date,open_time,end_time,contact_name,contact_phone,notes,tags,additional_info,owner_user_id
+    ) values($1,$2,$3,$4,$5::jsonb,$6,$7,$8::text[],$9,$10,$11,$12::jsonb,$13,$14,$15,$16,$17,$18,$19,$20::jsonb,$21::jsonb,$22)
     returning extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-        id, in.Name, in.Address, in.AddressDescription, coordsJSON, in.Type, in.SubType, in.InfoSources, in.VerifiedAt, in.WebsiteURL, in.Status, resourcesJSON, in.OpenDate, in.EndDate, in.OpenTime, in.EndTime, in.ContactName, in.ContactPhone, in.Notes, tagsJSON, addInfoJSON,
+        id, in.Name, in.Address, in.AddressDescription, coordsJSON, in.Type, in.SubType, in.InfoSources, in.VerifiedAt, in.WebsiteURL, in.Status, resourcesJSON, in.OpenDate, in.EndDate, in.OpenTime, in.EndTime, in.ContactName, in.ContactPhone, in.Notes, tagsJSON, addInfoJSON, currentUserID(c),
     ).Scan(&created, &updated)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -100,9 +100,9 @@ func (h *Handler) CreatePlace(c *gin.Context) {
 func (h *Handler) GetPlace(c *gin.Context) {
     id := c.Param("id")
     ctx := context.Background()
-    row := h.pool.QueryRow(ctx, `select id,name,address,address_description,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,
+    row := h.db(c).QueryRow(ctx, `select id,name,address,address_description,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,
         type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,
-        extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from places where id=$1`, id)
+        extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from places where id=$1`+liveCond(c), id)
     var p models.Place
     var addrDesc, subType, websiteURL, notes *string
     var infoSources []string
@@ -112,7 +112,7 @@ func (h *Handler) GetPlace(c *gin.Context) {
     var lat, lng *float64
     var created, updated int64
     var resourcesJSON, tagsJSON, addInfoJSON []byte
-    if err := row.Scan(&p.ID, &p.Name, &p.Address, &addrDesc, &lat, &lng, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated); err != nil {
+    if err := row.Scan(&p.ID, &p.Name, &p.Address, &addrDesc, &lat, &lng, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated, &p.DeletedAt); err != nil {
         if err == pgx.ErrNoRows {
             c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
             return
@@ -160,6 +160,11 @@ func (h *Handler) ListPlaces(c *gin.Context) {
     offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
     status := c.Query("status")
     typ := c.Query("type")
+    geo, err := parseGeoQuery(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
     ctx := context.Background()
     filters := []string{}
     args := []interface{}{}
@@ -171,21 +176,23 @@ func (h *Handler) ListPlaces(c *gin.Context) {
         filters = append(filters, "type=$"+strconv.Itoa(len(args)+1))
         args = append(args, typ)
     }
+    filters = appendLiveFilter(c, filters)
+    filters, args = geo.apply(filters, args)
     countQ := "select count(*) from places"
-    dataQ := "select id,name,address,address_description,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng, type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from places"
+    dataQ := "select id,name,address,address_description,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng, type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint," + geo.distanceColumn() + " from places"
     if len(filters) > 0 {
         where := " where " + strings.Join(filters, " and ")
         countQ += where
         dataQ += where
     }
     var total int
-    if err := h.pool.QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
+    if err := h.db(c).QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
     args = append(args, limit, offset)
-    dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
-    rows, err := h.pool.Query(ctx, dataQ, args...)
+    dataQ += " order by " + geo.orderBy("updated_at desc") + " limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
+    rows, err := h.db(c).Query(ctx, dataQ, args...)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -202,7 +209,7 @@ func (h *Handler) ListPlaces(c *gin.Context) {
         var lat, lng *float64
         var created, updated int64
         var resourcesJSON, tagsJSON, addInfoJSON []byte
-        if err := rows.Scan(&p.ID, &p.Name, &p.Address, &addrDesc, &lat, &lng, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated); err != nil {
+        if err := rows.Scan(&p.ID, &p.Name, &p.Address, &addrDesc, &lat, &lng, &p.Type, &subType, &infoSources, &verifiedAt, &websiteURL, &p.Status, &resourcesJSON, &tagsJSON, &addInfoJSON, &openDate, &endDate, &openTime, &endTime, &contactName, &contactPhone, &created, &updated, &p.DeletedAt, &p.DistanceM); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
@@ -257,7 +264,7 @@ func (h *Handler) ListPlaces(c *gin.Context) {
         s := build(offset - limit)
         prev = &s
     }
-    c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+    writeLocationCollection(c, list, total, limit, offset, next, prev)
 }
 
 type placePatchInput struct {
@@ -290,6 +297,10 @@ func (h *Handler) PatchPlace(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    // granted by role (site coordinators only for operational fields), or the signed-in owner / a co-owner
+    if !h.authorizeEdit(c, "places", id, nil) {
+        return
+    }
     ctx := context.Background()
     setParts := []string{}
     args := []interface{}{}
@@ -319,9 +330,9 @@ func (h *Handler) PatchPlace(c *gin.Context) {
     if in.AdditionalInfo != nil { if b, err := json.Marshal(in.AdditionalInfo); err == nil { setParts = append(setParts, "additional_info=$"+strconv.Itoa(idx)+"::jsonb"); args = append(args, string(b)); idx++ } }
     if len(setParts) == 0 { c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"}); return }
     setParts = append(setParts, "updated_at=now()")
-    query := "update places set "+strings.Join(setParts, ",")+" where id=$"+strconv.Itoa(idx)+" returning id,name,address,address_description,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+    query := "update places set "+strings.Join(setParts, ",")+" where id=$"+strconv.Itoa(idx)+" and deleted_at is null returning id,name,address,address_description,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,type,sub_type,info_sources,verified_at,website_url,status,resources,tags,additional_info,open_date,end_date,open_time,end_time,contact_name,contact_phone,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
     args = append(args, id)
-    row := h.pool.QueryRow(ctx, query, args...)
+    row := h.db(c).QueryRow(ctx, query, args...)
     var p models.Place
     var addrDesc, subType, websiteURL, notes *string
     var infoSources []string
@@ -50,7 +50,7 @@ func (h *Handler) CreateReport(c *gin.Context) {
 		return
 	}
 	id := "incident-" + newUUID.String()
-	row := h.pool.QueryRow(context.Background(), `insert into reports(id,name,location_type,reason,notes,status,location_id) values($1,$2,$3,$4,$5,$6,$7) returning id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`, id, in.Name, in.LocationType, in.Reason, in.Notes, in.Status, in.LocationID)
+	row := h.db(c).QueryRow(context.Background(), `insert into reports(id,name,location_type,reason,notes,status,location_id) values($1,$2,$3,$4,$5,$6,$7) returning id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`, id, in.Name, in.LocationType, in.Reason, in.Notes, in.Status, in.LocationID)
 	var r models.Report
 	var notes *string
 	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt); err != nil {
@@ -68,20 +68,24 @@ func (h *Handler) ListReports(c *gin.Context) {
 	ctx := context.Background()
 	var total int
 	countSQL := `select count(*) from reports`
-	listSQL := `select id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from reports`
+	listSQL := `select id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from reports`
 	args := []interface{}{}
+	filters := appendLiveFilter(c, []string{})
 	if status != "" {
-		countSQL += " where status=$1"
-		listSQL += " where status=$1"
+		filters = append(filters, "status=$1")
 		args = append(args, status)
 	}
+	if len(filters) > 0 {
+		countSQL += " where " + strings.Join(filters, " and ")
+		listSQL += " where " + strings.Join(filters, " and ")
+	}
 	listSQL += " order by updated_at desc limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
 	args = append(args, limit, offset)
-	if err := h.pool.QueryRow(ctx, countSQL, args[:len(args)-2]...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countSQL, args[:len(args)-2]...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	rows, err := h.pool.Query(ctx, listSQL, args...)
+	rows, err := h.db(c).Query(ctx, listSQL, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -91,7 +95,7 @@ func (h *Handler) ListReports(c *gin.Context) {
 	for rows.Next() {
 		var r models.Report
 		var notes *string
-		if err := rows.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -120,10 +124,10 @@ func (h *Handler) ListReports(c *gin.Context) {
 
 func (h *Handler) GetReport(c *gin.Context) {
 	id := c.Param("id")
-	row := h.pool.QueryRow(context.Background(), `select id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from reports where id=$1`, id)
+	row := h.db(c).QueryRow(context.Background(), `select id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from reports where id=$1`+liveCond(c), id)
 	var r models.Report
 	var notes *string
-	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt); err != nil {
+	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -173,9 +177,9 @@ func (h *Handler) PatchReport(c *gin.Context) {
 		return
 	}
 	set = append(set, "updated_at=now()")
-	query := "update reports set " + strings.Join(set, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update reports set " + strings.Join(set, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,name,location_type,reason,notes,status,location_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	row := h.pool.QueryRow(context.Background(), query, args...)
+	row := h.db(c).QueryRow(context.Background(), query, args...)
 	var r models.Report
 	var notes *string
 	if err := row.Scan(&r.ID, &r.Name, &r.LocationType, &r.Reason, &notes, &r.Status, &r.LocationID, &r.CreatedAt, &r.UpdatedAt); err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,6 +19,8 @@ type RequestLog struct {
 	StatusCode *int              `json:"status_code"`
 	Error      *string           `json:"error"`
 	DurationMS *int              `json:"duration_ms"`
+	APIKeyID   *string           `json:"api_key_id"`
+	APIKeyName *string           `json:"api_key_name"`
 	CreatedAt  int64             `json:"created_at"`
 }
 
@@ -25,12 +28,28 @@ func (h *Handler) ListRequestLogs(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 100, 1, 500)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	ctx := context.Background()
+	filters := []string{}
+	args := []interface{}{}
+	if v := c.Query("api_key_id"); v != "" {
+		filters = append(filters, "api_key_id=$"+strconv.Itoa(len(args)+1))
+		args = append(args, v)
+	}
+	if v := c.Query("api_key_name"); v != "" {
+		filters = append(filters, "api_key_name=$"+strconv.Itoa(len(args)+1))
+		args = append(args, v)
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
 	var total int
-	if err := h.pool.QueryRow(ctx, `select count(*) from request_logs`).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, `select count(*) from request_logs`+where, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	rows, err := h.pool.Query(ctx, `select id,method,path,query,ip,headers,status_code,error,duration_ms,extract(epoch from created_at)::bigint from request_logs order by created_at desc limit $1 offset $2`, limit, offset)
+	args = append(args, limit, offset)
+	rows, err := h.db(c).Query(ctx, `select id,method,path,query,ip,headers,status_code,error,duration_ms,api_key_id::text,api_key_name,extract(epoch from created_at)::bigint from request_logs`+where+
+		` order by created_at desc limit $`+strconv.Itoa(len(args)-1)+` offset $`+strconv.Itoa(len(args)), args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -40,7 +59,7 @@ func (h *Handler) ListRequestLogs(c *gin.Context) {
 	for rows.Next() {
 		var rl RequestLog
 		var headersJSON map[string]string
-		if err := rows.Scan(&rl.ID, &rl.Method, &rl.Path, &rl.Query, &rl.IP, &headersJSON, &rl.StatusCode, &rl.Error, &rl.DurationMS, &rl.CreatedAt); err != nil {
+		if err := rows.Scan(&rl.ID, &rl.Method, &rl.Path, &rl.Query, &rl.IP, &headersJSON, &rl.StatusCode, &rl.Error, &rl.DurationMS, &rl.APIKeyID, &rl.APIKeyName, &rl.CreatedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
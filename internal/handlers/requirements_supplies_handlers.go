@@ -23,6 +23,8 @@ type requirementsSuppliesCreateInput struct {
     ReceivedCount int                      `json:"received_count"`
     Tags          []map[string]interface{} `json:"tags"`
     AdditionalInfo map[string]interface{}  `json:"additional_info"`
+    CategoryID    *string                  `json:"category_id"`     // defaults to the category required_type maps to
+    CatalogItemID *string                  `json:"catalog_item_id"` // defaults to the catalog item name maps to
 }
 
 func (h *Handler) CreateRequirementsSupplies(c *gin.Context) {
@@ -30,36 +32,41 @@ func (h *Handler) CreateRequirementsSupplies(c *gin.Context) {
     if err := c.ShouldBindJSON(&in); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
     // verify place exists
     var exists bool
-    if err := h.pool.QueryRow(context.Background(), `select exists(select 1 from places where id=$1)`, in.PlaceID).Scan(&exists); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.db(c).QueryRow(context.Background(), `select exists(select 1 from places where id=$1 and deleted_at is null)`, in.PlaceID).Scan(&exists); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
     if !exists { c.JSON(http.StatusNotFound, gin.H{"error": "not found", "reason": "place not found"}); return }
+    if !h.checkCatalogRefs(c, in.CategoryID, in.CatalogItemID) { return }
     var tagsJSON, addInfoJSON *string
     if in.Tags != nil { if b, err := json.Marshal(in.Tags); err == nil { s := string(b); tagsJSON = &s } }
     if in.AdditionalInfo != nil { if b, err := json.Marshal(in.AdditionalInfo); err == nil { s := string(b); addInfoJSON = &s } }
     newID, _ := uuid.NewV7()
     id := newID.String()
     var created, updated int64
-    err := h.pool.QueryRow(context.Background(), `insert into requirements_supplies(
-        id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info
-    ) values($1,$2,$3,$4,$5,$6,$7,$8::jsonb,$9::jsonb) returning extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-        id, in.PlaceID, in.RequiredType, in.Name, in.Unit, in.RequireCount, in.ReceivedCount, tagsJSON, addInfoJSON,
-    ).Scan(&created, &updated)
+    var normUnit *string
+    var unitFactor *float64
+    err := h.db(c).QueryRow(context.Background(), `insert into requirements_supplies(
+        id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,category_id,catalog_item_id
+    ) values($1,$2,$3,$4,$5,$6,$7,$8::jsonb,$9::jsonb,nullif($10,''),nullif($11,'')) returning unit,`+requirementUnitColumns+`,category_id,catalog_item_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+        id, in.PlaceID, in.RequiredType, in.Name, in.Unit, in.RequireCount, in.ReceivedCount, tagsJSON, addInfoJSON, in.CategoryID, in.CatalogItemID,
+    ).Scan(&in.Unit, &normUnit, &unitFactor, &in.CategoryID, &in.CatalogItemID, &created, &updated) // unit comes back in its catalog spelling, the catalog ids mapped
     if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    out := models.RequirementsSupplies{ID: id, PlaceID: in.PlaceID, RequiredType: in.RequiredType, Name: in.Name, Unit: in.Unit, RequireCount: in.RequireCount, ReceivedCount: in.ReceivedCount, CreatedAt: created, UpdatedAt: updated}
+    out := models.RequirementsSupplies{ID: id, PlaceID: in.PlaceID, RequiredType: in.RequiredType, Name: in.Name, Unit: in.Unit, RequireCount: in.RequireCount, ReceivedCount: in.ReceivedCount, NormalizedUnit: normUnit, UnitFactor: unitFactor, CategoryID: in.CategoryID, CatalogItemID: in.CatalogItemID, CreatedAt: created, UpdatedAt: updated}
     out.Tags = in.Tags; out.AdditionalInfo = in.AdditionalInfo
+    setRequirementUnits(&out)
     c.JSON(http.StatusCreated, out)
 }
 
 func (h *Handler) GetRequirementsSupplies(c *gin.Context) {
     id := c.Param("id")
-    row := h.pool.QueryRow(context.Background(), `select id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from requirements_supplies where id=$1`, id)
+    row := h.db(c).QueryRow(context.Background(), `select id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,`+requirementUnitColumns+`,category_id,catalog_item_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from requirements_supplies where id=$1`+liveCond(c), id)
     var r models.RequirementsSupplies
     var tagsJSON, addInfoJSON []byte
-    if err := row.Scan(&r.ID, &r.PlaceID, &r.RequiredType, &r.Name, &r.Unit, &r.RequireCount, &r.ReceivedCount, &tagsJSON, &addInfoJSON, &r.CreatedAt, &r.UpdatedAt); err != nil {
+    if err := row.Scan(&r.ID, &r.PlaceID, &r.RequiredType, &r.Name, &r.Unit, &r.RequireCount, &r.ReceivedCount, &tagsJSON, &addInfoJSON, &r.NormalizedUnit, &r.UnitFactor, &r.CategoryID, &r.CatalogItemID, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt); err != nil {
         if err == pgx.ErrNoRows { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return
     }
     if len(tagsJSON) > 0 { var arr []map[string]interface{}; _ = json.Unmarshal(tagsJSON, &arr); r.Tags = arr }
     if len(addInfoJSON) > 0 { var m map[string]interface{}; _ = json.Unmarshal(addInfoJSON, &m); r.AdditionalInfo = m }
+    setRequirementUnits(&r)
     c.JSON(http.StatusOK, r)
 }
 
@@ -72,25 +79,30 @@ func (h *Handler) ListRequirementsSupplies(c *gin.Context) {
     args := []interface{}{}
     if placeID != "" { filters = append(filters, "place_id=$"+strconv.Itoa(len(args)+1)); args = append(args, placeID) }
     if reqType != "" { filters = append(filters, "required_type=$"+strconv.Itoa(len(args)+1)); args = append(args, reqType) }
+    for _, f := range []string{"category_id", "catalog_item_id"} {
+        if v := c.Query(f); v != "" { filters = append(filters, f+"=$"+strconv.Itoa(len(args)+1)); args = append(args, v) }
+    }
+    filters = appendLiveFilter(c, filters)
     countQ := "select count(*) from requirements_supplies"
-    dataQ := "select id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from requirements_supplies"
+    dataQ := "select id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,"+requirementUnitColumns+",category_id,catalog_item_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from requirements_supplies"
     if len(filters) > 0 { where := " where "+strings.Join(filters, " and "); countQ += where; dataQ += where }
     var total int
-    if err := h.pool.QueryRow(context.Background(), countQ, args...).Scan(&total); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.db(c).QueryRow(context.Background(), countQ, args...).Scan(&total); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
     args = append(args, limit, offset)
     dataQ += " order by updated_at desc limit $"+strconv.Itoa(len(args)-1)+" offset $"+strconv.Itoa(len(args))
-    rows, err := h.pool.Query(context.Background(), dataQ, args...)
+    rows, err := h.db(c).Query(context.Background(), dataQ, args...)
     if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
     defer rows.Close()
     list := []models.RequirementsSupplies{}
     for rows.Next() {
         var r models.RequirementsSupplies
         var tagsJSON, addInfoJSON []byte
-        if err := rows.Scan(&r.ID, &r.PlaceID, &r.RequiredType, &r.Name, &r.Unit, &r.RequireCount, &r.ReceivedCount, &tagsJSON, &addInfoJSON, &r.CreatedAt, &r.UpdatedAt); err != nil {
+        if err := rows.Scan(&r.ID, &r.PlaceID, &r.RequiredType, &r.Name, &r.Unit, &r.RequireCount, &r.ReceivedCount, &tagsJSON, &addInfoJSON, &r.NormalizedUnit, &r.UnitFactor, &r.CategoryID, &r.CatalogItemID, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return
         }
         if len(tagsJSON) > 0 { var arr []map[string]interface{}; _ = json.Unmarshal(tagsJSON, &arr); r.Tags = arr }
         if len(addInfoJSON) > 0 { var m map[string]interface{}; _ = json.Unmarshal(addInfoJSON, &m); r.AdditionalInfo = m }
+        setRequirementUnits(&r)
         list = append(list, r)
     }
     baseURL := c.Request.URL.Path
@@ -112,12 +124,15 @@ type requirementsSuppliesPatchInput struct {
     ReceivedCount *int                     `json:"received_count"`
     Tags          *[]map[string]interface{} `json:"tags"`
     AdditionalInfo *map[string]interface{}  `json:"additional_info"`
+    CategoryID    *string                  `json:"category_id"`     // "" clears it; changing required_type alone remaps it
+    CatalogItemID *string                  `json:"catalog_item_id"` // "" clears it; changing name alone remaps it
 }
 
 func (h *Handler) PatchRequirementsSupplies(c *gin.Context) {
     id := c.Param("id")
     var in requirementsSuppliesPatchInput
     if err := c.ShouldBindJSON(&in); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if !h.checkCatalogRefs(c, in.CategoryID, in.CatalogItemID) { return }
     setParts := []string{}
     args := []interface{}{}
     idx := 1
@@ -127,21 +142,24 @@ func (h *Handler) PatchRequirementsSupplies(c *gin.Context) {
     if in.Name != nil { add("name=", *in.Name) }
     if in.Unit != nil { add("unit=", *in.Unit) }
     if in.RequireCount != nil { add("require_count=", *in.RequireCount) }
+    if in.CategoryID != nil { setParts = append(setParts, "category_id=nullif($"+strconv.Itoa(idx)+",'')"); args = append(args, *in.CategoryID); idx++ }
+    if in.CatalogItemID != nil { setParts = append(setParts, "catalog_item_id=nullif($"+strconv.Itoa(idx)+",'')"); args = append(args, *in.CatalogItemID); idx++ }
     if in.ReceivedCount != nil { add("received_count=", *in.ReceivedCount) }
     if in.Tags != nil { if b, err := json.Marshal(in.Tags); err == nil { setParts = append(setParts, "tags=$"+strconv.Itoa(idx)+"::jsonb"); args = append(args, string(b)); idx++ } }
     if in.AdditionalInfo != nil { if b, err := json.Marshal(in.AdditionalInfo); err == nil { setParts = append(setParts, "additional_info=$"+strconv.Itoa(idx)+"::jsonb"); args = append(args, string(b)); idx++ } }
     if len(setParts) == 0 { c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"}); return }
     setParts = append(setParts, "updated_at=now()")
-    query := "update requirements_supplies set "+strings.Join(setParts, ",")+" where id=$"+strconv.Itoa(idx)+" returning id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+    query := "update requirements_supplies set "+strings.Join(setParts, ",")+" where id=$"+strconv.Itoa(idx)+" and deleted_at is null returning id,place_id,required_type,name,unit,require_count,received_count,tags,additional_info,"+requirementUnitColumns+",category_id,catalog_item_id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
     args = append(args, id)
-    row := h.pool.QueryRow(context.Background(), query, args...)
+    row := h.db(c).QueryRow(context.Background(), query, args...)
     var r models.RequirementsSupplies
     var tagsJSON, addInfoJSON []byte
-    if err := row.Scan(&r.ID, &r.PlaceID, &r.RequiredType, &r.Name, &r.Unit, &r.RequireCount, &r.ReceivedCount, &tagsJSON, &addInfoJSON, &r.CreatedAt, &r.UpdatedAt); err != nil {
+    if err := row.Scan(&r.ID, &r.PlaceID, &r.RequiredType, &r.Name, &r.Unit, &r.RequireCount, &r.ReceivedCount, &tagsJSON, &addInfoJSON, &r.NormalizedUnit, &r.UnitFactor, &r.CategoryID, &r.CatalogItemID, &r.CreatedAt, &r.UpdatedAt); err != nil {
         if err == pgx.ErrNoRows { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return
     }
     if len(tagsJSON) > 0 { var arr []map[string]interface{}; _ = json.Unmarshal(tagsJSON, &arr); r.Tags = arr }
     if len(addInfoJSON) > 0 { var m map[string]interface{}; _ = json.Unmarshal(addInfoJSON, &m); r.AdditionalInfo = m }
+    setRequirementUnits(&r)
     c.JSON(http.StatusOK, r)
 }
@@ -73,7 +73,7 @@ func (h *Handler) CreateRestroom(c *gin.Context) {
 	ctx := context.Background()
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into restrooms(name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,last_cleaned,facilities,distance_to_disaster_area,notes,info_source,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16::text[],$17,$18,$19,$20::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+	err := h.db(c).QueryRow(ctx, `insert into restrooms(name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,last_cleaned,facilities,distance_to_disaster_area,notes,info_source,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16::text[],$17,$18,$19,$20::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
 		in.Name, in.Address, in.Phone, in.FacilityType, in.OpeningHours, isFree, in.MaleUnits, in.FemaleUnits, in.UnisexUnits, in.AccessibleUnits, hasWater, hasLighting, in.Status, in.Cleanliness, lastCleaned, in.Facilities, in.DistanceToDisasterArea, in.Notes, in.InfoSource, coordsJSON).Scan(&id, &created, &updated)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -200,9 +200,9 @@ func (h *Handler) PatchRestroom(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update restrooms set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update restrooms set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var r models.Restroom
 	var phone, cleanliness, distance, notes, infoSource *string
 	var male, female, unisex, accessible *int
@@ -247,7 +247,7 @@ func (h *Handler) PatchRestroom(c *gin.Context) {
 func (h *Handler) GetRestroom(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from restrooms where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from ` + h.legacySource("restrooms") + ` where id=$1`+liveCond(c), id)
 	var r models.Restroom
 	var phone, cleanliness, distance, notes, infoSource *string
 	var male, female, unisex, accessible *int
@@ -256,7 +256,7 @@ func (h *Handler) GetRestroom(c *gin.Context) {
 	var isFree, hasWater, hasLighting bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&r.ID, &r.Name, &r.Address, &phone, &r.FacilityType, &r.OpeningHours, &isFree, &male, &female, &unisex, &accessible, &hasWater, &hasLighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&r.ID, &r.Name, &r.Address, &phone, &r.FacilityType, &r.OpeningHours, &isFree, &male, &female, &unisex, &accessible, &hasWater, &hasLighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated, &r.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -297,6 +297,11 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 	isFree := c.Query("is_free")
 	hasWater := c.Query("has_water")
 	hasLighting := c.Query("has_lighting")
+	geo, err := parseGeoQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := context.Background()
 	filters := []string{}
 	args := []interface{}{}
@@ -320,21 +325,23 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 		filters = append(filters, "has_lighting=$"+strconv.Itoa(len(args)+1))
 		args = append(args, hasLighting == "true" || hasLighting == "1")
 	}
-	countQ := "select count(*) from restrooms"
-	dataQ := "select id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from restrooms"
+	filters = appendLiveFilter(c, filters)
+	filters, args = geo.apply(filters, args)
+	countQ := "select count(*) from " + h.legacySource("restrooms")
+	dataQ := "select id,name,address,phone,facility_type,opening_hours,is_free,male_units,female_units,unisex_units,accessible_units,has_water,has_lighting,status,cleanliness,extract(epoch from last_cleaned)::bigint,facilities,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint," + geo.distanceColumn() + " from " + h.legacySource("restrooms")
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
 		dataQ += where
 	}
 	var total int
-	if err := h.pool.QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	args = append(args, limit, offset)
-	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
-	rows, err := h.pool.Query(ctx, dataQ, args...)
+	dataQ += " order by " + geo.orderBy("updated_at desc") + " limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
+	rows, err := h.db(c).Query(ctx, dataQ, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -350,7 +357,7 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 		var free, water, lighting bool
 		var lat, lng *float64
 		var created, updated int64
-		if err := rows.Scan(&r.ID, &r.Name, &r.Address, &phone, &r.FacilityType, &r.OpeningHours, &free, &male, &female, &unisex, &accessible, &water, &lighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+		if err := rows.Scan(&r.ID, &r.Name, &r.Address, &phone, &r.FacilityType, &r.OpeningHours, &free, &male, &female, &unisex, &accessible, &water, &lighting, &r.Status, &cleanliness, &lastCleaned, &facilities, &distance, &notes, &infoSource, &lat, &lng, &created, &updated, &r.DeletedAt, &r.DistanceM); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -395,5 +402,5 @@ func (h *Handler) ListRestrooms(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
-	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+	writeLocationCollection(c, list, total, limit, offset, next, prev)
 }
@@ -51,7 +51,7 @@ func (h *Handler) CreateShelter(c *gin.Context) {
 	ctx := context.Background()
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into shelters(name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,opening_hours,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9::text[],$10,$11,$12,$13::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+	err := h.db(c).QueryRow(ctx, `insert into shelters(name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,opening_hours,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9::text[],$10,$11,$12,$13::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
 		in.Name, in.Location, in.Phone, in.Link, in.Status, in.Capacity, in.CurrentOccupancy, in.AvailableSpaces, in.Facilities, in.ContactPerson, in.Notes, in.OpeningHours, coordsJSON).Scan(&id, &created, &updated)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -66,21 +66,35 @@ func (h *Handler) ListShelters(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	status := c.Query("status")
+	geo, err := parseGeoQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := context.Background()
-	var total int
+	filters := []string{}
+	args := []interface{}{}
 	if status != "" {
-		h.pool.QueryRow(ctx, `select count(*) from shelters where status=$1`, status).Scan(&total)
-	} else {
-		h.pool.QueryRow(ctx, `select count(*) from shelters`).Scan(&total)
+		filters = append(filters, "status=$"+strconv.Itoa(len(args)+1))
+		args = append(args, status)
 	}
-	base := `select id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shelters`
-	var rows pgx.Rows
-	var err error
-	if status != "" {
-		rows, err = h.pool.Query(ctx, base+` where status=$1 order by updated_at desc limit $2 offset $3`, status, limit, offset)
-	} else {
-		rows, err = h.pool.Query(ctx, base+` order by updated_at desc limit $1 offset $2`, limit, offset)
+	filters = appendLiveFilter(c, filters)
+	filters, args = geo.apply(filters, args)
+	countQ := "select count(*) from " + h.legacySource("shelters")
+	dataQ := "select id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint," + geo.distanceColumn() + " from " + h.legacySource("shelters")
+	if len(filters) > 0 {
+		where := " where " + strings.Join(filters, " and ")
+		countQ += where
+		dataQ += where
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	args = append(args, limit, offset)
+	dataQ += " order by " + geo.orderBy("updated_at desc") + " limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
+	rows, err := h.db(c).Query(ctx, dataQ, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -94,7 +108,7 @@ func (h *Handler) ListShelters(c *gin.Context) {
 		var facilities []string
 		var lat, lng *float64
 		var created, updated int64
-		if err = rows.Scan(&s.ID, &s.Name, &s.Location, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &created, &updated); err != nil {
+		if err = rows.Scan(&s.ID, &s.Name, &s.Location, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &created, &updated, &s.DeletedAt, &s.DistanceM); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -133,20 +147,20 @@ func (h *Handler) ListShelters(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
-	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+	writeLocationCollection(c, list, total, limit, offset, next, prev)
 }
 
 func (h *Handler) GetShelter(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shelters where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from ` + h.legacySource("shelters") + ` where id=$1`+liveCond(c), id)
 	var s models.Shelter
 	var link, contactPerson, notes, opening *string
 	var capacity, currentOcc, avail *int
 	var facilities []string
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &s.Name, &s.Location, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &s.Name, &s.Location, &s.Phone, &link, &s.Status, &capacity, &currentOcc, &avail, &facilities, &contactPerson, &notes, &lat, &lng, &opening, &created, &updated, &s.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -259,9 +273,9 @@ func (h *Handler) PatchShelter(c *gin.Context) {
 	}
 	// always update updated_at
 	setParts = append(setParts, "updated_at=now()")
-	query := "update shelters set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update shelters set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,name,location,phone,link,status,capacity,current_occupancy,available_spaces,facilities,contact_person,notes,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,opening_hours,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var s models.Shelter
 	var link, contactPerson, notes, opening *string
 	var capacity, currentOcc, avail *int
@@ -68,7 +68,7 @@ func (h *Handler) CreateShowerStation(c *gin.Context) {
 	}
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into shower_stations(name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,coordinates) values($1,$2,$3,$4,$5,$6::jsonb,$7,$8,$9,$10,$11,$12,$13,$14::text[],$15,$16,$17,$18::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+	err := h.db(c).QueryRow(ctx, `insert into shower_stations(name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,coordinates) values($1,$2,$3,$4,$5,$6::jsonb,$7,$8,$9,$10,$11,$12,$13,$14::text[],$15,$16,$17,$18::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
 		in.Name, in.Address, in.Phone, in.FacilityType, in.TimeSlots, genderJSON, in.AvailablePeriod, in.Capacity, isFree, in.Pricing, in.Notes, in.InfoSource, in.Status, in.Facilities, in.DistanceToGuangfu, reqApp, in.ContactMethod, coordsJSON).Scan(&id, &created, &updated)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -192,9 +192,9 @@ func (h *Handler) PatchShowerStation(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update shower_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update shower_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var s models.ShowerStation
 	var phone, pricing, notes, infoSource, distance, contactMethod *string
 	var genderJSON []byte
@@ -244,7 +244,7 @@ func (h *Handler) PatchShowerStation(c *gin.Context) {
 func (h *Handler) GetShowerStation(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shower_stations where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from ` + h.legacySource("shower_stations") + ` where id=$1`+liveCond(c), id)
 	var s models.ShowerStation
 	var phone, pricing, notes, infoSource, distance, contactMethod *string
 	var genderJSON []byte
@@ -254,7 +254,7 @@ func (h *Handler) GetShowerStation(c *gin.Context) {
 	var reqApp bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &s.Name, &s.Address, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &isFree, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &s.Name, &s.Address, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &isFree, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated, &s.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -298,6 +298,11 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 	facilityType := c.Query("facility_type")
 	isFree := c.Query("is_free")
 	requiresApp := c.Query("requires_appointment")
+	geo, err := parseGeoQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := context.Background()
 	filters := []string{}
 	args := []interface{}{}
@@ -319,21 +324,23 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 		val := (requiresApp == "true" || requiresApp == "1")
 		args = append(args, val)
 	}
-	countQ := "select count(*) from shower_stations"
-	dataQ := "select id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from shower_stations"
+	filters = appendLiveFilter(c, filters)
+	filters, args = geo.apply(filters, args)
+	countQ := "select count(*) from " + h.legacySource("shower_stations")
+	dataQ := "select id,name,address,phone,facility_type,time_slots,gender_schedule,available_period,capacity,is_free,pricing,notes,info_source,status,facilities,distance_to_guangfu,requires_appointment,contact_method,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint," + geo.distanceColumn() + " from " + h.legacySource("shower_stations")
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
 		dataQ += where
 	}
 	var total int
-	if err := h.pool.QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	args = append(args, limit, offset)
-	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
-	rows, err := h.pool.Query(ctx, dataQ, args...)
+	dataQ += " order by " + geo.orderBy("updated_at desc") + " limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
+	rows, err := h.db(c).Query(ctx, dataQ, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -350,7 +357,7 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 		var reqApp bool
 		var lat, lng *float64
 		var created, updated int64
-		if err := rows.Scan(&s.ID, &s.Name, &s.Address, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &free, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated); err != nil {
+		if err := rows.Scan(&s.ID, &s.Name, &s.Address, &phone, &s.FacilityType, &s.TimeSlots, &genderJSON, &s.AvailablePeriod, &capacity, &free, &pricing, &notes, &infoSource, &s.Status, &facilities, &distance, &reqApp, &contactMethod, &lat, &lng, &created, &updated, &s.DeletedAt, &s.DistanceM); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -399,5 +406,5 @@ func (h *Handler) ListShowerStations(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
-	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+	writeLocationCollection(c, list, total, limit, offset, next, prev)
 }
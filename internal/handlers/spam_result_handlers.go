@@ -50,7 +50,7 @@ func (h *Handler) CreateSpamResult(c *gin.Context) {
 	}
 	validatedAt := time.Now().Unix()
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `insert into spam_result(id,target_id,target_type,target_data,is_spam,judgment,validated_at) values($1,$2,$3,$4,$5,$6,$7) returning id,target_id,target_type,target_data,is_spam,judgment,validated_at`,
+	row := h.db(c).QueryRow(ctx, `insert into spam_result(id,target_id,target_type,target_data,is_spam,judgment,validated_at) values($1,$2,$3,$4,$5,$6,$7) returning id,target_id,target_type,target_data,is_spam,judgment,validated_at`,
 		newUUID.String(), in.TargetID, in.TargetType, in.TargetData, in.IsSpam, in.Judgment, validatedAt)
 	var sr models.SpamResult
 	if err := row.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt); err != nil {
@@ -84,8 +84,9 @@ func (h *Handler) ListSpamResults(c *gin.Context) {
 		args = append(args, isSpamStr == "true")
 	}
 
+	filters = appendLiveFilter(c, filters)
 	countSQL := `select count(*) from spam_result`
-	listSQL := `select id,target_id,target_type,target_data,is_spam,judgment,validated_at from spam_result`
+	listSQL := `select id,target_id,target_type,target_data,is_spam,judgment,validated_at,extract(epoch from deleted_at)::bigint from spam_result`
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countSQL += where
@@ -93,7 +94,7 @@ func (h *Handler) ListSpamResults(c *gin.Context) {
 	}
 
 	var total int
-	if err := h.pool.QueryRow(ctx, countSQL, args...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countSQL, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -101,7 +102,7 @@ func (h *Handler) ListSpamResults(c *gin.Context) {
 	listSQL += " order by validated_at desc limit $" + strconv.Itoa(len(args)+1) + " offset $" + strconv.Itoa(len(args)+2)
 	args = append(args, limit, offset)
 
-	rows, err := h.pool.Query(ctx, listSQL, args...)
+	rows, err := h.db(c).Query(ctx, listSQL, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -111,7 +112,7 @@ func (h *Handler) ListSpamResults(c *gin.Context) {
 	list := []models.SpamResult{}
 	for rows.Next() {
 		var sr models.SpamResult
-		if err := rows.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt); err != nil {
+		if err := rows.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt, &sr.DeletedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -141,9 +142,9 @@ func (h *Handler) ListSpamResults(c *gin.Context) {
 func (h *Handler) GetSpamResult(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,target_id,target_type,target_data,is_spam,judgment,validated_at from spam_result where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,target_id,target_type,target_data,is_spam,judgment,validated_at,extract(epoch from deleted_at)::bigint from spam_result where id=$1`+liveCond(c), id)
 	var sr models.SpamResult
-	if err := row.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt); err != nil {
+	if err := row.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt, &sr.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -182,10 +183,10 @@ func (h *Handler) PatchSpamResult(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 		return
 	}
-	query := "update spam_result set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,target_id,target_type,target_data,is_spam,judgment,validated_at"
+	query := "update spam_result set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,target_id,target_type,target_data,is_spam,judgment,validated_at"
 	args = append(args, id)
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var sr models.SpamResult
 	if err := row.Scan(&sr.ID, &sr.TargetID, &sr.TargetType, &sr.TargetData, &sr.IsSpam, &sr.Judgment, &sr.ValidatedAt); err != nil {
 		if err == pgx.ErrNoRows {
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// needsDims are the ways GET /stats/needs can group outstanding needs, with the needs_summary
+// columns each one adds to the output.
+var needsDims = []struct {
+	name    string
+	columns []string
+}{
+	{"kind", []string{"kind"}},
+	{"category", []string{"category_id", "category"}},
+	{"item", []string{"catalog_item_id", "item"}},
+	{"place", []string{"place_id", "place"}},
+	{"township", []string{"township"}},
+	{"status", []string{"status"}},
+}
+
+// needsStatuses are the fulfilment statuses of a need in needs_summary.
+var needsStatuses = []string{"unmet", "partial", "met"}
+
+// needsKinds are the tables needs_summary aggregates.
+var needsKinds = []string{"supply_items", "requirements_supplies", "requirements_hr"}
+
+// wantsCSV reports whether the client asked for CSV (?format=csv or Accept: text/csv).
+func wantsCSV(c *gin.Context) bool {
+	if f := c.Query("format"); f != "" {
+		return strings.EqualFold(f, "csv")
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/csv")
+}
+
+// splitList returns the non-empty, trimmed entries of a comma separated query value.
+func splitList(raw string) []string {
+	out := []string{}
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// NeedsStats aggregates what is still missing across supply items, place supply requirements and
+// place HR requirements (GET /stats/needs). Rows are grouped by the group_by dimensions plus unit,
+// so quantities in different units are never added up. It reads the needs_summary materialized
+// view, which is refreshed in the background; refreshed_at says how current it is.
+func (h *Handler) NeedsStats(c *gin.Context) {
+	groupBy := splitList(c.DefaultQuery("group_by", "category,item"))
+	cols := []string{}
+	for i, g := range groupBy {
+		if slices.Contains(groupBy[:i], g) {
+			continue
+		}
+		found := false
+		for _, d := range needsDims {
+			if d.name == g {
+				cols = append(cols, d.columns...)
+				found = true
+			}
+		}
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown group_by " + g + " (kind, category, item, place, township, status)"})
+			return
+		}
+	}
+	filters := []string{}
+	args := []interface{}{}
+	for _, f := range []struct {
+		param, column string
+		allowed       []string
+	}{
+		{"kind", "kind", needsKinds},
+		{"status", "status", needsStatuses},
+		{"category_id", "category_id", nil},
+		{"catalog_item_id", "catalog_item_id", nil},
+		{"place_id", "place_id", nil},
+		{"township", "township", nil},
+	} {
+		values := splitList(c.Query(f.param))
+		if len(values) == 0 {
+			continue
+		}
+		for _, v := range values {
+			if f.allowed != nil && !slices.Contains(f.allowed, v) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + f.param + " " + v, "allowed": f.allowed})
+				return
+			}
+		}
+		filters = append(filters, f.column+"=any($"+strconv.Itoa(len(args)+1)+")")
+		args = append(args, values)
+	}
+	// the time window applies to when needs were posted, to the hour
+	for _, f := range []struct{ param, cond string }{
+		{"since", "bucket>=date_trunc('hour',to_timestamp($%d))"},
+		{"until", "bucket<to_timestamp($%d)"},
+	} {
+		raw := c.Query(f.param)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": f.param + " must be epoch seconds"})
+			return
+		}
+		filters = append(filters, fmt.Sprintf(f.cond, len(args)+1))
+		args = append(args, v)
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	groupCols := append(append([]string{}, cols...), "unit")
+	query := `select ` + strings.Join(groupCols, ",") + `,bool_or(unit_review),sum(needs)::int,sum(required),sum(received),sum(missing)
+		from needs_summary` + where + ` group by ` + strings.Join(groupCols, ",")
+	// ?outstanding=true leaves out groups with nothing missing
+	if c.Query("outstanding") == "true" {
+		query += ` having sum(missing)>0`
+	}
+	ctx := context.Background()
+	var refreshedAt int64
+	if err := h.db(c).QueryRow(ctx, `select extract(epoch from refreshed_at)::bigint from stats_refreshes where name='needs_summary'`).Scan(&refreshedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	csvOut := wantsCSV(c)
+	limit := parsePositiveInt(c.Query("limit"), 100, 1, 1000)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	var total int
+	if err := h.db(c).QueryRow(ctx, `select count(*) from (`+query+`) g`, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	query += ` order by sum(missing) desc, ` + strings.Join(groupCols, ",")
+	if !csvOut { // the export has every group
+		args = append(args, limit, offset)
+		query += ` limit $` + strconv.Itoa(len(args)-1) + ` offset $` + strconv.Itoa(len(args))
+	}
+	rows, err := h.db(c).Query(ctx, query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	header := append(append([]string{}, groupCols...), "unit_review", "needs", "required", "received", "missing")
+	list := []gin.H{}
+	for rows.Next() {
+		dims := make([]*string, len(groupCols))
+		dest := make([]interface{}, 0, len(header))
+		for i := range dims {
+			dest = append(dest, &dims[i])
+		}
+		var unitReview bool
+		var needs int
+		var required, received, missing float64
+		dest = append(dest, &unitReview, &needs, &required, &received, &missing)
+		if err := rows.Scan(dest...); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		row := gin.H{}
+		for i, col := range groupCols {
+			row[col] = dims[i]
+		}
+		row["unit_review"] = unitReview
+		row["needs"] = needs
+		row["required"] = math.Round(required*1000) / 1000
+		row["received"] = math.Round(received*1000) / 1000
+		row["missing"] = math.Round(missing*1000) / 1000
+		list = append(list, row)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if csvOut {
+		writeNeedsCSV(c, header, list)
+		return
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prev *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		s := build(max(offset-limit, 0))
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev,
+		"group_by": groupBy, "refreshed_at": refreshedAt})
+}
+
+// writeNeedsCSV writes the groups of NeedsStats as CSV, one column per header entry.
+func writeNeedsCSV(c *gin.Context, header []string, list []gin.H) {
+	var b strings.Builder
+	// a BOM so spreadsheet apps read the Chinese names as UTF-8
+	b.WriteString("\ufeff")
+	w := csv.NewWriter(&b)
+	_ = w.Write(header)
+	for _, row := range list {
+		rec := make([]string, len(header))
+		for i, col := range header {
+			switch v := row[col].(type) {
+			case *string:
+				if v != nil {
+					rec[i] = *v
+				}
+			case bool:
+				rec[i] = strconv.FormatBool(v)
+			case int:
+				rec[i] = strconv.Itoa(v)
+			case float64:
+				rec[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		_ = w.Write(rec)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="needs.csv"`)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(b.String()))
+}
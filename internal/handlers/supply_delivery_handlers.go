@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"guangfu250923/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// SupplyDelivery is one supply_deliveries entry (see migration 0011_supply_deliveries): a delivery,
+// the reversal of a mistaken entry, or an adjustment of received_count made outside the ledger.
+type SupplyDelivery struct {
+	ID           string       `json:"id"`
+	SupplyItemID string       `json:"supply_item_id"`
+	Kind         string       `json:"kind"`
+	Quantity     int          `json:"quantity"`
+	Unit         *string      `json:"unit"`
+	ProviderID   *string      `json:"provider_id"`
+	DeliveredAt  int64        `json:"delivered_at"`
+	Note         *string      `json:"note"`
+	ReversesID   *string      `json:"reverses_id"`
+	ReversedBy   *string      `json:"reversed_by"`
+	Actor        HistoryActor `json:"actor"`
+	CreatedAt    int64        `json:"created_at"`
+}
+
+const supplyDeliveryColumns = `d.id,d.supply_item_id,d.kind,d.quantity,d.unit,d.provider_id,extract(epoch from d.delivered_at)::bigint,d.note,d.reverses_id,
+	(select r.id from supply_deliveries r where r.reverses_id=d.id),d.actor_ip,d.actor_key,extract(epoch from d.created_at)::bigint`
+
+func scanSupplyDelivery(row pgx.Row) (SupplyDelivery, error) {
+	var d SupplyDelivery
+	err := row.Scan(&d.ID, &d.SupplyItemID, &d.Kind, &d.Quantity, &d.Unit, &d.ProviderID, &d.DeliveredAt, &d.Note, &d.ReversesID,
+		&d.ReversedBy, &d.Actor.IP, &d.Actor.APIKey, &d.CreatedAt)
+	return d, err
+}
+
+// ListSupplyDeliveries lists the ledger of a supply item, latest delivery first. Actor IPs are
+// masked unless the caller may audit supply items.
+func (h *Handler) ListSupplyDeliveries(c *gin.Context) {
+	id := c.Param("id")
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := context.Background()
+	var exists bool
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from supply_items where id=$1`+liveCond(c)+`)`, id).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, `select count(*) from supply_deliveries where supply_item_id=$1`, id).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rows, err := h.db(c).Query(ctx, `select `+supplyDeliveryColumns+` from supply_deliveries d where d.supply_item_id=$1
+		order by d.delivered_at desc, d.created_at desc limit $2 offset $3`, id, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	showIP := middleware.Can(c, "supply_items:audit")
+	list := []SupplyDelivery{}
+	for rows.Next() {
+		d, err := scanSupplyDelivery(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if d.Actor.IP != nil && !showIP {
+			m := maskIP(*d.Actor.IP)
+			d.Actor.IP = &m
+		}
+		list = append(list, d)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prev *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		po := offset - limit
+		if po < 0 {
+			po = 0
+		}
+		s := build(po)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+type supplyDeliveryReverseInput struct {
+	Note     *string `json:"note"`
+	ValidPin *string `json:"valid_pin"` // PIN of the supply the item belongs to
+}
+
+// ReverseSupplyDelivery undoes a mistaken ledger entry by appending a reversal of the opposite
+// quantity; the entry itself stays. Each entry can be reversed once, reversals not at all.
+func (h *Handler) ReverseSupplyDelivery(c *gin.Context) {
+	id := c.Param("id")
+	var in supplyDeliveryReverseInput
+	if err := c.ShouldBindJSON(&in); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorizeEdit(c, "supply_items", id, in.ValidPin) {
+		return
+	}
+	ctx := context.Background()
+	var received, total int
+	if err := h.db(c).QueryRow(ctx, `select received_count,total_number from supply_items where id=$1 and deleted_at is null for update`, id).Scan(&received, &total); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	orig, err := scanSupplyDelivery(h.db(c).QueryRow(ctx, `select `+supplyDeliveryColumns+` from supply_deliveries d where d.id=$1 and d.supply_item_id=$2`, c.Param("delivery_id"), id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if orig.Kind == "reversal" {
+		c.JSON(http.StatusConflict, gin.H{"error": "a reversal cannot be reversed"})
+		return
+	}
+	if orig.ReversedBy != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "already reversed", "reversed_by": *orig.ReversedBy})
+		return
+	}
+	if after := received - orig.Quantity; after < 0 || after > total {
+		c.JSON(http.StatusConflict, gin.H{"error": "reversal would put recieved_count out of range", "recieved_count": received, "total_count": total, "attempt_add": -orig.Quantity})
+		return
+	}
+	var newID string
+	if err := h.db(c).QueryRow(ctx, `insert into supply_deliveries(supply_item_id,kind,quantity,unit,provider_id,note,reverses_id)
+		values($1,'reversal',$2,$3,$4,$5,$6) returning id`, id, -orig.Quantity, orig.Unit, orig.ProviderID, in.Note, orig.ID).Scan(&newID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	d, err := scanSupplyDelivery(h.db(c).QueryRow(ctx, `select `+supplyDeliveryColumns+` from supply_deliveries d where d.id=$1`, newID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, d)
+}
@@ -3,8 +3,8 @@ package handlers
 import (
 	"context"
 	"guangfu250923/internal/models"
+	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
@@ -29,14 +29,18 @@ type supplyItemInline struct {
 	ReceivedCount *int    `json:"recieved_count"` // 注意: 前端拼字 recieved_count
 	TotalCount    int     `json:"total_count" binding:"required"`
 	Unit          *string `json:"unit"`
+	CategoryID    *string `json:"category_id"`     // defaults to the category tag maps to
+	CatalogItemID *string `json:"catalog_item_id"` // defaults to the catalog item name maps to
 }
 
 type supplyItemCreateInput struct { // 保留原獨立建立 endpoint 使用
-	SupplyID   string  `json:"supply_id" binding:"required"`
-	Tag        *string `json:"tag"`
-	Name       *string `json:"name"`
-	TotalCount int     `json:"total_count" binding:"required"`
-	Unit       *string `json:"unit"`
+	SupplyID      string  `json:"supply_id" binding:"required"`
+	Tag           *string `json:"tag"`
+	Name          *string `json:"name"`
+	TotalCount    int     `json:"total_count" binding:"required"`
+	Unit          *string `json:"unit"`
+	CategoryID    *string `json:"category_id"`
+	CatalogItemID *string `json:"catalog_item_id"`
 }
 
 func (h *Handler) CreateSupply(c *gin.Context) {
@@ -53,8 +57,13 @@ func (h *Handler) CreateSupply(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_pin must be 6 digits"})
 		return
 	}
+	pinHash, err := hashPinInput(in.ValidPin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := context.Background()
-	tx, err := h.pool.Begin(ctx)
+	tx, err := h.db(c).Begin(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -62,12 +71,15 @@ func (h *Handler) CreateSupply(c *gin.Context) {
 	defer tx.Rollback(ctx)
 	var id string
 	var created, updated int64
-	if err := tx.QueryRow(ctx, `insert into supplies(name,address,phone,notes,pii_date,valid_pin) values($1,$2,$3,$4,$5,$6) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`, in.Name, in.Address, in.Phone, in.Notes, in.PiiDate, in.ValidPin).Scan(&id, &created, &updated); err != nil {
+	if err := tx.QueryRow(ctx, `insert into supplies(name,address,phone,notes,pii_date,valid_pin,owner_user_id) values($1,$2,$3,$4,$5,$6,$7) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`, in.Name, in.Address, in.Phone, in.Notes, in.PiiDate, pinHash, currentUserID(c)).Scan(&id, &created, &updated); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	var createdItems []models.SupplyItem
 	if in.Supplies != nil {
+		if !h.checkCatalogRefs(c, in.Supplies.CategoryID, in.Supplies.CatalogItemID) {
+			return
+		}
 		received := 0
 		if in.Supplies.ReceivedCount != nil {
 			received = *in.Supplies.ReceivedCount
@@ -76,12 +88,14 @@ func (h *Handler) CreateSupply(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "recieved_count cannot exceed total_count"})
 			return
 		}
-		var itemID string
-		if err := tx.QueryRow(ctx, `insert into supply_items(supply_id,tag,name,received_count,total_number,unit) values($1,$2,$3,$4,$5,$6) returning id`, id, in.Supplies.Tag, in.Supplies.Name, received, in.Supplies.TotalCount, in.Supplies.Unit).Scan(&itemID); err != nil {
+		it := models.SupplyItem{SupplyID: id, Tag: in.Supplies.Tag, Name: in.Supplies.Name, ReceivedCount: received, TotalCount: in.Supplies.TotalCount}
+		if err := tx.QueryRow(ctx, `insert into supply_items(supply_id,tag,name,received_count,total_number,unit,category_id,catalog_item_id) values($1,$2,$3,$4,$5,$6,nullif($7,''),nullif($8,''))
+			returning id,unit,`+itemUnitColumns+`,category_id,catalog_item_id`, id, in.Supplies.Tag, in.Supplies.Name, received, in.Supplies.TotalCount, in.Supplies.Unit, in.Supplies.CategoryID, in.Supplies.CatalogItemID).Scan(&it.ID, &it.Unit, &it.NormalizedUnit, &it.UnitFactor, &it.CategoryID, &it.CatalogItemID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		createdItems = append(createdItems, models.SupplyItem{ID: itemID, SupplyID: id, Tag: in.Supplies.Tag, Name: in.Supplies.Name, ReceivedCount: received, TotalCount: in.Supplies.TotalCount, Unit: in.Supplies.Unit})
+		setOutstanding(&it)
+		createdItems = append(createdItems, it)
 	}
 	if err := tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -96,12 +110,16 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	embed := c.Query("embed")
 	ctx := context.Background()
+	where := ""
+	if !includeDeleted(c) {
+		where = " where deleted_at is null"
+	}
 	var total int
-	if err := h.pool.QueryRow(ctx, `select count(*) from supplies`).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, `select count(*) from supplies`+where).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	rows, err := h.pool.Query(ctx, `select id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supplies order by updated_at desc limit $1 offset $2`, limit, offset)
+	rows, err := h.db(c).Query(ctx, `select id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from supplies`+where+` order by updated_at desc limit $1 offset $2`, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -113,7 +131,7 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 		var name, addr, phone, notes *string
 		var piiDate *int64
 		var created, updated int64
-		if err := rows.Scan(&s.ID, &name, &addr, &phone, &notes, &piiDate, &created, &updated); err != nil {
+		if err := rows.Scan(&s.ID, &name, &addr, &phone, &notes, &piiDate, &created, &updated, &s.DeletedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -153,8 +171,8 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 			placeholders[i] = "$" + strconv.Itoa(i+1)
 			argsItems[i] = s.ID
 		}
-		query := "select id,supply_id,tag,name,received_count,total_number,unit from supply_items where supply_id in (" + strings.Join(placeholders, ",") + ") order by supply_id,id asc"
-		rowsIt, err := h.pool.Query(ctx, query, argsItems...)
+		query := "select id,supply_id,tag,name,received_count,total_number,unit," + supplyItemPledged + "," + itemUnitColumns + ",category_id,catalog_item_id,extract(epoch from deleted_at)::bigint from supply_items where supply_id in (" + strings.Join(placeholders, ",") + ")" + liveCond(c) + " order by supply_id,id asc"
+		rowsIt, err := h.db(c).Query(ctx, query, argsItems...)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -162,7 +180,7 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 		for rowsIt.Next() {
 			var it models.SupplyItem
 			var tag, name, unit *string
-			if err := rowsIt.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+			if err := rowsIt.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit, &it.PledgedCount, &it.NormalizedUnit, &it.UnitFactor, &it.CategoryID, &it.CatalogItemID, &it.DeletedAt); err != nil {
 				rowsIt.Close()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
@@ -170,6 +188,7 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 			it.Tag = tag
 			it.Name = name
 			it.Unit = unit
+			setOutstanding(&it)
 			itemsMap[it.SupplyID] = append(itemsMap[it.SupplyID], it)
 		}
 		rowsIt.Close()
@@ -184,7 +203,7 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 				suppliesArr = []interface{}{}
 			}
 		}
-		wrapped = append(wrapped, gin.H{
+		entry := gin.H{
 			"id":         s.ID,
 			"name":       s.Name,
 			"address":    s.Address,
@@ -194,7 +213,11 @@ func (h *Handler) ListSupplies(c *gin.Context) {
 			"created_at": s.CreatedAt,
 			"updated_at": s.UpdatedAt,
 			"supplies":   suppliesArr,
-		})
+		}
+		if s.DeletedAt != nil {
+			entry["deleted_at"] = *s.DeletedAt
+		}
+		wrapped = append(wrapped, entry)
 	}
 	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": wrapped, "limit": limit, "offset": offset, "next": next, "previous": prev})
 }
@@ -203,12 +226,12 @@ func (h *Handler) GetSupply(c *gin.Context) {
 	id := c.Param("id")
 	filterOutComplete := c.Query("filterOutComplete") == "true"
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supplies where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from supplies where id=$1`+liveCond(c), id)
 	var s models.Supply
 	var name, addr, phone, notes *string
 	var piiDate *int64
 	var created, updated int64
-	if err := row.Scan(&s.ID, &name, &addr, &phone, &notes, &piiDate, &created, &updated); err != nil {
+	if err := row.Scan(&s.ID, &name, &addr, &phone, &notes, &piiDate, &created, &updated, &s.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -224,12 +247,12 @@ func (h *Handler) GetSupply(c *gin.Context) {
 	s.CreatedAt = created
 	s.UpdatedAt = updated
 	// fetch items: if filterOutComplete=true, filter out completed items (received_count == total_number)
-	query := `select id,supply_id,tag,name,received_count,total_number,unit from supply_items where supply_id=$1`
+	query := `select id,supply_id,tag,name,received_count,total_number,unit,` + supplyItemPledged + `,` + itemUnitColumns + `,category_id,catalog_item_id,extract(epoch from deleted_at)::bigint from supply_items where supply_id=$1` + liveCond(c)
 	if filterOutComplete {
 		query += ` and received_count < total_number`
 	}
 	query += ` order by id asc`
-	rows, err := h.pool.Query(ctx, query, s.ID)
+	rows, err := h.db(c).Query(ctx, query, s.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -239,16 +262,20 @@ func (h *Handler) GetSupply(c *gin.Context) {
 	for rows.Next() {
 		var it models.SupplyItem
 		var tag, iname, unit *string
-		if err := rows.Scan(&it.ID, &it.SupplyID, &tag, &iname, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+		if err := rows.Scan(&it.ID, &it.SupplyID, &tag, &iname, &it.ReceivedCount, &it.TotalCount, &unit, &it.PledgedCount, &it.NormalizedUnit, &it.UnitFactor, &it.CategoryID, &it.CatalogItemID, &it.DeletedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		it.Tag = tag
 		it.Name = iname
 		it.Unit = unit
+		setOutstanding(&it)
 		items = append(items, it)
 	}
 	resp := gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Supply", "id": s.ID, "name": s.Name, "address": s.Address, "phone": s.Phone, "notes": s.Notes, "pii_date": s.PiiDate, "created_at": s.CreatedAt, "updated_at": s.UpdatedAt, "supplies": items}
+	if s.DeletedAt != nil {
+		resp["deleted_at"] = *s.DeletedAt
+	}
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -268,25 +295,10 @@ func (h *Handler) PatchSupply(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// Optional verification (controlled by VERIFY_SUPPLY_PIN)
-	if os.Getenv("VERIFY_SUPPLY_PIN") == "true" {
-		var storedPin *string
-		if err := h.pool.QueryRow(context.Background(), `select valid_pin from supplies where id=$1`, id).Scan(&storedPin); err != nil {
-			if err == pgx.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if storedPin == nil || strings.TrimSpace(*storedPin) == "" {
-			// bypass
-		} else {
-			if !isValidPin6(in.ValidPin) || *in.ValidPin != *storedPin {
-				c.JSON(http.StatusForbidden, gin.H{"error": "invalid pin"})
-				return
-			}
-		}
+	// An API key with supplies:write, the signed-in owner (or a co-owner) or, when
+	// VERIFY_SUPPLY_PIN=true, the supply's PIN
+	if !h.authorizeEdit(c, "supplies", id, in.ValidPin) {
+		return
 	}
 	setParts := []string{}
 	args := []interface{}{}
@@ -316,10 +328,10 @@ func (h *Handler) PatchSupply(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update supplies set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update supplies set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,name,address,phone,notes,pii_date,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var s models.Supply
 	var name, addr, phone, notes *string
 	var piiDate *int64
@@ -348,9 +360,18 @@ func (h *Handler) CreateSupplyItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.checkCatalogRefs(c, in.CategoryID, in.CatalogItemID) {
+		return
+	}
 	ctx := context.Background()
 	var id string
-	err := h.pool.QueryRow(ctx, `insert into supply_items(supply_id,tag,name,total_number,unit) values($1,$2,$3,$4,$5) returning id`, in.SupplyID, in.Tag, in.Name, in.TotalCount, in.Unit).Scan(&id)
+	// a deleted supply takes no new items
+	err := h.db(c).QueryRow(ctx, `insert into supply_items(supply_id,tag,name,total_number,unit,category_id,catalog_item_id) select $1,$2,$3,$4,$5,nullif($6,''),nullif($7,'')
+		where exists(select 1 from supplies where id=$1 and deleted_at is null) returning id`, in.SupplyID, in.Tag, in.Name, in.TotalCount, in.Unit, in.CategoryID, in.CatalogItemID).Scan(&id)
+	if err == pgx.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "supply not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -369,21 +390,32 @@ func (h *Handler) ListSupplyItems(c *gin.Context) {
 		filters = append(filters, "supply_id=$"+strconv.Itoa(len(args)+1))
 		args = append(args, supplyID)
 	}
+	for _, f := range []string{"category_id", "catalog_item_id"} {
+		if v := c.Query(f); v != "" {
+			filters = append(filters, f+"=$"+strconv.Itoa(len(args)+1))
+			args = append(args, v)
+		}
+	}
+	// ?outstanding=true keeps items still short once in-flight pledges arrive
+	if c.Query("outstanding") == "true" {
+		filters = append(filters, "total_number-received_count-"+supplyItemPledged+">0")
+	}
+	filters = appendLiveFilter(c, filters)
 	countQuery := "select count(*) from supply_items"
-	dataQuery := "select id,supply_id,tag,name,received_count,total_number,unit from supply_items"
+	dataQuery := "select id,supply_id,tag,name,received_count,total_number,unit," + supplyItemPledged + "," + itemUnitColumns + ",category_id,catalog_item_id,extract(epoch from deleted_at)::bigint from supply_items"
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQuery += where
 		dataQuery += where
 	}
 	var total int
-	if err := h.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	args = append(args, limit, offset)
 	dataQuery += " order by id desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
-	rows, err := h.pool.Query(ctx, dataQuery, args...)
+	rows, err := h.db(c).Query(ctx, dataQuery, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -393,13 +425,14 @@ func (h *Handler) ListSupplyItems(c *gin.Context) {
 	for rows.Next() {
 		var it models.SupplyItem
 		var tag, name, unit *string
-		if err := rows.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+		if err := rows.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit, &it.PledgedCount, &it.NormalizedUnit, &it.UnitFactor, &it.CategoryID, &it.CatalogItemID, &it.DeletedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		it.Tag = tag
 		it.Name = name
 		it.Unit = unit
+		setOutstanding(&it)
 		list = append(list, it)
 	}
 	baseURL := c.Request.URL.Path
@@ -428,6 +461,9 @@ type supplyItemPatchInput struct {
 	ReceivedCount *int    `json:"recieved_count"`
 	TotalNumber   *int    `json:"total_count"`
 	Unit          *string `json:"unit"`
+	CategoryID    *string `json:"category_id"`     // "" clears it; changing tag alone remaps it
+	CatalogItemID *string `json:"catalog_item_id"` // "" clears it; changing name alone remaps it
+	ValidPin      *string `json:"valid_pin"`       // PIN of the supply the item belongs to
 }
 
 func (h *Handler) PatchSupplyItem(c *gin.Context) {
@@ -437,11 +473,17 @@ func (h *Handler) PatchSupplyItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.authorizeEdit(c, "supply_items", id, in.ValidPin) {
+		return
+	}
+	if !h.checkCatalogRefs(c, in.CategoryID, in.CatalogItemID) {
+		return
+	}
 	// Validation if counts involved
 	if in.ReceivedCount != nil || in.TotalNumber != nil {
 		ctxCheck := context.Background()
 		var existingReceived, existingTotal int
-		if err := h.pool.QueryRow(ctxCheck, "select received_count,total_number from supply_items where id=$1", id).Scan(&existingReceived, &existingTotal); err != nil {
+		if err := h.db(c).QueryRow(ctxCheck, "select received_count,total_number from supply_items where id=$1 and deleted_at is null", id).Scan(&existingReceived, &existingTotal); err != nil {
 			if err == pgx.ErrNoRows {
 				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 				return
@@ -477,6 +519,7 @@ func (h *Handler) PatchSupplyItem(c *gin.Context) {
 		add("name=", *in.Name)
 	}
 	if in.ReceivedCount != nil {
+		// the difference is recorded as an adjustment in supply_deliveries by trigger
 		add("received_count=", *in.ReceivedCount)
 	}
 	if in.TotalNumber != nil {
@@ -485,17 +528,27 @@ func (h *Handler) PatchSupplyItem(c *gin.Context) {
 	if in.Unit != nil {
 		add("unit=", *in.Unit)
 	}
+	if in.CategoryID != nil {
+		setParts = append(setParts, "category_id=nullif($"+strconv.Itoa(idx)+",'')")
+		args = append(args, *in.CategoryID)
+		idx++
+	}
+	if in.CatalogItemID != nil {
+		setParts = append(setParts, "catalog_item_id=nullif($"+strconv.Itoa(idx)+",'')")
+		args = append(args, *in.CatalogItemID)
+		idx++
+	}
 	if len(setParts) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 		return
 	}
-	query := "update supply_items set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,supply_id,tag,name,received_count,total_number,unit"
+	query := "update supply_items set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,supply_id,tag,name,received_count,total_number,unit," + supplyItemPledged + "," + itemUnitColumns + ",category_id,catalog_item_id"
 	args = append(args, id)
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var it models.SupplyItem
 	var tag, name, unit *string
-	if err := row.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+	if err := row.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit, &it.PledgedCount, &it.NormalizedUnit, &it.UnitFactor, &it.CategoryID, &it.CatalogItemID); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -506,16 +559,17 @@ func (h *Handler) PatchSupplyItem(c *gin.Context) {
 	it.Tag = tag
 	it.Name = name
 	it.Unit = unit
+	setOutstanding(&it)
 	c.JSON(http.StatusOK, it)
 }
 
 func (h *Handler) GetSupplyItem(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,supply_id,tag,name,received_count,total_number,unit from supply_items where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,supply_id,tag,name,received_count,total_number,unit,`+supplyItemPledged+`,`+itemUnitColumns+`,category_id,catalog_item_id,extract(epoch from deleted_at)::bigint from supply_items where id=$1`+liveCond(c), id)
 	var it models.SupplyItem
 	var tag, name, unit *string
-	if err := row.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit); err != nil {
+	if err := row.Scan(&it.ID, &it.SupplyID, &tag, &name, &it.ReceivedCount, &it.TotalCount, &unit, &it.PledgedCount, &it.NormalizedUnit, &it.UnitFactor, &it.CategoryID, &it.CatalogItemID, &it.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -526,13 +580,18 @@ func (h *Handler) GetSupplyItem(c *gin.Context) {
 	it.Tag = tag
 	it.Name = name
 	it.Unit = unit
+	setOutstanding(&it)
 	c.JSON(http.StatusOK, it)
 }
 
 // POST /supplies/:id  (批次配送某供應單的多個物資項目)
 type distributeItemInput struct {
-	ID    string `json:"id" binding:"required"`
-	Count int    `json:"count" binding:"required"`
+	ID          string  `json:"id" binding:"required"`
+	Count       int     `json:"count" binding:"required"`
+	Unit        *string `json:"unit"`         // defaults to the item's unit
+	ProviderID  *string `json:"provider_id"`  // supply_providers row the delivery came from
+	DeliveredAt *int64  `json:"delivered_at"` // epoch seconds, defaults to now
+	Note        *string `json:"note"`
 }
 
 func (h *Handler) DistributeSupplyItems(c *gin.Context) {
@@ -551,7 +610,7 @@ func (h *Handler) DistributeSupplyItems(c *gin.Context) {
 		return
 	}
 	ctx := context.Background()
-	tx, err := h.pool.Begin(ctx)
+	tx, err := h.db(c).Begin(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -565,8 +624,9 @@ func (h *Handler) DistributeSupplyItems(c *gin.Context) {
 		}
 		var curSuppID string
 		var received, total int
+		var itemUnit *string
 		// lock row
-		if err := tx.QueryRow(ctx, `select supply_id,received_count,total_number from supply_items where id=$1 for update`, itm.ID).Scan(&curSuppID, &received, &total); err != nil {
+		if err := tx.QueryRow(ctx, `select supply_id,received_count,total_number,unit from supply_items where id=$1 and deleted_at is null for update`, itm.ID).Scan(&curSuppID, &received, &total, &itemUnit); err != nil {
 			if err == pgx.ErrNoRows {
 				c.JSON(http.StatusNotFound, gin.H{"error": "item not found", "id": itm.ID})
 				return
@@ -578,20 +638,65 @@ func (h *Handler) DistributeSupplyItems(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "item does not belong to supply", "id": itm.ID})
 			return
 		}
-		newReceived := received + itm.Count
+		// a count in another unit is converted to the item's (2 箱 of bottled water are 48 瓶)
+		count := itm.Count
+		unitOf := itemUnit
+		if itm.Unit != nil && *itm.Unit != "" {
+			if itemUnit != nil && *itemUnit != "" {
+				var converted *float64
+				if err := tx.QueryRow(ctx, `select convert_quantity($2::numeric,name,tag,$3,unit)::double precision from supply_items where id=$1`, itm.ID, itm.Count, *itm.Unit).Scan(&converted); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "id": itm.ID})
+					return
+				}
+				if converted == nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "unit does not match the item's unit", "id": itm.ID, "unit": *itemUnit})
+					return
+				}
+				if *converted != math.Trunc(*converted) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "count is not a whole number of the item's unit", "id": itm.ID, "unit": *itemUnit, "converted_count": *converted})
+					return
+				}
+				count = int(*converted)
+			} else {
+				unitOf = itm.Unit
+			}
+		}
+		newReceived := received + count
 		if newReceived > total {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "exceeds total_count", "id": itm.ID, "recieved_count": received, "total_count": total, "attempt_add": itm.Count})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exceeds total_count", "id": itm.ID, "recieved_count": received, "total_count": total, "attempt_add": count})
+			return
+		}
+		if itm.ProviderID != nil {
+			var providerItem string
+			if err := tx.QueryRow(ctx, `select supply_item_id from supply_providers where id=$1 and deleted_at is null`, *itm.ProviderID).Scan(&providerItem); err != nil {
+				if err == pgx.ErrNoRows {
+					c.JSON(http.StatusNotFound, gin.H{"error": "provider not found", "id": itm.ID})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "id": itm.ID})
+				return
+			}
+			if providerItem != itm.ID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "provider does not provide this item", "id": itm.ID})
+				return
+			}
+		}
+		// the ledger entry moves received_count (see migration 0011_supply_deliveries)
+		if _, err := tx.Exec(ctx, `insert into supply_deliveries(supply_item_id,quantity,unit,provider_id,delivered_at,note) values($1,$2,$3,$4,coalesce(to_timestamp($5::bigint),now()),$6)`,
+			itm.ID, count, unitOf, itm.ProviderID, itm.DeliveredAt, itm.Note); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "id": itm.ID})
 			return
 		}
 		var out models.SupplyItem
 		var tag, name, unit *string
-		if err := tx.QueryRow(ctx, `update supply_items set received_count=$1 where id=$2 returning id,supply_id,tag,name,received_count,total_number,unit`, newReceived, itm.ID).Scan(&out.ID, &out.SupplyID, &tag, &name, &out.ReceivedCount, &out.TotalCount, &unit); err != nil {
+		if err := tx.QueryRow(ctx, `select id,supply_id,tag,name,received_count,total_number,unit,`+supplyItemPledged+`,`+itemUnitColumns+`,category_id,catalog_item_id from supply_items where id=$1`, itm.ID).Scan(&out.ID, &out.SupplyID, &tag, &name, &out.ReceivedCount, &out.TotalCount, &unit, &out.PledgedCount, &out.NormalizedUnit, &out.UnitFactor, &out.CategoryID, &out.CatalogItemID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "id": itm.ID})
 			return
 		}
 		out.Tag = tag
 		out.Name = name
 		out.Unit = unit
+		setOutstanding(&out)
 		updated = append(updated, out)
 	}
 	if err := tx.Commit(ctx); err != nil {
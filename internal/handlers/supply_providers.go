@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -13,11 +15,77 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
+var supplyProviderColumns = `id,name,phone,supply_item_id,address,(coordinates->>'lat')::double precision,(coordinates->>'lng')::double precision,notes,provide_count,provide_unit,status,extract(epoch from status_changed_at)::bigint,delivery_id,` +
+	pledgeAllocatedSQL + `,` + providerUnitColumns + `,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint`
+
+func scanSupplyProvider(row pgx.Row) (models.SupplyProvider, error) {
+	var sp models.SupplyProvider
+	var lat, lng *float64
+	err := row.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &lat, &lng, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit,
+		&sp.Status, &sp.StatusChangedAt, &sp.DeliveryID, &sp.AllocatedCount, &sp.NormalizedUnit, &sp.UnitFactor, &sp.CreatedAt, &sp.UpdatedAt, &sp.DeletedAt)
+	sp.NormalizedProvideCount = normalized(sp.ProvideCount, sp.UnitFactor)
+	sp.UnitReview = unitReview(sp.ProvideUnit, sp.NormalizedUnit)
+	if lat != nil || lng != nil {
+		sp.Coordinates = &struct {
+			Lat *float64 `json:"lat"`
+			Lng *float64 `json:"lng"`
+		}{Lat: lat, Lng: lng}
+	}
+	return sp, err
+}
+
+// pledgeStatuses are the statuses of a pledge (supply_providers.status), in order.
+var pledgeStatuses = []string{"pledged", "confirmed", "in_transit", "delivered", "cancelled"}
+
+// pledgeTransitions are the statuses a pledge may move to from each status; delivered and
+// cancelled are final.
+var pledgeTransitions = map[string][]string{
+	"pledged":    {"confirmed", "in_transit", "delivered", "cancelled"},
+	"confirmed":  {"in_transit", "delivered", "cancelled"},
+	"in_transit": {"delivered", "cancelled"},
+}
+
+// pledgeInFlight are the statuses whose provide_count still counts as on its way.
+const pledgeInFlight = `('pledged','confirmed','in_transit')`
+
+// allocationsHeld are the allocation statuses that set part of a pledge aside for a requirement.
+const allocationsHeld = `('accepted','fulfilled')`
+
+// pledgeAllocated is the quantity of pledge p allocations have set aside for places' needs, in the
+// pledge's unit (allocations count in the requirement's), rounded up.
+func pledgeAllocated(p string) string {
+	return `ceil(coalesce((select sum(coalesce(a.provider_quantity,a.quantity)) from allocations a where a.supply_provider_id=` + p + `.id and a.status in ` + allocationsHeld + `),0))::int`
+}
+
+var pledgeAllocatedSQL = pledgeAllocated("supply_providers")
+
+// supplyItemPledged is the quantity in-flight pledges promise to the supply_items row of the query,
+// less what of them was allocated to places instead, converted to the item's unit. Pledges in a unit
+// that doesn't convert (see convert_quantity, migration 0014_units) aren't counted.
+var supplyItemPledged = `coalesce(round((select sum(greatest(convert_quantity(p.provide_count-` + pledgeAllocated("p") + `,supply_items.name,supply_items.tag,p.provide_unit,supply_items.unit),0))
+	from supply_providers p where p.supply_item_id=supply_items.id and p.deleted_at is null and p.status in ` + pledgeInFlight + `)),0)::int`
+
+// setOutstanding works out what is left to ask for once received and pledged quantities are counted,
+// and the quantities in the canonical unit.
+func setOutstanding(it *models.SupplyItem) {
+	it.OutstandingCount = it.TotalCount - it.ReceivedCount - it.PledgedCount
+	if it.OutstandingCount < 0 {
+		it.OutstandingCount = 0
+	}
+	it.NormalizedTotalCount = normalized(it.TotalCount, it.UnitFactor)
+	it.NormalizedReceivedCount = normalized(it.ReceivedCount, it.UnitFactor)
+	it.UnitReview = unitReview(it.Unit, it.NormalizedUnit)
+}
+
 type supplyProviderCreateInput struct {
-	Name         string  `json:"name" binding:"required"`
-	Phone        string  `json:"phone" binding:"required"`
-	SupplyItemID string  `json:"supply_item_id" binding:"required"`
-	Address      string  `json:"address" binding:"required"`
+	Name         string `json:"name" binding:"required"`
+	Phone        string `json:"phone" binding:"required"`
+	SupplyItemID string `json:"supply_item_id" binding:"required"`
+	Address      string `json:"address" binding:"required"`
+	Coordinates  *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"` // where the goods are, for matching them with places' needs
 	Notes        *string `json:"notes"`
 	ProvideCount int     `json:"provide_count" binding:"required"`
 	ProvideUnit  *string `json:"provide_unit"`
@@ -32,7 +100,7 @@ func (h *Handler) CreateSupplyProvider(c *gin.Context) {
 	ctx := context.Background()
 	// Verify supply_item_id exists
 	var exists bool
-	if err := h.pool.QueryRow(ctx, `select exists(select 1 from supply_items where id=$1)`, in.SupplyItemID).Scan(&exists); err != nil {
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from supply_items where id=$1 and deleted_at is null)`, in.SupplyItemID).Scan(&exists); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -40,6 +108,13 @@ func (h *Handler) CreateSupplyProvider(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found", "reason": "supply item not found"})
 		return
 	}
+	if mismatch, err := h.pledgeUnitMismatch(c, in.SupplyItemID, in.ProvideUnit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if mismatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide_unit does not convert to the item's unit"})
+		return
+	}
 
 	newUUID, err := uuid.NewV7()
 	if err != nil {
@@ -47,26 +122,20 @@ func (h *Handler) CreateSupplyProvider(c *gin.Context) {
 		return
 	}
 	id := newUUID.String()
+	var coordsJSON *string
+	if in.Coordinates != nil {
+		if b, err := json.Marshal(in.Coordinates); err == nil {
+			s := string(b)
+			coordsJSON = &s
+		}
+	}
 
-	var created, updated int64
-	err = h.pool.QueryRow(ctx, `insert into supply_providers(id,name,phone,supply_item_id,address,notes,provide_count,provide_unit) values($1,$2,$3,$4,$5,$6,$7,$8) returning extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
-		id, in.Name, in.Phone, in.SupplyItemID, in.Address, in.Notes, in.ProvideCount, in.ProvideUnit).Scan(&created, &updated)
+	out, err := scanSupplyProvider(h.db(c).QueryRow(ctx, `insert into supply_providers(id,name,phone,supply_item_id,address,coordinates,notes,provide_count,provide_unit,owner_user_id) values($1,$2,$3,$4,$5,$6::jsonb,$7,$8,$9,$10) returning `+supplyProviderColumns,
+		id, in.Name, in.Phone, in.SupplyItemID, in.Address, coordsJSON, in.Notes, in.ProvideCount, in.ProvideUnit, currentUserID(c)))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	out := models.SupplyProvider{
-		ID:           id,
-		Name:         in.Name,
-		Phone:        in.Phone,
-		SupplyItemID: in.SupplyItemID,
-		Address:      in.Address,
-		Notes:        in.Notes,
-		ProvideCount: in.ProvideCount,
-		ProvideUnit:  in.ProvideUnit,
-		CreatedAt:    created,
-		UpdatedAt:    updated,
-	}
 	c.JSON(http.StatusCreated, out)
 }
 
@@ -76,23 +145,28 @@ func (h *Handler) ListSupplyProviders(c *gin.Context) {
 	supplyItemID := c.Query("supply_item_id")
 	ctx := context.Background()
 
-	var total int
-	var rows pgx.Rows
-	var err error
-
+	filters := []string{}
+	args := []interface{}{}
 	if supplyItemID != "" {
-		if err := h.pool.QueryRow(ctx, `select count(*) from supply_providers where supply_item_id=$1`, supplyItemID).Scan(&total); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		rows, err = h.pool.Query(ctx, `select id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supply_providers where supply_item_id=$1 order by updated_at desc limit $2 offset $3`, supplyItemID, limit, offset)
-	} else {
-		if err := h.pool.QueryRow(ctx, `select count(*) from supply_providers`).Scan(&total); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		rows, err = h.pool.Query(ctx, `select id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supply_providers order by updated_at desc limit $1 offset $2`, limit, offset)
+		filters = append(filters, "supply_item_id=$"+strconv.Itoa(len(args)+1))
+		args = append(args, supplyItemID)
+	}
+	if v := c.Query("status"); v != "" {
+		filters = append(filters, "status=$"+strconv.Itoa(len(args)+1))
+		args = append(args, v)
 	}
+	filters = appendLiveFilter(c, filters)
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, `select count(*) from supply_providers`+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	args = append(args, limit, offset)
+	rows, err := h.db(c).Query(ctx, `select `+supplyProviderColumns+` from supply_providers`+where+` order by updated_at desc limit $`+strconv.Itoa(len(args)-1)+` offset $`+strconv.Itoa(len(args)), args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -101,14 +175,11 @@ func (h *Handler) ListSupplyProviders(c *gin.Context) {
 
 	list := []models.SupplyProvider{}
 	for rows.Next() {
-		var sp models.SupplyProvider
-		var created, updated int64
-		if err = rows.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
+		sp, err := scanSupplyProvider(rows)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		sp.CreatedAt = created
-		sp.UpdatedAt = updated
 		list = append(list, sp)
 	}
 	baseURL := c.Request.URL.Path
@@ -135,11 +206,8 @@ func (h *Handler) ListSupplyProviders(c *gin.Context) {
 func (h *Handler) GetSupplyProvider(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from supply_providers where id=$1`, id)
-
-	var sp models.SupplyProvider
-	var created, updated int64
-	if err := row.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
+	sp, err := scanSupplyProvider(h.db(c).QueryRow(ctx, `select `+supplyProviderColumns+` from supply_providers where id=$1`+liveCond(c), id))
+	if err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -147,9 +215,6 @@ func (h *Handler) GetSupplyProvider(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	sp.CreatedAt = created
-	sp.UpdatedAt = updated
 	c.JSON(http.StatusOK, sp)
 }
 
@@ -158,9 +223,16 @@ type supplyProviderPatchInput struct {
 	Phone        *string `json:"phone"`
 	SupplyItemID *string `json:"supply_item_id"`
 	Address      *string `json:"address"`
+	Coordinates  *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"`
 	Notes        *string `json:"notes"`
 	ProvideCount *int    `json:"provide_count"`
 	ProvideUnit  *string `json:"provide_unit"`
+	Status       *string `json:"status"`        // see pledgeTransitions
+	BookDelivery bool    `json:"book_delivery"` // with status delivered: add provide_count to the item's received count
+	ValidPin     *string `json:"valid_pin"`     // PIN of the supply the pledged item belongs to
 }
 
 func (h *Handler) PatchSupplyProvider(c *gin.Context) {
@@ -170,11 +242,19 @@ func (h *Handler) PatchSupplyProvider(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// The provider who pledged (signed in), the supply's owner or co-owners, or the supply's PIN;
+	// open to anyone while VERIFY_SUPPLY_PIN is off (see policy.Options)
+	if !h.authorizeEdit(c, "supply_providers", id, in.ValidPin) {
+		return
+	}
+	if !h.checkPledgeQuantityChange(c, id, in) {
+		return
+	}
 	ctx := context.Background()
 	// If updating supply_item_id, verify it exists
 	if in.SupplyItemID != nil {
 		var exists bool
-		if err := h.pool.QueryRow(ctx, `select exists(select 1 from supply_items where id=$1)`, *in.SupplyItemID).Scan(&exists); err != nil {
+		if err := h.db(c).QueryRow(ctx, `select exists(select 1 from supply_items where id=$1 and deleted_at is null)`, *in.SupplyItemID).Scan(&exists); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -183,6 +263,43 @@ func (h *Handler) PatchSupplyProvider(c *gin.Context) {
 			return
 		}
 	}
+	if in.SupplyItemID != nil || in.ProvideUnit != nil {
+		// the unit the pledge is left with must still convert to its item's
+		var itemID string
+		var unit *string
+		if err := h.db(c).QueryRow(ctx, `select supply_item_id,provide_unit from supply_providers where id=$1 and deleted_at is null`, id).Scan(&itemID, &unit); err != nil {
+			if err == pgx.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if in.SupplyItemID != nil {
+			itemID = *in.SupplyItemID
+		}
+		if in.ProvideUnit != nil {
+			unit = in.ProvideUnit
+		}
+		if mismatch, err := h.pledgeUnitMismatch(c, itemID, unit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		} else if mismatch {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "provide_unit does not convert to the item's unit"})
+			return
+		}
+	}
+	var statusChanged bool
+	var deliveryID *string
+	if in.Status != nil {
+		var ok bool
+		if statusChanged, deliveryID, ok = h.transitionPledge(c, id, in); !ok {
+			return
+		}
+	} else if in.BookDelivery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "book_delivery needs status delivered"})
+		return
+	}
 	// Build dynamic update
 	setParts := []string{}
 	args := []interface{}{}
@@ -204,6 +321,13 @@ func (h *Handler) PatchSupplyProvider(c *gin.Context) {
 	if in.Address != nil {
 		add("address=", *in.Address)
 	}
+	if in.Coordinates != nil {
+		if b, err := json.Marshal(in.Coordinates); err == nil {
+			setParts = append(setParts, "coordinates=$"+strconv.Itoa(idx)+"::jsonb")
+			args = append(args, string(b))
+			idx++
+		}
+	}
 	if in.Notes != nil {
 		add("notes=", *in.Notes)
 	}
@@ -213,18 +337,23 @@ func (h *Handler) PatchSupplyProvider(c *gin.Context) {
 	if in.ProvideUnit != nil {
 		add("provide_unit=", *in.ProvideUnit)
 	}
-	if len(setParts) == 0 {
+	if statusChanged {
+		add("status=", *in.Status)
+		setParts = append(setParts, "status_changed_at=now()")
+	}
+	if deliveryID != nil {
+		add("delivery_id=", *deliveryID)
+	}
+	if len(setParts) == 0 && in.Status == nil { // an unchanged status still answers with the pledge
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 		return
 	}
 	// always update updated_at
 	setParts = append(setParts, "updated_at=now()")
-	query := "update supply_providers set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,phone,supply_item_id,address,notes,provide_count,provide_unit,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update supply_providers set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning " + supplyProviderColumns
 	args = append(args, id)
-	row := h.pool.QueryRow(ctx, query, args...)
-	var sp models.SupplyProvider
-	var created, updated int64
-	if err := row.Scan(&sp.ID, &sp.Name, &sp.Phone, &sp.SupplyItemID, &sp.Address, &sp.Notes, &sp.ProvideCount, &sp.ProvideUnit, &created, &updated); err != nil {
+	sp, err := scanSupplyProvider(h.db(c).QueryRow(ctx, query, args...))
+	if err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -232,7 +361,153 @@ func (h *Handler) PatchSupplyProvider(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	sp.CreatedAt = created
-	sp.UpdatedAt = updated
 	c.JSON(http.StatusOK, sp)
 }
+
+// checkPledgeQuantityChange refuses (409) a PATCH that changes what a pledge promises (its item,
+// count or unit) once the pledge is delivered or cancelled, so it keeps agreeing with the delivery
+// it booked, or while accepted allocations hold part of it in the current item's unit; those have
+// to be cancelled first. The pledge stays locked for the rest of the request. It answers the
+// request itself when refusing.
+func (h *Handler) checkPledgeQuantityChange(c *gin.Context, id string, in supplyProviderPatchInput) bool {
+	if in.SupplyItemID == nil && in.ProvideCount == nil && in.ProvideUnit == nil {
+		return true
+	}
+	var status, itemID string
+	var count, accepted int
+	var unit *string
+	if err := h.db(c).QueryRow(context.Background(), `select status,supply_item_id,provide_count,provide_unit,
+		(select count(*) from allocations a where a.supply_provider_id=supply_providers.id and a.status='accepted')::int
+		from supply_providers where id=$1 and deleted_at is null for update`, id).Scan(&status, &itemID, &count, &unit, &accepted); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	fields := []string{}
+	if in.SupplyItemID != nil && *in.SupplyItemID != itemID {
+		fields = append(fields, "supply_item_id")
+	}
+	if in.ProvideCount != nil && *in.ProvideCount != count {
+		fields = append(fields, "provide_count")
+	}
+	if in.ProvideUnit != nil && (unit == nil || *unit != *in.ProvideUnit) {
+		fields = append(fields, "provide_unit")
+	}
+	if len(fields) == 0 {
+		return true
+	}
+	if _, open := pledgeTransitions[status]; !open {
+		c.JSON(http.StatusConflict, gin.H{"error": "a " + status + " pledge can't change its item or quantity", "status": status, "fields": fields})
+		return false
+	}
+	if accepted > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cancel the pledge's accepted allocations first", "accepted_allocations": accepted, "fields": fields})
+		return false
+	}
+	return true
+}
+
+// transitionPledge checks a PATCH's status change against pledgeTransitions, with the pledge locked
+// for the rest of the request. Delivered with book_delivery adds the pledged quantity, less what was
+// allocated to places' needs, to the item's received count as a supply_deliveries entry (in the
+// request's transaction, so the status and the count change together) and returns the entry's id.
+// It reports whether the status changes, and answers the request itself when refusing.
+func (h *Handler) transitionPledge(c *gin.Context, id string, in supplyProviderPatchInput) (bool, *string, bool) {
+	ctx := context.Background()
+	to := *in.Status
+	if !slices.Contains(pledgeStatuses, to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown status: " + to, "allowed": pledgeStatuses})
+		return false, nil, false
+	}
+	if in.BookDelivery && to != "delivered" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "book_delivery needs status delivered"})
+		return false, nil, false
+	}
+	var from, itemID string
+	var count, allocated int
+	var unit *string
+	if err := h.db(c).QueryRow(ctx, `select status,supply_item_id,provide_count,provide_unit,`+pledgeAllocatedSQL+` from supply_providers where id=$1 and deleted_at is null for update`, id).Scan(&from, &itemID, &count, &unit, &allocated); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return false, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false, nil, false
+	}
+	if from == to && !in.BookDelivery {
+		return false, nil, true
+	}
+	if !slices.Contains(pledgeTransitions[from], to) {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot change status from " + from + " to " + to, "status": from, "allowed": pledgeTransitions[from]})
+		return false, nil, false
+	}
+	if to == "cancelled" {
+		// what was allocated from the pledge won't arrive either
+		if _, err := h.db(c).Exec(ctx, `update allocations set status='cancelled',status_changed_at=now(),updated_at=now() where supply_provider_id=$1 and status='accepted'`, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false, nil, false
+		}
+	}
+	if !in.BookDelivery {
+		return true, nil, true
+	}
+	// the booked delivery is what the PATCH leaves the pledge with
+	if in.SupplyItemID != nil {
+		itemID = *in.SupplyItemID
+	}
+	if in.ProvideCount != nil {
+		count = *in.ProvideCount
+	}
+	if in.ProvideUnit != nil {
+		unit = in.ProvideUnit
+	}
+	if count <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide_count must be > 0 to book a delivery"})
+		return false, nil, false
+	}
+	// what was allocated to places' needs doesn't go to the item
+	if count -= allocated; count <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the whole pledge is allocated to places' needs, nothing to book", "allocated_count": allocated})
+		return false, nil, false
+	}
+	var received, total int
+	var itemUnit *string
+	var converted *int // the rest in the item's unit, whole units only
+	if err := h.db(c).QueryRow(ctx, `select received_count,total_number,unit,floor(convert_quantity($2::numeric,name,tag,$3,unit))::int from supply_items where id=$1 and deleted_at is null for update`,
+		itemID, count, unit).Scan(&received, &total, &itemUnit, &converted); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found", "reason": "supply item not found"})
+			return false, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false, nil, false
+	}
+	if converted == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide_unit does not match the item's unit", "unit": itemUnit})
+		return false, nil, false
+	}
+	if itemUnit != nil && *itemUnit != "" {
+		count, unit = *converted, itemUnit
+	}
+	if count <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "less than one of the item's unit left to book", "unit": itemUnit})
+		return false, nil, false
+	}
+	if received+count > total {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exceeds total_count", "id": itemID, "recieved_count": received, "total_count": total, "attempt_add": count})
+		return false, nil, false
+	}
+	if unit == nil || *unit == "" {
+		unit = itemUnit
+	}
+	var deliveryID string
+	if err := h.db(c).QueryRow(ctx, `insert into supply_deliveries(supply_item_id,quantity,unit,provider_id,note) values($1,$2,$3,$4,'pledge delivered') returning id`,
+		itemID, count, unit, id).Scan(&deliveryID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false, nil, false
+	}
+	return true, &deliveryID, true
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTimeseriesPoints caps from/to/step so one request can't ask for an unbounded series.
+const maxTimeseriesPoints = 5000
+
+// TimeseriesResources lists the resources that have /{resource}/:id/timeseries routes.
+func TimeseriesResources() []string {
+	list := []string{}
+	for _, m := range db.TimeseriesMetrics {
+		if !slices.Contains(list, m.Resource) {
+			list = append(list, m.Resource)
+		}
+	}
+	return list
+}
+
+// TimeseriesPoint is one step of a downsampled series: the value holding at the end of the step
+// and the lowest and highest it was during it.
+type TimeseriesPoint struct {
+	T     int64   `json:"t"`
+	Value float64 `json:"value"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+type TimeseriesSeries struct {
+	Metric string            `json:"metric"`
+	Points []TimeseriesPoint `json:"points"`
+}
+
+// GetTimeseries returns the recorded values of an entity (GET /{resource}/:id/timeseries) between
+// from and to (epoch seconds, default the last 7 days) in steps of step seconds (default about 200
+// points). Samples are only stored when a value changes, so every step starts from the value
+// holding before it; steps before the first sample are left out.
+func (h *Handler) GetTimeseries(c *gin.Context) {
+	res := strings.Split(strings.TrimPrefix(c.FullPath(), "/"), "/")[0]
+	metrics := []string{}
+	for _, m := range db.TimeseriesMetrics {
+		if m.Resource == res {
+			metrics = append(metrics, m.Name)
+		}
+	}
+	if len(metrics) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if raw := c.Query("metric"); raw != "" {
+		want := splitList(raw)
+		for _, m := range want {
+			if !slices.Contains(metrics, m) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown metric " + m, "metrics": metrics})
+				return
+			}
+		}
+		metrics = want
+	}
+	to := time.Now().Unix()
+	if raw := c.Query("to"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be epoch seconds"})
+			return
+		}
+		to = v
+	}
+	from := to - 7*24*3600
+	if raw := c.Query("from"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be epoch seconds"})
+			return
+		}
+		from = v
+	}
+	if from >= to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	// default: whole minutes giving at most about 200 points
+	step := max((to-from+200*60-1)/(200*60)*60, 60)
+	if raw := c.Query("step"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "step must be a positive number of seconds"})
+			return
+		}
+		step = v
+	}
+	if (to-from+step-1)/step > maxTimeseriesPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many points, use a larger step", "max_points": maxTimeseriesPoints})
+		return
+	}
+	id := c.Param("id")
+	ctx := context.Background()
+	var exists bool
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from `+res+` where id=$1)`, id).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	// the last sample before from is the value holding when the range starts
+	rows, err := h.db(c).Query(ctx, `select metric,extract(epoch from ts)::bigint,value from (
+			select distinct on (metric) metric,ts,value from metric_samples
+			where resource=$1 and entity_id=$2 and metric=any($3) and ts<to_timestamp($4) order by metric,ts desc
+		) prev
+		union all
+		select metric,extract(epoch from ts)::bigint,value from metric_samples
+		where resource=$1 and entity_id=$2 and metric=any($3) and ts>=to_timestamp($4) and ts<to_timestamp($5)
+		order by 1,2`, res, id, metrics, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	type sample struct {
+		ts    int64
+		value float64
+	}
+	samples := map[string][]sample{}
+	for rows.Next() {
+		var metric string
+		var s sample
+		if err := rows.Scan(&metric, &s.ts, &s.value); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		samples[metric] = append(samples[metric], s)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	series := []TimeseriesSeries{}
+	for _, metric := range metrics {
+		points := []TimeseriesPoint{}
+		list := samples[metric]
+		i := 0
+		var cur *float64
+		for t := from; t < to; t += step {
+			var p TimeseriesPoint
+			if cur != nil {
+				p = TimeseriesPoint{Value: *cur, Min: *cur, Max: *cur}
+			}
+			seen := cur != nil
+			for ; i < len(list) && list[i].ts < t+step; i++ {
+				v := list[i].value
+				cur = &v
+				if !seen {
+					p = TimeseriesPoint{Min: v, Max: v}
+					seen = true
+				}
+				p.Value, p.Min, p.Max = v, min(p.Min, v), max(p.Max, v)
+			}
+			if !seen {
+				continue
+			}
+			p.T = t
+			points = append(points, p)
+		}
+		series = append(series, TimeseriesSeries{Metric: metric, Points: points})
+	}
+	c.JSON(http.StatusOK, gin.H{"resource": res, "id": id, "from": from, "to": to, "step": step, "series": series})
+}
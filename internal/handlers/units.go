@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+
+	"guangfu250923/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// itemUnitColumns are the canonical unit and factor (see normalize_unit, migration 0014_units) of
+// the supply_items row of the query.
+const itemUnitColumns = `(select n.canonical_unit from normalize_unit(supply_items.name,supply_items.tag,supply_items.unit) n),
+	(select n.canonical_factor::double precision from normalize_unit(supply_items.name,supply_items.tag,supply_items.unit) n)`
+
+// providerUnitColumns are the same for the supply_providers row of the query: its provide_unit, or
+// the item's unit when it has none, for the pledged item.
+const providerUnitColumns = `(select n.canonical_unit from supply_items i, normalize_unit(i.name,i.tag,coalesce(nullif(supply_providers.provide_unit,''),i.unit)) n where i.id=supply_providers.supply_item_id),
+	(select n.canonical_factor::double precision from supply_items i, normalize_unit(i.name,i.tag,coalesce(nullif(supply_providers.provide_unit,''),i.unit)) n where i.id=supply_providers.supply_item_id)`
+
+// requirementUnitColumns are the same for the requirements_supplies row of the query.
+const requirementUnitColumns = `(select n.canonical_unit from normalize_unit(requirements_supplies.name,requirements_supplies.required_type,requirements_supplies.unit) n),
+	(select n.canonical_factor::double precision from normalize_unit(requirements_supplies.name,requirements_supplies.required_type,requirements_supplies.unit) n)`
+
+// normalized is count expressed in the canonical unit, 1 unit being factor of it; nil when the
+// unit isn't in the catalog.
+func normalized(count int, factor *float64) *float64 {
+	if factor == nil {
+		return nil
+	}
+	v := math.Round(float64(count)**factor*1000) / 1000
+	return &v
+}
+
+// unitReview reports whether unit is given but not in the catalog, so its quantities can't be
+// compared or summed until someone adds it (see GET /_admin/units/review).
+func unitReview(unit, canonical *string) bool {
+	return unit != nil && strings.TrimSpace(*unit) != "" && canonical == nil
+}
+
+// setRequirementUnits fills in the quantities of r in the canonical unit.
+func setRequirementUnits(r *models.RequirementsSupplies) {
+	r.NormalizedRequireCount = normalized(r.RequireCount, r.UnitFactor)
+	r.NormalizedReceivedCount = normalized(r.ReceivedCount, r.UnitFactor)
+	r.UnitReview = unitReview(&r.Unit, r.NormalizedUnit)
+}
+
+// pledgeUnitMismatch reports whether a pledge in unit can't be counted against supply item itemID
+// because both units are in the catalog but don't convert into each other. Units outside the
+// catalog aren't refused; they are flagged for review.
+func (h *Handler) pledgeUnitMismatch(c *gin.Context, itemID string, unit *string) (bool, error) {
+	if unit == nil || strings.TrimSpace(*unit) == "" {
+		return false, nil
+	}
+	var mismatch bool
+	err := h.db(c).QueryRow(context.Background(), `select resolve_unit($2) is not null and resolve_unit(unit) is not null and convert_quantity(1,name,tag,$2,unit) is null
+		from supply_items where id=$1`, itemID, *unit).Scan(&mismatch)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return mismatch, err
+}
+
+const unitCatalogColumns = `unit,base_unit,factor::double precision,aliases,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+func scanUnit(row pgx.Row) (models.Unit, error) {
+	var u models.Unit
+	err := row.Scan(&u.Unit, &u.BaseUnit, &u.Factor, &u.Aliases, &u.CreatedAt, &u.UpdatedAt)
+	return u, err
+}
+
+const unitConversionColumns = `item,from_unit,to_unit,factor::double precision,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`
+
+func scanUnitConversion(row pgx.Row) (models.UnitConversion, error) {
+	var uc models.UnitConversion
+	err := row.Scan(&uc.Item, &uc.FromUnit, &uc.ToUnit, &uc.Factor, &uc.CreatedAt, &uc.UpdatedAt)
+	return uc, err
+}
+
+// ListUnits returns the unit catalog (GET /units) and the per-item conversions, for clients to
+// offer known units and show normalized quantities.
+func (h *Handler) ListUnits(c *gin.Context) {
+	ctx := context.Background()
+	units := []models.Unit{}
+	rows, err := h.db(c).Query(ctx, `select `+unitCatalogColumns+` from units order by base_unit, factor, unit`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for rows.Next() {
+		u, err := scanUnit(rows)
+		if err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		units = append(units, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	conversions := []models.UnitConversion{}
+	rows, err = h.db(c).Query(ctx, `select `+unitConversionColumns+` from unit_conversions order by item, from_unit`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		uc, err := scanUnitConversion(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		conversions = append(conversions, uc)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"units": units, "conversions": conversions})
+}
+
+type unitInput struct {
+	Unit     string   `json:"unit" binding:"required"`
+	BaseUnit *string  `json:"base_unit"` // defaults to the unit itself
+	Factor   *float64 `json:"factor"`    // 1 unit = factor base_unit; defaults to 1
+	Aliases  []string `json:"aliases"`
+}
+
+// PutUnit adds a unit to the catalog or replaces it (POST /_admin/units). A unit is either a base
+// unit (its own base, factor 1) or converts to one; aliases may not be another unit's spelling.
+func (h *Handler) PutUnit(c *gin.Context) {
+	var in unitInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	unit := strings.TrimSpace(in.Unit)
+	base, factor := unit, 1.0
+	if in.BaseUnit != nil && strings.TrimSpace(*in.BaseUnit) != "" {
+		base = strings.TrimSpace(*in.BaseUnit)
+	}
+	if in.Factor != nil {
+		factor = *in.Factor
+	}
+	if unit == "" || factor <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unit is required and factor must be > 0"})
+		return
+	}
+	if base == unit && factor != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a base unit has factor 1"})
+		return
+	}
+	spellings := []string{strings.ToLower(unit)}
+	aliases := []string{}
+	for _, a := range in.Aliases {
+		a = strings.TrimSpace(a)
+		if a == "" || strings.EqualFold(a, unit) {
+			continue
+		}
+		aliases = append(aliases, a)
+		spellings = append(spellings, strings.ToLower(a))
+	}
+	ctx := context.Background()
+	if base != unit {
+		var isBase bool
+		if err := h.db(c).QueryRow(ctx, `select base_unit=unit from units where unit=$1`, base).Scan(&isBase); err != nil {
+			if err == pgx.ErrNoRows {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown base_unit: " + base})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !isBase {
+			c.JSON(http.StatusBadRequest, gin.H{"error": base + " is not a base unit"})
+			return
+		}
+		var isBaseOfOthers bool
+		if err := h.db(c).QueryRow(ctx, `select exists(select 1 from units where base_unit=$1 and unit<>$1)`, unit).Scan(&isBaseOfOthers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if isBaseOfOthers {
+			c.JSON(http.StatusConflict, gin.H{"error": "other units convert to " + unit + ", it has to stay a base unit"})
+			return
+		}
+	}
+	var taken string
+	err := h.db(c).QueryRow(ctx, `select unit from units where unit<>$1 and (lower(unit)=any($2) or exists(select 1 from unnest(aliases) a where lower(a)=any($2))) limit 1`, unit, spellings).Scan(&taken)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "spelling already belongs to another unit", "unit": taken})
+		return
+	}
+	if err != pgx.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var existed bool
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from units where unit=$1)`, unit).Scan(&existed); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := scanUnit(h.db(c).QueryRow(ctx, `insert into units(unit,base_unit,factor,aliases) values($1,$2,$3,$4)
+		on conflict (unit) do update set base_unit=excluded.base_unit,factor=excluded.factor,aliases=excluded.aliases,updated_at=now()
+		returning `+unitCatalogColumns, unit, base, factor, aliases))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	c.JSON(status, u)
+}
+
+// DeleteUnit removes a unit from the catalog, with its conversions. Quantities in it become
+// unknown (flagged for review); base units other units convert to can't be removed.
+func (h *Handler) DeleteUnit(c *gin.Context) {
+	unit := c.Param("unit")
+	ctx := context.Background()
+	var isBaseOfOthers bool
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from units where base_unit=$1 and unit<>$1)`, unit).Scan(&isBaseOfOthers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if isBaseOfOthers {
+		c.JSON(http.StatusConflict, gin.H{"error": "other units convert to " + unit})
+		return
+	}
+	tag, err := h.db(c).Exec(ctx, `delete from units where unit=$1`, unit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type unitConversionInput struct {
+	Item     string  `json:"item" binding:"required"`
+	FromUnit string  `json:"from_unit" binding:"required"`
+	ToUnit   string  `json:"to_unit" binding:"required"`
+	Factor   float64 `json:"factor" binding:"required"` // 1 from_unit = factor to_unit
+}
+
+// PutUnitConversion adds or replaces how a packaging unit converts for one item
+// (POST /_admin/unit_conversions). Both units must be in the catalog; any spelling of them works.
+func (h *Handler) PutUnitConversion(c *gin.Context) {
+	var in unitConversionInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	item := strings.ToLower(strings.TrimSpace(in.Item))
+	if item == "" || in.Factor <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "item is required and factor must be > 0"})
+		return
+	}
+	ctx := context.Background()
+	var from, to *string
+	if err := h.db(c).QueryRow(ctx, `select resolve_unit($1),resolve_unit($2)`, in.FromUnit, in.ToUnit).Scan(&from, &to); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if from == nil || to == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown unit, add it to the catalog first", "from_unit": from, "to_unit": to})
+		return
+	}
+	if *from == *to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_unit and to_unit are the same unit"})
+		return
+	}
+	var existed bool
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from unit_conversions where item=$1 and from_unit=$2)`, item, *from).Scan(&existed); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uc, err := scanUnitConversion(h.db(c).QueryRow(ctx, `insert into unit_conversions(item,from_unit,to_unit,factor) values($1,$2,$3,$4)
+		on conflict (item,from_unit) do update set to_unit=excluded.to_unit,factor=excluded.factor,updated_at=now()
+		returning `+unitConversionColumns, item, *from, *to, in.Factor))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	c.JSON(status, uc)
+}
+
+func (h *Handler) DeleteUnitConversion(c *gin.Context) {
+	tag, err := h.db(c).Exec(context.Background(), `delete from unit_conversions where item=lower(btrim($1)) and from_unit=coalesce(resolve_unit($2),$2)`, c.Param("item"), c.Param("from_unit"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// unitReviewEntry is a unit outside the catalog and how many live rows of a resource use it.
+type unitReviewEntry struct {
+	Resource string `json:"resource"`
+	Unit     string `json:"unit"`
+	Count    int    `json:"count"`
+}
+
+// ListUnitReview lists the units in use that aren't in the catalog (GET /_admin/units/review), most
+// used first, so they can be added as units or aliases.
+func (h *Handler) ListUnitReview(c *gin.Context) {
+	rows, err := h.db(c).Query(context.Background(), `select resource,unit,count from (
+		select 'supply_items' as resource,btrim(unit) as unit,count(*)::int as count from supply_items
+			where deleted_at is null and nullif(btrim(unit),'') is not null and resolve_unit(unit) is null group by btrim(unit)
+		union all
+		select 'supply_providers',btrim(provide_unit),count(*)::int from supply_providers
+			where deleted_at is null and nullif(btrim(provide_unit),'') is not null and resolve_unit(provide_unit) is null group by btrim(provide_unit)
+		union all
+		select 'requirements_supplies',btrim(unit),count(*)::int from requirements_supplies
+			where deleted_at is null and nullif(btrim(unit),'') is not null and resolve_unit(unit) is null group by btrim(unit)
+	) x order by count desc, unit, resource`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []unitReviewEntry{}
+	for rows.Next() {
+		var e unitReviewEntry
+		if err := rows.Scan(&e.Resource, &e.Unit, &e.Count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, e)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": len(list), "member": list})
+}
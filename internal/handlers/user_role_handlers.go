@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"guangfu250923/internal/middleware"
+	"guangfu250923/internal/models"
+	"guangfu250923/internal/policy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const userRoleColumns = `id,role,place_id,created_by,extract(epoch from created_at)::bigint`
+
+func scanUserRole(row pgx.Row) (models.UserRole, error) {
+	var r models.UserRole
+	err := row.Scan(&r.ID, &r.Role, &r.PlaceID, &r.CreatedBy, &r.CreatedAt)
+	return r, err
+}
+
+// loadUserRoles returns the roles of the given users, by user id.
+func (h *Handler) loadUserRoles(c *gin.Context, ids []string) (map[string][]models.UserRole, error) {
+	out := map[string][]models.UserRole{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := h.db(c).Query(context.Background(), `select user_id::text,`+userRoleColumns+` from user_roles where user_id::text = any($1) order by created_at`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uid string
+		var r models.UserRole
+		if err := rows.Scan(&uid, &r.ID, &r.Role, &r.PlaceID, &r.CreatedBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		out[uid] = append(out[uid], r)
+	}
+	return out, rows.Err()
+}
+
+// actorName is who made an admin change: the API key's name or user:<id>.
+func actorName(c *gin.Context) *string {
+	if k := middleware.CurrentAPIKey(c); k != nil {
+		return &k.Name
+	}
+	if u := middleware.CurrentUser(c); u != nil {
+		s := "user:" + u.ID
+		return &s
+	}
+	return nil
+}
+
+// ListUsers lists signed-in users with their roles; ?role= keeps users holding that role.
+func (h *Handler) ListUsers(c *gin.Context) {
+	limit := parsePositiveInt(c.Query("limit"), 50, 1, 500)
+	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
+	ctx := context.Background()
+	filters := []string{}
+	args := []interface{}{}
+	if v := c.Query("role"); v != "" {
+		filters = append(filters, "exists(select 1 from user_roles r where r.user_id=users.id and r.role=$"+strconv.Itoa(len(args)+1)+")")
+		args = append(args, v)
+	}
+	if v := c.Query("place_id"); v != "" {
+		filters = append(filters, "exists(select 1 from user_roles r where r.user_id=users.id and r.place_id=$"+strconv.Itoa(len(args)+1)+")")
+		args = append(args, v)
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = " where " + strings.Join(filters, " and ")
+	}
+	var total int
+	if err := h.db(c).QueryRow(ctx, "select count(*) from users"+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	args = append(args, limit, offset)
+	rows, err := h.db(c).Query(ctx, "select "+userColumns+" from users"+where+" order by created_at desc limit $"+strconv.Itoa(len(args)-1)+" offset $"+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	list := []models.User{}
+	ids := []string{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		list = append(list, u)
+		ids = append(ids, u.ID)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	roles, err := h.loadUserRoles(c, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range list {
+		list[i].Roles = roles[list[i].ID]
+	}
+	base := c.Request.URL.Path
+	q := c.Request.URL.Query()
+	build := func(off int) string {
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(off))
+		return base + "?" + q.Encode()
+	}
+	var next, prev *string
+	if offset+limit < total {
+		s := build(offset + limit)
+		next = &s
+	}
+	if offset > 0 {
+		po := offset - limit
+		if po < 0 {
+			po = 0
+		}
+		s := build(po)
+		prev = &s
+	}
+	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+}
+
+type userRoleInput struct {
+	Role    string  `json:"role"`
+	PlaceID *string `json:"place_id"` // required for site_coordinator, not allowed otherwise
+}
+
+// AddUserRole assigns a role to a user. It applies to the user's sessions right away.
+func (h *Handler) AddUserRole(c *gin.Context) {
+	var in userRoleInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := policy.Role(strings.TrimSpace(in.Role))
+	if !role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role: " + in.Role})
+		return
+	}
+	var placeID *string
+	if in.PlaceID != nil && strings.TrimSpace(*in.PlaceID) != "" {
+		placeID = in.PlaceID
+	}
+	if (role == policy.SiteCoordinator) != (placeID != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "place_id is required for site_coordinator and only for it"})
+		return
+	}
+	ctx := context.Background()
+	uid := c.Param("id")
+	var exists bool
+	if err := h.db(c).QueryRow(ctx, `select exists(select 1 from users where id::text=$1)`, uid).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if placeID != nil {
+		if err := h.db(c).QueryRow(ctx, `select exists(select 1 from places where id=$1 and deleted_at is null)`, *placeID).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "place not found"})
+			return
+		}
+	}
+	r, err := scanUserRole(h.db(c).QueryRow(ctx, `insert into user_roles(user_id,role,place_id,created_by) values($1::uuid,$2,$3,$4)
+		on conflict do nothing returning `+userRoleColumns, uid, string(role), placeID, actorName(c)))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusConflict, gin.H{"error": "role already assigned"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.afterCommit(c, middleware.ForgetSessions)
+	c.JSON(http.StatusCreated, r)
+}
+
+// RemoveUserRole takes a role away from a user.
+func (h *Handler) RemoveUserRole(c *gin.Context) {
+	tag, err := h.db(c).Exec(context.Background(), `delete from user_roles where id::text=$1 and user_id::text=$2`, c.Param("role_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	h.afterCommit(c, middleware.ForgetSessions)
+	c.Status(http.StatusNoContent)
+}
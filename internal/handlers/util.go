@@ -3,7 +3,13 @@ package handlers
 import (
 	"crypto/rand"
 	"math/big"
+	"net/http"
 	"strconv"
+	"strings"
+
+	"guangfu250923/internal/legacy"
+
+	"github.com/gin-gonic/gin"
 )
 
 // parsePositiveInt parses a query parameter into an int with bounds and default.
@@ -56,3 +62,45 @@ func isValidPin6(s *string) bool {
 	}
 	return true
 }
+
+// legacySource returns the relation legacy GET endpoints read from: the legacy table itself, or
+// its places-backed compatibility view once LEGACY_READ_FROM_PLACES=true (see cmd/migrate_places).
+func (h *Handler) legacySource(table string) string {
+	if h.legacyFromPlaces {
+		return legacy.ViewName(table)
+	}
+	return table
+}
+
+// LegacyWriteGuard refuses writes (405) to the legacy facility endpoints while their reads come
+// from places, so a write can't land in a table nothing reads any more. Writes go to /places
+// instead. Register it before the legacy routes.
+func (h *Handler) LegacyWriteGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.legacyFromPlaces {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		res := strings.Split(strings.TrimPrefix(c.FullPath(), "/"), "/")[0]
+		if _, ok := legacy.Lookup(res); ok {
+			c.Header("Allow", "GET, HEAD")
+			c.JSON(http.StatusMethodNotAllowed, gin.H{"error": res + " is read-only while LEGACY_READ_FROM_PLACES=true; write to /places instead"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// nilIfEmpty maps "" to NULL for optional text columns.
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// entityVersion is the latest entity_versions version of a row; 0 when it has none.
+func (h *Handler) entityVersion(c *gin.Context, res, id string) (int, error) {
+	var v int
+	err := h.db(c).QueryRow(context.Background(), `select coalesce(max(version),0) from entity_versions where resource=$1 and entity_id=$2`, res, id).Scan(&v)
+	return v, err
+}
+
+// versionETag is the strong ETag of a row at version v.
+func versionETag(v int) string {
+	return `"v` + strconv.Itoa(v) + `"`
+}
+
+// ifMatches reports whether an If-Match header value matches etag. Weak tags never match.
+func ifMatches(header, etag string) bool {
+	for _, p := range strings.Split(header, ",") {
+		p = strings.TrimSpace(p)
+		if p == "*" || p == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Versioning gives every single resource (GET /{resource}/:id) a strong ETag made from its
+// entity_versions version, and makes PATCH /{resource}/:id honour If-Match with it: when the row
+// has changed since the client read it the update is refused with 412 Precondition Failed and
+// the current representation (and ETag), so the client can merge and retry. PATCH without
+// If-Match behaves as before. Successful updates answer with the new ETag.
+func (h *Handler) Versioning() gin.HandlerFunc {
+	getters := map[string]gin.HandlerFunc{
+		"volunteer_organizations": h.GetVolunteerOrg,
+		"shelters":                h.GetShelter,
+		"medical_stations":        h.GetMedicalStation,
+		"mental_health_resources": h.GetMentalHealthResource,
+		"accommodations":          h.GetAccommodation,
+		"shower_stations":         h.GetShowerStation,
+		"water_refill_stations":   h.GetWaterRefillStation,
+		"restrooms":               h.GetRestroom,
+		"human_resources":         h.GetHumanResource,
+		"supplies":                h.GetSupply,
+		"supply_items":            h.GetSupplyItem,
+		"reports":                 h.GetReport,
+		"spam_results":            h.GetSpamResult,
+		"places":                  h.GetPlace,
+		"requirements_hr":         h.GetRequirementsHR,
+		"requirements_supplies":   h.GetRequirementsSupplies,
+		"supply_providers":        h.GetSupplyProvider,
+	}
+	return func(c *gin.Context) {
+		res, _, ok := historyResource(c)
+		get := getters[res]
+		if !ok || get == nil || c.FullPath() != "/"+res+"/:id" {
+			c.Next()
+			return
+		}
+		id := c.Param("id")
+		switch c.Request.Method {
+		case http.MethodGet:
+			// read before the handler, so a change racing the read makes the ETag stale, not newer
+			if v, err := h.entityVersion(c, res, id); err == nil && v > 0 {
+				c.Header("ETag", versionETag(v))
+			}
+			c.Next()
+			if c.Writer.Status() != http.StatusOK {
+				c.Writer.Header().Del("ETag")
+			}
+		case http.MethodPatch:
+			if im := c.GetHeader("If-Match"); im != "" {
+				ctx := context.Background()
+				// hold the row's version lock (see record_entity_version) until the request commits
+				if _, err := h.db(c).Exec(ctx, `select pg_advisory_xact_lock(hashtext($1 || '/' || $2))`, res, id); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					c.Abort()
+					return
+				}
+				v, err := h.entityVersion(c, res, id)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					c.Abort()
+					return
+				}
+				if v == 0 || !ifMatches(im, versionETag(v)) {
+					status, row := currentRow(c, get)
+					if status != http.StatusOK {
+						c.Data(status, "application/json; charset=utf-8", row)
+						c.Abort()
+						return
+					}
+					c.Header("ETag", versionETag(v))
+					c.JSON(http.StatusPreconditionFailed, json.RawMessage(row))
+					c.Abort()
+					return
+				}
+			}
+			c.Next()
+			if c.Writer.Status() < 400 && !c.IsAborted() {
+				if v, err := h.entityVersion(c, res, id); err == nil && v > 0 {
+					c.Header("ETag", versionETag(v))
+				}
+			}
+		default:
+			c.Next()
+		}
+	}
+}
+
+// currentRow runs a GET handler without writing its response and returns the status and body
+// it produced: the current representation of the row when the status is 200.
+func currentRow(c *gin.Context, get gin.HandlerFunc) (int, []byte) {
+	w := c.Writer
+	rec := &rowRecorder{ResponseWriter: w, status: http.StatusOK}
+	c.Writer = rec
+	get(c)
+	c.Writer = w
+	return rec.status, rec.buf.Bytes()
+}
+
+// rowRecorder keeps what a handler writes instead of sending it.
+type rowRecorder struct {
+	gin.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (r *rowRecorder) WriteHeader(code int)              { r.status = code }
+func (r *rowRecorder) WriteHeaderNow()                   {}
+func (r *rowRecorder) Status() int                       { return r.status }
+func (r *rowRecorder) Write(b []byte) (int, error)       { return r.buf.Write(b) }
+func (r *rowRecorder) WriteString(s string) (int, error) { return r.buf.WriteString(s) }
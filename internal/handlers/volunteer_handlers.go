@@ -36,7 +36,7 @@ func (h *Handler) CreateVolunteerOrg(c *gin.Context) {
 	ctx := context.Background()
 	var id string
 	var lastUpdated time.Time
-	err := h.pool.QueryRow(ctx, `insert into volunteer_organizations(last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url) values(now(),$1,$2,$3,$4,$5,$6,$7,$8,$9,$10) returning id,last_updated`,
+	err := h.db(c).QueryRow(ctx, `insert into volunteer_organizations(last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url) values(now(),$1,$2,$3,$4,$5,$6,$7,$8,$9,$10) returning id,last_updated`,
 		in.RegistrationStatus, in.OrganizationNature, in.OrganizationName, in.Coordinator, in.ContactInfo, in.RegistrationMethod, in.ServiceContent, in.MeetingInfo, in.Notes, in.ImageURL,
 	).Scan(&id, &lastUpdated)
 	if err != nil {
@@ -51,9 +51,13 @@ func (h *Handler) ListVolunteerOrgs(c *gin.Context) {
 	limit := parsePositiveInt(c.Query("limit"), 20, 1, 200)
 	offset := parsePositiveInt(c.Query("offset"), 0, 0, 1000000)
 	ctx := context.Background()
+	where := ""
+	if !includeDeleted(c) {
+		where = " where deleted_at is null"
+	}
 	var total int
-	h.pool.QueryRow(ctx, `select count(*) from volunteer_organizations`).Scan(&total)
-	rows, err := h.pool.Query(ctx, `select id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url from volunteer_organizations order by last_updated desc limit $1 offset $2`, limit, offset)
+	h.db(c).QueryRow(ctx, `select count(*) from volunteer_organizations`+where).Scan(&total)
+	rows, err := h.db(c).Query(ctx, `select id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url,extract(epoch from deleted_at)::bigint from volunteer_organizations`+where+` order by last_updated desc limit $1 offset $2`, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -62,7 +66,7 @@ func (h *Handler) ListVolunteerOrgs(c *gin.Context) {
 	list := []models.VolunteerOrganization{}
 	for rows.Next() {
 		var vo models.VolunteerOrganization
-		if err = rows.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL); err != nil {
+		if err = rows.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL, &vo.DeletedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -101,9 +105,9 @@ func (h *Handler) ListVolunteerOrgs(c *gin.Context) {
 func (h *Handler) GetVolunteerOrg(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url from volunteer_organizations where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url,extract(epoch from deleted_at)::bigint from volunteer_organizations where id=$1`+liveCond(c), id)
 	var vo models.VolunteerOrganization
-	if err := row.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL); err != nil {
+	if err := row.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL, &vo.DeletedAt); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
@@ -175,10 +179,10 @@ func (h *Handler) PatchVolunteerOrg(c *gin.Context) {
 	}
 	// always bump last_updated timestamp
 	setParts = append(setParts, "last_updated=now()")
-	query := "update volunteer_organizations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url"
+	query := "update volunteer_organizations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,last_updated,registration_status,organization_nature,organization_name,coordinator,contact_info,registration_method,service_content,meeting_info,notes,image_url"
 	args = append(args, id)
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var vo models.VolunteerOrganization
 	if err := row.Scan(&vo.ID, &vo.LastUpdated, &vo.RegistrationStatus, &vo.OrganizationNature, &vo.OrganizationName, &vo.Coordinator, &vo.ContactInfo, &vo.RegistrationMethod, &vo.ServiceContent, &vo.MeetingInfo, &vo.Notes, &vo.ImageURL); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
@@ -59,7 +59,7 @@ func (h *Handler) CreateWaterRefillStation(c *gin.Context) {
 	ctx := context.Background()
 	var id string
 	var created, updated int64
-	err := h.pool.QueryRow(ctx, `insert into water_refill_stations(name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11::text[],$12,$13,$14,$15,$16::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
+	err := h.db(c).QueryRow(ctx, `insert into water_refill_stations(name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,coordinates) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11::text[],$12,$13,$14,$15,$16::jsonb) returning id,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint`,
 		in.Name, in.Address, in.Phone, in.WaterType, in.OpeningHours, isFree, in.ContainerRequired, in.DailyCapacity, in.Status, in.WaterQuality, in.Facilities, accessible, in.DistanceToDisasterArea, in.Notes, in.InfoSource, coordsJSON).Scan(&id, &created, &updated)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -165,9 +165,9 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 		return
 	}
 	setParts = append(setParts, "updated_at=now()")
-	query := "update water_refill_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " returning id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
+	query := "update water_refill_stations set " + strings.Join(setParts, ",") + " where id=$" + strconv.Itoa(idx) + " and deleted_at is null returning id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint"
 	args = append(args, id)
-	row := h.pool.QueryRow(ctx, query, args...)
+	row := h.db(c).QueryRow(ctx, query, args...)
 	var w models.WaterRefillStation
 	var phone, containerReq, waterQuality, distance, notes, infoSource *string
 	var dailyCap *int
@@ -207,7 +207,7 @@ func (h *Handler) PatchWaterRefillStation(c *gin.Context) {
 func (h *Handler) GetWaterRefillStation(c *gin.Context) {
 	id := c.Param("id")
 	ctx := context.Background()
-	row := h.pool.QueryRow(ctx, `select id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from water_refill_stations where id=$1`, id)
+	row := h.db(c).QueryRow(ctx, `select id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint from ` + h.legacySource("water_refill_stations") + ` where id=$1`+liveCond(c), id)
 	var w models.WaterRefillStation
 	var phone, containerReq, waterQuality, distance, notes, infoSource *string
 	var dailyCap *int
@@ -215,7 +215,7 @@ func (h *Handler) GetWaterRefillStation(c *gin.Context) {
 	var isFree, accessibility bool
 	var lat, lng *float64
 	var created, updated int64
-	if err := row.Scan(&w.ID, &w.Name, &w.Address, &phone, &w.WaterType, &w.OpeningHours, &isFree, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &accessibility, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+	if err := row.Scan(&w.ID, &w.Name, &w.Address, &phone, &w.WaterType, &w.OpeningHours, &isFree, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &accessibility, &distance, &notes, &infoSource, &lat, &lng, &created, &updated, &w.DeletedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
@@ -251,6 +251,11 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 	waterType := c.Query("water_type")
 	isFree := c.Query("is_free")
 	accessibility := c.Query("accessibility")
+	geo, err := parseGeoQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	ctx := context.Background()
 	filters := []string{}
 	args := []interface{}{}
@@ -272,21 +277,23 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 		val := (accessibility == "true" || accessibility == "1")
 		args = append(args, val)
 	}
-	countQ := "select count(*) from water_refill_stations"
-	dataQ := "select id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint from water_refill_stations"
+	filters = appendLiveFilter(c, filters)
+	filters, args = geo.apply(filters, args)
+	countQ := "select count(*) from " + h.legacySource("water_refill_stations")
+	dataQ := "select id,name,address,phone,water_type,opening_hours,is_free,container_required,daily_capacity,status,water_quality,facilities,accessibility,distance_to_disaster_area,notes,info_source,(coordinates->>'lat')::double precision as lat,(coordinates->>'lng')::double precision as lng,extract(epoch from created_at)::bigint,extract(epoch from updated_at)::bigint,extract(epoch from deleted_at)::bigint," + geo.distanceColumn() + " from " + h.legacySource("water_refill_stations")
 	if len(filters) > 0 {
 		where := " where " + strings.Join(filters, " and ")
 		countQ += where
 		dataQ += where
 	}
 	var total int
-	if err := h.pool.QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
+	if err := h.db(c).QueryRow(ctx, countQ, args...).Scan(&total); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	args = append(args, limit, offset)
-	dataQ += " order by updated_at desc limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
-	rows, err := h.pool.Query(ctx, dataQ, args...)
+	dataQ += " order by " + geo.orderBy("updated_at desc") + " limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
+	rows, err := h.db(c).Query(ctx, dataQ, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -301,7 +308,7 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 		var free, acc bool
 		var lat, lng *float64
 		var created, updated int64
-		if err := rows.Scan(&w.ID, &w.Name, &w.Address, &phone, &w.WaterType, &w.OpeningHours, &free, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &acc, &distance, &notes, &infoSource, &lat, &lng, &created, &updated); err != nil {
+		if err := rows.Scan(&w.ID, &w.Name, &w.Address, &phone, &w.WaterType, &w.OpeningHours, &free, &containerReq, &dailyCap, &w.Status, &waterQuality, &facilities, &acc, &distance, &notes, &infoSource, &lat, &lng, &created, &updated, &w.DeletedAt, &w.DistanceM); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -342,5 +349,5 @@ func (h *Handler) ListWaterRefillStations(c *gin.Context) {
 		s := build(offset - limit)
 		prev = &s
 	}
-	c.JSON(http.StatusOK, gin.H{"@context": "https://www.w3.org/ns/hydra/context.jsonld", "@type": "Collection", "totalItems": total, "member": list, "limit": limit, "offset": offset, "next": next, "previous": prev})
+	writeLocationCollection(c, list, total, limit, offset, next, prev)
 }
@@ -0,0 +1,297 @@
+// Package legacy describes how the per-facility tables (shelters, restrooms, ...) map onto
+// places. The same description drives the cmd/migrate_places tool and the places_as_<table>
+// compatibility views created by db.Migrate, so the two can't drift apart.
+package legacy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// Column types understood by the view builder.
+const (
+	Text        = "text"  // nullable text
+	TextNotNull = "text!" // text not null (falls back to '')
+	Int         = "int"
+	Bool        = "bool" // boolean not null (falls back to false)
+	TextArray   = "text[]"
+	Timestamp   = "timestamptz"
+	JSONB       = "jsonb"
+)
+
+// Column is a legacy table column that has no first-class places counterpart and is therefore
+// kept in places.additional_info->'legacy'.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Table maps one legacy table onto places. Field values name legacy columns ("" = not mapped).
+type Table struct {
+	Name       string
+	PlaceType  string
+	SubType    string
+	Address    []string // first non-empty wins
+	Phone      string
+	Contact    string
+	Website    string
+	InfoSource string
+	Resources  []string // text[] columns turned into places.resources entries
+	// Statuses are the legacy words used for 開放/暫停/關閉 when a place has no legacy status.
+	Statuses [3]string
+	// Columns lists every legacy column except id, name, coordinates, created_at, updated_at and deleted_at.
+	Columns []Column
+}
+
+// Tables lists the legacy tables in migration order.
+var Tables = []Table{
+	{
+		Name: "shelters", PlaceType: "避難", Address: []string{"location"}, Phone: "phone", Contact: "contact_person", Website: "link",
+		Resources: []string{"facilities"}, Statuses: [3]string{"open", "temporary_closed", "closed"},
+		Columns: []Column{
+			{"location", TextNotNull}, {"phone", TextNotNull}, {"link", Text}, {"status", TextNotNull}, {"capacity", Int},
+			{"current_occupancy", Int}, {"available_spaces", Int}, {"facilities", TextArray}, {"contact_person", Text},
+			{"notes", Text}, {"opening_hours", Text},
+		},
+	},
+	{
+		Name: "medical_stations", PlaceType: "醫療", SubType: "station_type", Address: []string{"detailed_address", "location"}, Phone: "phone",
+		Contact: "contact_person", Website: "link", Resources: []string{"services", "equipment"}, Statuses: [3]string{"active", "temporarily_closed", "closed"},
+		Columns: []Column{
+			{"station_type", TextNotNull}, {"location", TextNotNull}, {"detailed_address", Text}, {"phone", Text}, {"contact_person", Text},
+			{"status", TextNotNull}, {"services", TextArray}, {"equipment", TextArray}, {"operating_hours", Text}, {"medical_staff", Int},
+			{"daily_capacity", Int}, {"affiliated_organization", Text}, {"notes", Text}, {"link", Text},
+		},
+	},
+	{
+		Name: "mental_health_resources", PlaceType: "心理援助", SubType: "service_format", Address: []string{"location"}, Phone: "contact_info",
+		Website: "website_url", Resources: []string{"specialties"}, Statuses: [3]string{"active", "paused", "ended"},
+		Columns: []Column{
+			{"duration_type", TextNotNull}, {"service_format", TextNotNull}, {"service_hours", TextNotNull}, {"contact_info", TextNotNull},
+			{"website_url", Text}, {"target_audience", TextArray}, {"specialties", TextArray}, {"languages", TextArray}, {"is_free", Bool},
+			{"location", Text}, {"status", TextNotNull}, {"capacity", Int}, {"waiting_time", Text}, {"notes", Text}, {"emergency_support", Bool},
+		},
+	},
+	{
+		Name: "accommodations", PlaceType: "住宿", Address: []string{"address"}, Phone: "contact_info", InfoSource: "info_source",
+		Resources: []string{"facilities"}, Statuses: [3]string{"active", "paused", "closed"},
+		Columns: []Column{
+			{"township", TextNotNull}, {"has_vacancy", TextNotNull}, {"available_period", TextNotNull}, {"restrictions", Text},
+			{"contact_info", TextNotNull}, {"room_info", Text}, {"address", TextNotNull}, {"pricing", TextNotNull}, {"info_source", Text},
+			{"notes", Text}, {"capacity", Int}, {"status", TextNotNull}, {"registration_method", Text}, {"facilities", TextArray},
+			{"distance_to_disaster_area", Text},
+		},
+	},
+	{
+		Name: "shower_stations", PlaceType: "洗澡", SubType: "facility_type", Address: []string{"address"}, Phone: "phone", InfoSource: "info_source",
+		Resources: []string{"facilities"}, Statuses: [3]string{"active", "paused", "closed"},
+		Columns: []Column{
+			{"address", TextNotNull}, {"phone", Text}, {"facility_type", TextNotNull}, {"time_slots", TextNotNull}, {"gender_schedule", JSONB},
+			{"available_period", TextNotNull}, {"capacity", Int}, {"is_free", Bool}, {"pricing", Text}, {"notes", Text}, {"info_source", Text},
+			{"status", TextNotNull}, {"facilities", TextArray}, {"distance_to_guangfu", Text}, {"requires_appointment", Bool}, {"contact_method", Text},
+		},
+	},
+	{
+		Name: "water_refill_stations", PlaceType: "加水", SubType: "water_type", Address: []string{"address"}, Phone: "phone", InfoSource: "info_source",
+		Resources: []string{"facilities"}, Statuses: [3]string{"active", "paused", "closed"},
+		Columns: []Column{
+			{"address", TextNotNull}, {"phone", Text}, {"water_type", TextNotNull}, {"opening_hours", TextNotNull}, {"is_free", Bool},
+			{"container_required", Text}, {"daily_capacity", Int}, {"status", TextNotNull}, {"water_quality", Text}, {"facilities", TextArray},
+			{"accessibility", Bool}, {"distance_to_disaster_area", Text}, {"notes", Text}, {"info_source", Text},
+		},
+	},
+	{
+		Name: "restrooms", PlaceType: "廁所", SubType: "facility_type", Address: []string{"address"}, Phone: "phone", InfoSource: "info_source",
+		Resources: []string{"facilities"}, Statuses: [3]string{"active", "paused", "closed"},
+		Columns: []Column{
+			{"address", TextNotNull}, {"phone", Text}, {"facility_type", TextNotNull}, {"opening_hours", TextNotNull}, {"is_free", Bool},
+			{"male_units", Int}, {"female_units", Int}, {"unisex_units", Int}, {"accessible_units", Int}, {"has_water", Bool},
+			{"has_lighting", Bool}, {"status", TextNotNull}, {"cleanliness", Text}, {"last_cleaned", Timestamp}, {"facilities", TextArray},
+			{"distance_to_disaster_area", Text}, {"notes", Text}, {"info_source", Text},
+		},
+	},
+}
+
+// Lookup returns the mapping for a legacy table name.
+func Lookup(name string) (Table, bool) {
+	for _, t := range Tables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Table{}, false
+}
+
+// ViewName is the places-backed compatibility view that mirrors the legacy table's columns.
+func ViewName(table string) string { return "places_as_" + table }
+
+// PlaceStatus maps the free-form legacy status onto the places status constraint (開放/暫停/關閉).
+func PlaceStatus(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "full", "paused", "suspended", "temporary_closed", "temporarily_closed", "暫停", "額滿", "已滿":
+		return "暫停"
+	case "closed", "ended", "inactive", "關閉", "已關閉", "結束":
+		return "關閉"
+	default:
+		return "開放"
+	}
+}
+
+// ViewSQL builds the "create or replace view" statement for t. Rows migrated from the legacy
+// table keep their legacy id (via legacy_place_map) and column values; places created natively
+// with the matching type show up too, with their columns mapped back as far as possible.
+// deleted_at follows the place, so a place deleted through /places disappears from the view too.
+func (t Table) ViewSQL() string {
+	cols := []string{"coalesce(m.legacy_id, p.id) as id", "p.name"}
+	for _, c := range t.Columns {
+		cols = append(cols, t.columnExpr(c)+" as "+c.Name)
+	}
+	cols = append(cols, "p.coordinates", "p.created_at", "p.updated_at", "p.deleted_at")
+	return "create or replace view " + ViewName(t.Name) + " as select " + strings.Join(cols, ", ") +
+		" from places p cross join lateral (select p.additional_info->'legacy' as l) x" +
+		" left join legacy_place_map m on m.place_id = p.id and m.legacy_table = '" + t.Name + "'" +
+		" where p.type = '" + t.PlaceType + "'"
+}
+
+func (t Table) columnExpr(c Column) string {
+	raw := "l->>'" + c.Name + "'"
+	fallback := t.placeFallback(c.Name)
+	switch c.Type {
+	case Int:
+		return "(" + raw + ")::int"
+	case Bool:
+		return "coalesce((" + raw + ")::boolean, false)"
+	case TextArray:
+		return "case when jsonb_typeof(l->'" + c.Name + "') = 'array' then array(select jsonb_array_elements_text(l->'" + c.Name + "')) end"
+	case Timestamp:
+		return "(" + raw + ")::timestamptz"
+	case JSONB:
+		return "l->'" + c.Name + "'"
+	}
+	// fallbacks only apply to places that never came from the legacy table
+	if fallback != "" {
+		raw = "case when l is null then " + fallback + " else " + raw + " end"
+	}
+	if c.Type == TextNotNull {
+		return "coalesce(" + raw + ", '')"
+	}
+	return raw
+}
+
+// placeFallback is the places column used for col when the row carries no legacy data.
+func (t Table) placeFallback(col string) string {
+	for _, a := range t.Address {
+		if a == col {
+			return "nullif(p.address, '')"
+		}
+	}
+	switch col {
+	case "status":
+		return "case p.status when '開放' then '" + t.Statuses[0] + "' when '暫停' then '" + t.Statuses[1] + "' else '" + t.Statuses[2] + "' end"
+	case "notes":
+		return "nullif(p.notes, '')"
+	case t.SubType:
+		return "nullif(p.sub_type, '')"
+	case t.Phone:
+		return "nullif(p.contact_phone, '')"
+	case t.Contact:
+		return "nullif(p.contact_name, '')"
+	case t.Website:
+		return "p.website_url"
+	case t.InfoSource:
+		return "p.info_sources[1]"
+	}
+	return ""
+}
+
+// Place is a places row derived from one legacy row. Field names follow the places columns.
+type Place struct {
+	Name           string                   `json:"name"`
+	Address        string                   `json:"address"`
+	Coordinates    map[string]interface{}   `json:"coordinates"`
+	Type           string                   `json:"type"`
+	SubType        string                   `json:"sub_type"`
+	Status         string                   `json:"status"`
+	ContactName    string                   `json:"contact_name"`
+	ContactPhone   string                   `json:"contact_phone"`
+	WebsiteURL     *string                  `json:"website_url"`
+	InfoSources    []string                 `json:"info_sources"`
+	Notes          string                   `json:"notes"`
+	Resources      []map[string]interface{} `json:"resources"`
+	AdditionalInfo map[string]interface{}   `json:"additional_info"`
+	CreatedAt      string                   `json:"-"`
+	UpdatedAt      string                   `json:"-"`
+}
+
+// ToPlace maps a legacy row (as produced by to_jsonb) onto a places row. Every table-specific
+// column is kept under additional_info.legacy so the compatibility view can rebuild the row.
+func (t Table) ToPlace(row map[string]interface{}) Place {
+	p := Place{
+		Name:         str(row["name"]),
+		Type:         t.PlaceType,
+		SubType:      str(row[t.SubType]),
+		Status:       PlaceStatus(str(row["status"])),
+		ContactName:  str(row[t.Contact]),
+		ContactPhone: str(row[t.Phone]),
+		Notes:        str(row["notes"]),
+		CreatedAt:    str(row["created_at"]),
+		UpdatedAt:    str(row["updated_at"]),
+	}
+	for _, a := range t.Address {
+		if v := str(row[a]); v != "" {
+			p.Address = v
+			break
+		}
+	}
+	// places.coordinates is not null; rows without a location keep null lat/lng
+	p.Coordinates = map[string]interface{}{"lat": nil, "lng": nil}
+	if m, ok := row["coordinates"].(map[string]interface{}); ok {
+		p.Coordinates["lat"], p.Coordinates["lng"] = m["lat"], m["lng"]
+	}
+	if v := str(row[t.Website]); v != "" {
+		p.WebsiteURL = &v
+	}
+	if v := str(row[t.InfoSource]); v != "" {
+		p.InfoSources = []string{v}
+	}
+	for _, col := range t.Resources {
+		if arr, ok := row[col].([]interface{}); ok {
+			for _, item := range arr {
+				p.Resources = append(p.Resources, map[string]interface{}{"name": item, "source": col})
+			}
+		}
+	}
+	legacyFields := map[string]interface{}{}
+	for _, c := range t.Columns {
+		if v, ok := row[c.Name]; ok && v != nil {
+			legacyFields[c.Name] = v
+		}
+	}
+	p.AdditionalInfo = map[string]interface{}{"legacy_table": t.Name, "legacy": legacyFields}
+	return p
+}
+
+// Fields returns p as a places-column keyed map, normalized through JSON so it can be compared
+// with a to_jsonb() projection of the current places row.
+func (p Place) Fields() map[string]interface{} {
+	b, _ := json.Marshal(p)
+	var m map[string]interface{}
+	_ = json.Unmarshal(b, &m)
+	return m
+}
+
+// Hash fingerprints a legacy row so re-runs only touch rows that changed since the last run.
+func Hash(row map[string]interface{}) string {
+	b, _ := json.Marshal(row) // map keys are marshalled in sorted order
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func str(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package lineauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type fakeGrant struct {
+	sub, name, nonce, redirectURI string
+	expires                       time.Time
+}
+
+// NewFakeServer stands in for LINE Login for a channel, for local development:
+//
+//	GET  /oauth2/v2.1/authorize  redirects straight back to redirect_uri with a code (no login page);
+//	                             ?sub= and ?name= pick the fake user (default U0000000000000000000000000000fake)
+//	POST /oauth2/v2.1/token      exchanges the code for an HS256 ID token signed with channelSecret
+//
+// Codes are single use and expire after 10 minutes, like LINE's.
+func NewFakeServer(channelID, channelSecret string) http.Handler {
+	var (
+		mu     sync.Mutex
+		grants = map[string]fakeGrant{}
+	)
+	fail := func(w http.ResponseWriter, status int, code, desc string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": desc})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /oauth2/v2.1/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("client_id") != channelID {
+			fail(w, http.StatusBadRequest, "invalid_request", "unknown client_id")
+			return
+		}
+		redirect, err := url.Parse(q.Get("redirect_uri"))
+		if err != nil || redirect.Scheme == "" {
+			fail(w, http.StatusBadRequest, "invalid_request", "redirect_uri is required")
+			return
+		}
+		g := fakeGrant{sub: q.Get("sub"), name: q.Get("name"), nonce: q.Get("nonce"), redirectURI: q.Get("redirect_uri"), expires: time.Now().Add(10 * time.Minute)}
+		if g.sub == "" {
+			g.sub = "U0000000000000000000000000000fake"
+		}
+		if g.name == "" {
+			g.name = "Fake User"
+		}
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		code := hex.EncodeToString(b)
+		mu.Lock()
+		grants[code] = g
+		mu.Unlock()
+		back := redirect.Query()
+		back.Set("code", code)
+		back.Set("state", q.Get("state"))
+		redirect.RawQuery = back.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("POST /oauth2/v2.1/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("grant_type") != "authorization_code" {
+			fail(w, http.StatusBadRequest, "unsupported_grant_type", "")
+			return
+		}
+		if r.PostFormValue("client_id") != channelID || r.PostFormValue("client_secret") != channelSecret {
+			fail(w, http.StatusBadRequest, "invalid_client", "")
+			return
+		}
+		code := r.PostFormValue("code")
+		mu.Lock()
+		g, ok := grants[code]
+		delete(grants, code)
+		mu.Unlock()
+		if !ok || time.Now().After(g.expires) || g.redirectURI != r.PostFormValue("redirect_uri") {
+			fail(w, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+			return
+		}
+		now := time.Now()
+		claims := map[string]interface{}{
+			"iss": DefaultIssuer, "sub": g.sub, "aud": channelID, "exp": now.Add(time.Hour).Unix(), "iat": now.Unix(),
+			"nonce": g.nonce, "name": g.name, "amr": []string{"linesso"},
+		}
+		payload, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		mac := hmac.New(sha256.New, []byte(channelSecret))
+		mac.Write([]byte(signed))
+		idToken := signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "fake-" + code, "expires_in": 2592000, "id_token": idToken,
+			"refresh_token": "fake-refresh-" + code, "scope": "profile openid", "token_type": "Bearer",
+		})
+	})
+	return mux
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth guards the /_admin route group: only callers allowed at least one admin:<area>
+// permission get in (each route then requires its own). Refused attempts go to the server log as
+// well as to request_logs, since repeated ones usually mean someone is probing for the request logs.
+func (a *Authorizer) AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := CurrentPrincipal(c)
+		for _, perm := range a.policy.Permissions() {
+			if strings.HasPrefix(perm, "admin:") && a.policy.Check(p, perm, "", nil).Allowed {
+				c.Next()
+				logRefusedAdmin(c)
+				return
+			}
+		}
+		deny(c, "admin", nil)
+		logRefusedAdmin(c)
+	}
+}
+
+func logRefusedAdmin(c *gin.Context) {
+	if s := c.Writer.Status(); s != http.StatusUnauthorized && s != http.StatusForbidden {
+		return
+	}
+	name := "-"
+	if k := CurrentAPIKey(c); k != nil {
+		name = k.Name
+	} else if u := CurrentUser(c); u != nil {
+		name = "user:" + u.ID
+	}
+	log.Printf("admin auth: %d %s %s from %s (key %s)", c.Writer.Status(), c.Request.Method, c.Request.URL.Path, clientIP(c), name)
+}
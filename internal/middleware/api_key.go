@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// APIKey is the identity an API key resolved to. Keys issued through /_admin/api_keys have an ID;
+// keys from the environment (ALLOW_MODIFY_API_KEY_LIST, SPAM_RESULT_API_KEY) don't.
+type APIKey struct {
+	ID        string
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+const apiKeyContextKey = "middleware.api_key"
+
+// apiKeyCacheTTL bounds how long a revoked or changed key keeps working on other instances.
+const apiKeyCacheTTL = 30 * time.Second
+
+type apiKeyCacheEntry struct {
+	key      *APIKey
+	loadedAt time.Time
+	usedAt   time.Time
+}
+
+// apiKeyCache holds the keys that resolved, by hash. Unknown, revoked and expired keys are not
+// cached, so random X-Api-Key values can't grow it; entries older than apiKeyCacheTTL are swept
+// out at most once per TTL when a key is stored.
+var apiKeyCache = struct {
+	mu      sync.Mutex
+	items   map[string]*apiKeyCacheEntry
+	sweptAt time.Time
+}{items: map[string]*apiKeyCacheEntry{}}
+
+// ForgetAPIKeys drops cached key lookups so issued, rotated or revoked keys take effect at once.
+func ForgetAPIKeys() {
+	apiKeyCache.mu.Lock()
+	apiKeyCache.items = map[string]*apiKeyCacheEntry{}
+	apiKeyCache.mu.Unlock()
+}
+
+// HashAPIKey is the form an API key is stored in (api_keys.key_hash).
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyLabel is how a key from the environment is named: a short fingerprint, never the key.
+func APIKeyLabel(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:4])
+}
+
+// requestAPIKey returns the key a request carries: X-Api-Key: <key> or Authorization: Bearer <key>.
+// A bearer session token (see UserAuth) is not a key.
+func requestAPIKey(c *gin.Context) string {
+	key := strings.TrimSpace(c.GetHeader("X-Api-Key"))
+	if key == "" {
+		auth := c.GetHeader("Authorization")
+		if strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+			key = strings.TrimSpace(auth[7:])
+			if isSessionToken(key) {
+				key = ""
+			}
+		}
+	}
+	return key
+}
+
+// APIKeyAuth resolves the request's API key into an *APIKey stored in the gin context (see
+// CurrentAPIKey). It never rejects a request itself: its scopes become permissions that routes
+// check through Authorizer.Require and Can.
+//
+// Keys are looked up in api_keys (by hash; revoked and expired keys don't resolve) and the
+// ones that resolve cached for apiKeyCacheTTL. Keys from the environment keep working:
+//
+//	ALLOW_MODIFY_API_KEY_LIST  comma-separated keys with every scope ("*")
+//	SPAM_RESULT_API_KEY        a key with the spam_results:write scope
+func APIKeyAuth(pool *pgxpool.Pool) gin.HandlerFunc {
+	envKeys := map[string]*APIKey{}
+	if k := strings.TrimSpace(os.Getenv("SPAM_RESULT_API_KEY")); k != "" {
+		envKeys[k] = &APIKey{Name: APIKeyLabel(k), Scopes: []string{"spam_results:write"}}
+	}
+	for _, part := range strings.Split(os.Getenv("ALLOW_MODIFY_API_KEY_LIST"), ",") {
+		if k := strings.TrimSpace(part); k != "" {
+			envKeys[k] = &APIKey{Name: APIKeyLabel(k), Scopes: []string{"*"}}
+		}
+	}
+
+	lookup := func(hash string) *APIKey {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		k := &APIKey{}
+		err := pool.QueryRow(ctx, `select id,name,scopes,expires_at from api_keys where key_hash=$1 and not revoked and (expires_at is null or expires_at > now())`, hash).
+			Scan(&k.ID, &k.Name, &k.Scopes, &k.ExpiresAt)
+		if err != nil {
+			return nil
+		}
+		return k
+	}
+
+	return func(c *gin.Context) {
+		raw := requestAPIKey(c)
+		if raw == "" {
+			c.Next()
+			return
+		}
+		if k, ok := envKeys[raw]; ok {
+			c.Set(apiKeyContextKey, k)
+			c.Next()
+			return
+		}
+		if pool == nil {
+			c.Next()
+			return
+		}
+		hash := HashAPIKey(raw)
+		now := time.Now()
+		apiKeyCache.mu.Lock()
+		ent, ok := apiKeyCache.items[hash]
+		apiKeyCache.mu.Unlock()
+		if !ok || now.Sub(ent.loadedAt) > apiKeyCacheTTL {
+			ent = &apiKeyCacheEntry{key: lookup(hash), loadedAt: now}
+			apiKeyCache.mu.Lock()
+			if ent.key != nil {
+				apiKeyCache.items[hash] = ent
+			} else {
+				delete(apiKeyCache.items, hash)
+			}
+			if now.Sub(apiKeyCache.sweptAt) > apiKeyCacheTTL {
+				for h, e := range apiKeyCache.items {
+					if now.Sub(e.loadedAt) > apiKeyCacheTTL {
+						delete(apiKeyCache.items, h)
+					}
+				}
+				apiKeyCache.sweptAt = now
+			}
+			apiKeyCache.mu.Unlock()
+		}
+		k := ent.key
+		if k != nil && k.ExpiresAt != nil && now.After(*k.ExpiresAt) {
+			k = nil
+		}
+		if k != nil {
+			c.Set(apiKeyContextKey, k)
+			// last_used_at is informational; write it at most once a minute per key
+			apiKeyCache.mu.Lock()
+			touch := now.Sub(ent.usedAt) > time.Minute
+			if touch {
+				ent.usedAt = now
+			}
+			apiKeyCache.mu.Unlock()
+			if touch {
+				go func(id string) {
+					ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+					defer cancel()
+					_, _ = pool.Exec(ctx, `update api_keys set last_used_at=now() where id=$1`, id)
+				}(k.ID)
+			}
+		}
+		c.Next()
+	}
+}
+
+// CurrentAPIKey returns the identity APIKeyAuth resolved for the request, or nil.
+func CurrentAPIKey(c *gin.Context) *APIKey {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		if k, ok := v.(*APIKey); ok {
+			return k
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/policy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	authorizerContextKey = "middleware.authorizer"
+	principalContextKey  = "middleware.principal"
+	grantedContextKey    = "middleware.granted"
+	deniedContextKey     = "middleware.denied_fields"
+)
+
+// nonFieldKeys are body keys that carry credentials rather than data, so no rule has to allow them.
+var nonFieldKeys = map[string]bool{
+	"valid_pin":             true,
+	"cf-turnstile-response": true,
+	"h-captcha-response":    true,
+	"g-recaptcha-response":  true,
+}
+
+// Authorizer enforces the permissions routes declare against a policy.Policy.
+type Authorizer struct {
+	pool   *pgxpool.Pool
+	policy *policy.Policy
+}
+
+// NewAuthorizer enforces pol; pool is used to find the place of a requirement for site coordinators.
+func NewAuthorizer(pool *pgxpool.Pool, pol *policy.Policy) *Authorizer {
+	return &Authorizer{pool: pool, policy: pol}
+}
+
+// Policy is the policy the authorizer enforces.
+func (a *Authorizer) Policy() *policy.Policy { return a.policy }
+
+// Resolve works out the caller's roles from its API key scopes ("role:<role>") and signed-in
+// user (user_roles), for Require and Can. It goes after APIKeyAuth and UserAuth and never rejects.
+func (a *Authorizer) Resolve() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(authorizerContextKey, a)
+		c.Set(principalContextKey, principalOf(c))
+		c.Next()
+	}
+}
+
+func principalOf(c *gin.Context) policy.Principal {
+	var p policy.Principal
+	if k := CurrentAPIKey(c); k != nil {
+		for _, s := range k.Scopes {
+			if g, ok := strings.CutPrefix(s, "role:"); ok {
+				if grant, ok := policy.ParseGrant(g); ok {
+					p.Grants = append(p.Grants, grant)
+				}
+				continue
+			}
+			p.Scopes = append(p.Scopes, s)
+		}
+	}
+	if u := CurrentUser(c); u != nil {
+		for _, r := range u.Roles {
+			if grant, ok := policy.ParseGrant(r); ok {
+				p.Grants = append(p.Grants, grant)
+			}
+		}
+	}
+	return p
+}
+
+// CurrentPrincipal returns the roles and scopes Resolve found for the request.
+func CurrentPrincipal(c *gin.Context) policy.Principal {
+	if v, ok := c.Get(principalContextKey); ok {
+		if p, ok := v.(policy.Principal); ok {
+			return p
+		}
+	}
+	return principalOf(c)
+}
+
+// Require lets the request through when the caller's roles allow perm for every field of the
+// JSON body. When they don't but the permission has an owner rule, the request goes on without
+// being marked Granted and the handler decides by the row's owner, co-owners or PIN. Otherwise it
+// answers 401 (no credentials) or 403 (listing the fields the caller may not write).
+func (a *Authorizer) Require(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := jsonBody(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		var fields []string
+		for k := range body {
+			if !nonFieldKeys[k] {
+				fields = append(fields, k)
+			}
+		}
+		sort.Strings(fields)
+		p := CurrentPrincipal(c)
+		placeID := ""
+		if p.Coordinates() {
+			placeID = a.placeOf(c, perm, body)
+		}
+		d := a.policy.Check(p, perm, placeID, fields)
+		if d.Allowed {
+			c.Set(grantedContextKey, true)
+			c.Next()
+			return
+		}
+		if d.Owner {
+			c.Set(deniedContextKey, d.Denied)
+			c.Next()
+			return
+		}
+		deny(c, perm, d.Denied)
+	}
+}
+
+// Can reports whether the caller's roles allow perm outright (any field, no place scope). It is
+// for handlers deciding what to show or allow beyond what their route requires.
+func Can(c *gin.Context, perm string) bool {
+	v, ok := c.Get(authorizerContextKey)
+	if !ok {
+		return false
+	}
+	return v.(*Authorizer).policy.Check(CurrentPrincipal(c), perm, "", nil).Allowed
+}
+
+// Granted reports whether Require allowed the request by the caller's roles alone, so the
+// handler needn't check ownership.
+func Granted(c *gin.Context) bool {
+	return c.GetBool(grantedContextKey)
+}
+
+// DeniedFields are the body fields the caller's roles don't cover, when Require left the
+// decision to the handler (empty when the roles cover none of the request).
+func DeniedFields(c *gin.Context) []string {
+	return c.GetStringSlice(deniedContextKey)
+}
+
+// placeOf is the place a request acts on, for site coordinators: the place itself, the place a
+// requirement belongs to (its place_id, or the body's when creating one), or the place of the
+// requirement an allocation is for.
+func (a *Authorizer) placeOf(c *gin.Context, perm string, body map[string]json.RawMessage) string {
+	res, _, _ := strings.Cut(perm, ":")
+	id := c.Param("id")
+	switch res {
+	case "places":
+		return id
+	case "requirements_hr", "requirements_supplies":
+		if id == "" {
+			var placeID string
+			_ = json.Unmarshal(body["place_id"], &placeID)
+			return placeID
+		}
+		if a.pool == nil {
+			return ""
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		var placeID string
+		_ = a.pool.QueryRow(ctx, `select place_id from `+res+` where id=$1`, id).Scan(&placeID)
+		return placeID
+	case "allocations":
+		// the place of the requirement the allocation fills
+		var reqID string
+		if id == "" {
+			_ = json.Unmarshal(body["requirement_id"], &reqID)
+		}
+		if a.pool == nil {
+			return ""
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		var placeID string
+		_ = a.pool.QueryRow(ctx, `select r.place_id from requirements_supplies r
+			where r.id=coalesce(nullif($1,''),(select requirement_id from allocations where id=$2))`, reqID, id).Scan(&placeID)
+		return placeID
+	}
+	return ""
+}
+
+// jsonBody reads the top-level keys of a JSON object body and puts the body back for the
+// handler. Bodies that aren't a JSON object are left for the handler to reject.
+func jsonBody(c *gin.Context) (map[string]json.RawMessage, error) {
+	if c.Request.Body == nil {
+		return nil, nil
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]json.RawMessage
+	if json.Unmarshal(raw, &body) != nil {
+		return nil, nil
+	}
+	return body, nil
+}
+
+// deny answers 401 when the caller brought no (valid) credentials and 403 otherwise, recording
+// the reason with c.Error so request_logs shows why the request was refused.
+func deny(c *gin.Context, perm string, fields []string) {
+	k, u := CurrentAPIKey(c), CurrentUser(c)
+	if k == nil && u == nil {
+		reason := "api key required"
+		if requestAPIKey(c) != "" {
+			reason = "invalid api key"
+		}
+		c.Error(errors.New("unauthorized: " + reason)) //nolint:errcheck
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "reason": reason})
+		c.Abort()
+		return
+	}
+	who := "user:"
+	if k != nil {
+		who = k.Name
+	} else {
+		who += u.ID
+	}
+	resp := gin.H{"error": "insufficient scope", "required": perm}
+	msg := "forbidden: " + who + " lacks " + perm
+	if len(fields) > 0 {
+		resp["fields"] = fields
+		msg += " for " + strings.Join(fields, ",")
+	}
+	c.Error(errors.New(msg)) //nolint:errcheck
+	c.JSON(http.StatusForbidden, resp)
+	c.Abort()
+}
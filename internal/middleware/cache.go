@@ -13,8 +13,9 @@ import (
 )
 
 // CacheHeaders adds basic caching headers (ETag, Cache-Control) for idempotent GET responses.
-// It computes a weak ETag from the response body for 200 OK GET responses up to a size limit.
-// If the client sends If-None-Match matching the computed ETag, a 304 Not Modified is returned.
+// It keeps the strong ETag a handler set (single resources, see handlers.Versioning) and otherwise
+// computes a weak ETag from the response body for 200 OK GET responses up to a size limit.
+// If the client sends If-None-Match matching the ETag, a 304 Not Modified is returned.
 func CacheHeaders(maxBody int) gin.HandlerFunc {
 	if maxBody <= 0 {
 		maxBody = 512 * 1024 // 512KB buffer threshold
@@ -46,9 +47,12 @@ func CacheHeaders(maxBody int) gin.HandlerFunc {
 		}
 
 		body := rw.buf.Bytes()
-		h := sha256.Sum256(body)
-		etag := "W/\"" + hex.EncodeToString(h[:8]) + "\""
 		hdr := rw.Header()
+		etag := hdr.Get("ETag")
+		if etag == "" {
+			h := sha256.Sum256(body)
+			etag = "W/\"" + hex.EncodeToString(h[:8]) + "\""
+		}
 
 		// Handle conditional If-None-Match
 		if inm := c.Request.Header.Get("If-None-Match"); inm != "" {
@@ -60,7 +64,7 @@ func CacheHeaders(maxBody int) gin.HandlerFunc {
 					if hdr.Get("Cache-Control") == "" {
 						hdr.Set("Cache-Control", cacheControlForPath(c.FullPath(), c.Request.URL.RawQuery))
 					}
-					hdr.Set("Vary", "Accept-Encoding")
+					hdr.Set("Vary", "Accept, Accept-Encoding")
 					if hdr.Get("Last-Modified") == "" {
 						hdr.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
 					}
@@ -74,7 +78,7 @@ func CacheHeaders(maxBody int) gin.HandlerFunc {
 		if hdr.Get("Cache-Control") == "" {
 			hdr.Set("Cache-Control", cacheControlForPath(c.FullPath(), c.Request.URL.RawQuery))
 		}
-		hdr.Add("Vary", "Accept-Encoding")
+		hdr.Add("Vary", "Accept, Accept-Encoding")
 		if hdr.Get("Last-Modified") == "" {
 			hdr.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
 		}
@@ -161,6 +165,18 @@ func cacheControlForPath(pattern, rawQuery string) string {
 	if strings.HasPrefix(pattern, "/_admin/") || pattern == "/healthz" || strings.HasPrefix(pattern, "/auth/") {
 		return "no-store"
 	}
+	// history and delivery ledgers depend on the caller's roles (unmasked actor IPs)
+	if strings.HasSuffix(pattern, "/:id/history") || strings.HasSuffix(pattern, "/:id/deliveries") {
+		return "private, no-cache"
+	}
+	// co-owners are only listed to the owner, co-owners and callers allowed <resource>:update
+	if strings.HasSuffix(pattern, "/:id/co_owners") {
+		return "private, no-store"
+	}
+	// include_deleted is only honoured for callers allowed <resource>:audit
+	if strings.Contains(rawQuery, "include_deleted=") {
+		return "private, no-store"
+	}
 	// Highly dynamic aggregated embedding: disable cache to reflect near real-time changes
 	if pattern == "/supplies" || pattern == "/human_resources" {
 		// 需要即時回應
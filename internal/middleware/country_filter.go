@@ -14,6 +14,13 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// denylistGen is bumped by RefreshDenylist; IPFilter reloads ip_denylist when it changes.
+var denylistGen atomic.Int64
+
+// RefreshDenylist makes IPFilter reload ip_denylist on the next request instead of waiting for
+// its periodic refresh. Call it after the change is committed.
+func RefreshDenylist() { denylistGen.Add(1) }
+
 // IPFilter (formerly CountryFilter) restricts POST/PATCH requests based on Cloudflare-provided country header.
 // Despite the name change, current implementation still uses Cf-Ipcountry. If you later need pure IP allowlisting,
 // extend here to parse client IP (see middleware.clientIP) and compare with an ALLOWED_IPS list.
@@ -75,19 +82,20 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 		}
 	}
 
-	// Denylist cache (ip_denylist table). We keep a slice of *net.IPNet; single IP stored as /32 or /128.
+	// Denylist cache (ip_denylist table, expired entries skipped). We keep a slice of *net.IPNet; single IP stored as /32 or /128.
 	type denyCache struct {
+		gen      int64 // denylistGen at load time
 		loadedAt time.Time
 		nets     []*net.IPNet
 		singles  map[string]struct{} // exact IP strings
 	}
 	var cache atomic.Value
 	loadDeny := func(ctx context.Context) denyCache {
-		dc := denyCache{loadedAt: time.Now(), singles: map[string]struct{}{}}
+		dc := denyCache{gen: denylistGen.Load(), loadedAt: time.Now(), singles: map[string]struct{}{}}
 		if pool == nil {
 			return dc
 		}
-		rows, err := pool.Query(ctx, `select pattern from ip_denylist`)
+		rows, err := pool.Query(ctx, `select pattern from ip_denylist where expires_at is null or expires_at > now()`)
 		if err != nil {
 			return dc
 		}
@@ -122,6 +130,12 @@ func IPFilter(pool *pgxpool.Pool) gin.HandlerFunc {
 
 	ensureFresh := func() denyCache {
 		v := cache.Load().(denyCache)
+		// The denylist was changed through the API: reload before deciding on this request.
+		if v.gen != denylistGen.Load() {
+			v = loadDeny(context.Background())
+			cache.Store(v)
+			return v
+		}
 		if time.Since(v.loadedAt) < refreshInterval {
 			return v
 		}
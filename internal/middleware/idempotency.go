@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"guangfu250923/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxIdempotencyKey bounds the Idempotency-Key header; clients are expected to send a UUID.
+const maxIdempotencyKey = 255
+
+// Idempotency makes POST requests sent with an Idempotency-Key header safe to retry: the first
+// request with a key runs as usual and its response is stored (in idempotency_keys, in the same
+// transaction as the request's writes) for IDEMPOTENCY_TTL_HOURS (default 24); a retry with the
+// same key and the same request gets the stored response back, with Idempotent-Replayed: true,
+// without running the handler again. Reusing a key for a different request (method, path or body)
+// answers 422. Keys belong to the caller's API key or signed-in user; anonymous callers share one
+// space, so their keys must be unguessable. A retry arriving while the first request is still
+// running waits for it. Failed requests (>= 400) are rolled back and store nothing, so they can be
+// retried with the same key. It goes after RequestTx; /auth/ routes are left alone.
+func Idempotency(pool *pgxpool.Pool) gin.HandlerFunc {
+	ttlHours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_TTL_HOURS"))
+	if err != nil || ttlHours <= 0 {
+		ttlHours = 24
+	}
+
+	// Drop expired keys.
+	go func() {
+		for range time.Tick(time.Hour) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if _, err := pool.Exec(ctx, `delete from idempotency_keys where expires_at < now()`); err != nil {
+				log.Printf("idempotency: purge expired keys: %v", err)
+			}
+			cancel()
+		}
+	}()
+
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if key == "" || c.Request.Method != http.MethodPost || strings.HasPrefix(c.FullPath(), "/auth/") {
+			c.Next()
+			return
+		}
+		v, ok := c.Get(db.RequestTxKey)
+		if !ok {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key too long (max " + strconv.Itoa(maxIdempotencyKey) + ")"})
+			c.Abort()
+			return
+		}
+		var body []byte
+		if c.Request.Body != nil {
+			body, err = io.ReadAll(c.Request.Body)
+			c.Request.Body.Close()
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+		}
+		sum := sha256.Sum256([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n" + string(body)))
+		hash := hex.EncodeToString(sum[:])
+		scope := ""
+		if k := CurrentAPIKey(c); k != nil {
+			scope = k.Name
+		} else if u := CurrentUser(c); u != nil {
+			scope = "user:" + u.ID
+		}
+
+		ctx := context.Background()
+		tx := v.(*db.RequestTx).DB(ctx)
+		if _, err := tx.Exec(ctx, `delete from idempotency_keys where scope=$1 and key=$2 and expires_at < now()`, scope, key); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		// blocks while a request with the same key is in flight, until it commits or rolls back
+		tag, err := tx.Exec(ctx, `insert into idempotency_keys(scope,key,request_hash,expires_at) values($1,$2,$3,now()+make_interval(hours => $4))
+			on conflict do nothing`, scope, key, hash, ttlHours)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			replayIdempotent(c, tx, scope, key, hash)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+		status := c.Writer.Status()
+		if status >= 400 || len(c.Errors) > 0 {
+			return // rolled back together with the key
+		}
+		if _, err := tx.Exec(ctx, `update idempotency_keys set status=$3, content_type=$4, body=$5 where scope=$1 and key=$2`,
+			scope, key, status, rec.Header().Get("Content-Type"), rec.buf.Bytes()); err != nil {
+			// the failed statement aborts the transaction, so the commit fails and the client gets a 500
+			log.Printf("idempotency: store response for %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		}
+	}
+}
+
+// replayIdempotent answers with the stored response of a key, or 422 when it was stored for a
+// different request.
+func replayIdempotent(c *gin.Context, tx db.DBTX, scope, key, hash string) {
+	var storedHash string
+	var status int
+	var contentType *string
+	var body []byte
+	err := tx.QueryRow(context.Background(), `select request_hash,status,content_type,body from idempotency_keys where scope=$1 and key=$2`, scope, key).
+		Scan(&storedHash, &status, &contentType, &body)
+	if err == pgx.ErrNoRows {
+		// expired and purged between the insert and now; the client can retry
+		c.JSON(http.StatusConflict, gin.H{"error": "idempotency key expired, retry the request"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+	if storedHash != hash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+		c.Abort()
+		return
+	}
+	if contentType != nil && *contentType != "" {
+		c.Header("Content-Type", *contentType)
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(status)
+	if len(body) > 0 {
+		c.Writer.Write(body) //nolint:errcheck
+	}
+	c.Abort()
+}
+
+// idempotencyRecorder keeps a copy of the response body to store with the key.
+type idempotencyRecorder struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (r *idempotencyRecorder) Write(b []byte) (int, error) {
+	r.buf.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *idempotencyRecorder) WriteString(s string) (int, error) {
+	r.buf.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
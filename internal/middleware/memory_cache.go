@@ -41,7 +41,16 @@ func MemoryCache(ttl time.Duration, maxBody int) gin.HandlerFunc {
 	buildKey := func(c *gin.Context) string {
 		// Use the actual request path (not the route pattern) to keep distinct keys per entity id.
 		path := c.Request.URL.Path
-		return c.Request.Method + " " + path + "?" + c.Request.URL.RawQuery
+		key := c.Request.Method + " " + path + "?" + c.Request.URL.RawQuery
+		// GeoJSON can also be negotiated via Accept; keep it apart from the JSON-LD variant.
+		if strings.Contains(c.GetHeader("Accept"), "application/geo+json") {
+			key += "#geojson"
+		}
+		// likewise CSV exports (GET /stats/needs)
+		if strings.Contains(c.GetHeader("Accept"), "text/csv") {
+			key += "#csv"
+		}
+		return key
 	}
 
 	// simple allow-list for caching; skip admin/auth/healthz by default
@@ -59,6 +68,14 @@ func MemoryCache(ttl time.Duration, maxBody int) gin.HandlerFunc {
 		if strings.HasPrefix(p, "/swagger/") {
 			return true
 		}
+		// history and delivery ledgers show full actor IPs to auditors, so they can't be shared between callers
+		if strings.HasSuffix(p, "/:id/history") || strings.HasSuffix(p, "/:id/deliveries") || strings.HasSuffix(p, "/:id/co_owners") {
+			return true
+		}
+		// soft-deleted rows are only listed for callers allowed <resource>:audit
+		if c.Query("include_deleted") != "" {
+			return true
+		}
 		return false
 	}
 
@@ -29,6 +29,19 @@ func MemoryCacheInvalidator() gin.HandlerFunc {
         "/places",
         "/requirements_hr",
     "/requirements_supplies",
+        "/allocations",
+    }
+    // writes that change what other resources show: deliveries (POST /supplies/:id) change the
+    // items and their ledgers, items are embedded in supplies, pledges change items' outstanding counts
+    // and requirements' matches (as do item names and place coordinates), allocations take from
+    // pledges and fill requirements
+    related := map[string][]string{
+        "/supplies":              {"/supply_items"},
+        "/supply_items":          {"/supplies", "/requirements_supplies"},
+        "/supply_providers":      {"/supply_items", "/supplies", "/requirements_supplies", "/allocations"},
+        "/places":                {"/requirements_supplies"},
+        "/requirements_supplies": {"/allocations"},
+        "/allocations":           {"/requirements_supplies", "/supply_providers", "/supply_items", "/supplies"},
     }
     return func(c *gin.Context) {
         method := c.Request.Method
@@ -45,6 +58,11 @@ func MemoryCacheInvalidator() gin.HandlerFunc {
             for _, p := range prefixes {
                 if strings.HasPrefix(path, p) {
                     InvalidateMemoryCacheByPrefix(p)
+                    for _, r := range related[p] {
+                        InvalidateMemoryCacheByPrefix(r)
+                    }
+                    // /map/features aggregates the resource tables, so it is stale as well
+                    InvalidateMemoryCacheByPrefix("/map/")
                     return
                 }
             }
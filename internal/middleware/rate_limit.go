@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RateLimitRule is the token bucket for one route. Anonymous requests get a bucket per client IP;
+// requests with a valid API key get a bucket per key, with the Key* limits.
+type RateLimitRule struct {
+	Method       string  // HTTP method, or "*" for every write method
+	Path         string  // gin route pattern (c.FullPath()), or "" for every route
+	PerMinute    float64 // refill rate per client IP
+	Burst        int     // bucket size per client IP
+	KeyPerMinute float64 // refill rate per API key; 0 leaves requests with a key unlimited
+	KeyBurst     int
+}
+
+func (r RateLimitRule) matches(method, path string) bool {
+	return (r.Method == "*" || r.Method == method) && (r.Path == "" || r.Path == path)
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time since its last use and takes one token. It returns the
+// tokens left and, when the bucket is empty, how long until the next token.
+func (b *tokenBucket) take(now time.Time, perSec, burst float64) (bool, float64, time.Duration) {
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*perSec)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, b.tokens, 0
+	}
+	return false, b.tokens, time.Duration((1 - b.tokens) / perSec * float64(time.Second))
+}
+
+// RateLimit throttles write requests (POST/PATCH/PUT/DELETE) with token buckets; the first rule
+// matching the route applies and routes without a rule aren't limited. Responses carry
+// RateLimit-Limit / RateLimit-Remaining / RateLimit-Reset, and a 429 also Retry-After.
+//
+// A client IP that keeps hitting the limit (RATE_LIMIT_BAN_AFTER refusals within 10 minutes,
+// default 30) is added to ip_denylist for RATE_LIMIT_BAN_MINUTES (default 60), so IPFilter blocks
+// its writes on every instance (on this one right away). RATE_LIMIT_ENABLED=false turns the
+// middleware off.
+func RateLimit(pool *pgxpool.Pool, rules []RateLimitRule) gin.HandlerFunc {
+	if strings.EqualFold(os.Getenv("RATE_LIMIT_ENABLED"), "false") {
+		return func(c *gin.Context) { c.Next() }
+	}
+	banAfter, err := strconv.Atoi(os.Getenv("RATE_LIMIT_BAN_AFTER"))
+	if err != nil || banAfter < 0 {
+		banAfter = 30
+	}
+	banMinutes, err := strconv.Atoi(os.Getenv("RATE_LIMIT_BAN_MINUTES"))
+	if err != nil || banMinutes <= 0 {
+		banMinutes = 60
+	}
+	const offenceWindow = 10 * time.Minute
+
+	type offender struct {
+		count int
+		since time.Time
+	}
+	var (
+		mu        sync.Mutex
+		buckets   = map[string]*tokenBucket{}
+		offenders = map[string]*offender{}
+	)
+
+	// Drop idle buckets (idle long enough to be full again) and stale offence counters.
+	go func() {
+		for range time.Tick(time.Minute) {
+			now := time.Now()
+			mu.Lock()
+			for k, b := range buckets {
+				if now.Sub(b.last) > 10*time.Minute {
+					delete(buckets, k)
+				}
+			}
+			for ip, o := range offenders {
+				if now.Sub(o.since) > offenceWindow {
+					delete(offenders, ip)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	ban := func(ip string, refusals int) {
+		if pool == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		reason := "rate limit: " + strconv.Itoa(refusals) + " refused requests within " + offenceWindow.String()
+		// other instances may have banned the IP already and not reloaded the denylist yet
+		tag, err := pool.Exec(ctx, `insert into ip_denylist(pattern,reason,created_by,expires_at)
+			select $1::text,$2::text,'rate_limit',now()+make_interval(mins => $3)
+			where not exists (select 1 from ip_denylist where pattern=$1 and (expires_at is null or expires_at > now()))`, ip, reason, banMinutes)
+		if err != nil {
+			log.Printf("rate limit: denylist %s: %v", ip, err)
+			return
+		}
+		RefreshDenylist()
+		if tag.RowsAffected() == 0 {
+			return
+		}
+		log.Printf("rate limit: %s added to ip_denylist for %d minutes (%s)", ip, banMinutes, reason)
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		var rule *RateLimitRule
+		for i := range rules {
+			if rules[i].matches(c.Request.Method, c.FullPath()) {
+				rule = &rules[i]
+				break
+			}
+		}
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		ip := clientIP(c)
+		subject, perMinute, burst := "ip:"+ip, rule.PerMinute, rule.Burst
+		if k := CurrentAPIKey(c); k != nil {
+			if rule.KeyPerMinute <= 0 {
+				c.Next()
+				return
+			}
+			subject, perMinute, burst = "key:"+k.Name, rule.KeyPerMinute, rule.KeyBurst
+		}
+		if perMinute <= 0 || burst <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		mu.Lock()
+		key := rule.Method + " " + rule.Path + " " + subject
+		b, ok := buckets[key]
+		if !ok {
+			b = &tokenBucket{tokens: float64(burst), last: now}
+			buckets[key] = b
+		}
+		perSec := perMinute / 60
+		allowed, remaining, wait := b.take(now, perSec, float64(burst))
+		var refusals int
+		if !allowed && strings.HasPrefix(subject, "ip:") && banAfter > 0 {
+			o, ok := offenders[ip]
+			if !ok || now.Sub(o.since) > offenceWindow {
+				o = &offender{since: now}
+				offenders[ip] = o
+			}
+			o.count++
+			if o.count >= banAfter {
+				refusals = o.count
+				delete(offenders, ip)
+			}
+		}
+		mu.Unlock()
+
+		reset := int(math.Ceil((float64(burst) - remaining) / perSec))
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(int(remaining)))
+		h.Set("RateLimit-Reset", strconv.Itoa(reset))
+		if !allowed {
+			if refusals > 0 {
+				go ban(ip, refusals)
+			}
+			retry := int(math.Ceil(wait.Seconds()))
+			h.Set("Retry-After", strconv.Itoa(retry))
+			c.Error(errors.New("rate limited: " + subject)) //nolint:errcheck
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limited", "retry_after": retry})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -55,7 +55,7 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 		if c.Request.Body != nil && (c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPatch) {
 			// read and replace body so handler can still consume
 			bodyBytes, _ := io.ReadAll(io.LimitReader(c.Request.Body, 256*1024))
-			rawBody = bodyBytes
+			rawBody = redactPin(bodyBytes)
 			c.Request.Body.Close()
 			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 		}
@@ -79,6 +79,10 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 				continue
 			}
 			joined := v[0]
+			// never store credentials; the resolved key is logged as api_key_id / api_key_name
+			if k == "X-Api-Key" || k == "Authorization" {
+				joined = "[redacted]"
+			}
 			if len(joined) > maxHeaderBytes {
 				joined = joined[:maxHeaderBytes]
 			}
@@ -98,8 +102,23 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 		// Serialize headers
 		headersJSON, _ := jsonMarshal(headersMap)
 
+		result := recorder.buf.Bytes()
+		// issued / rotated API keys and reset PINs are returned in plain text exactly once, and login
+		// responses carry LINE and session tokens; keep them out of the log
+		if strings.HasPrefix(c.FullPath(), "/_admin/api_keys") || strings.HasPrefix(c.FullPath(), "/auth/") || strings.HasSuffix(c.FullPath(), "/reset_pin") {
+			result = nil
+		}
+
+		var keyID, keyName *string
+		if k := CurrentAPIKey(c); k != nil {
+			keyName = &k.Name
+			if k.ID != "" {
+				keyID = &k.ID
+			}
+		}
+
 		// Insert asynchronously (fire and forget)
-		go func(method, path, rawQuery, ip string, status int, errText string, headers []byte, took time.Duration, reqBody []byte, orig json.RawMessage, result json.RawMessage, resID *string) {
+		go func(method, path, rawQuery, ip string, status int, errText string, headers []byte, took time.Duration, reqBody []byte, orig json.RawMessage, result json.RawMessage, resID *string, keyID, keyName *string) {
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
 			var rid interface{}
@@ -108,14 +127,33 @@ func RequestLogger(pool *pgxpool.Pool, maxHeaderBytes int) gin.HandlerFunc {
 			} else {
 				rid = nil
 			}
-			_, _ = pool.Exec(ctx, `insert into request_logs(method,path,query,ip,headers,status_code,error,duration_ms,request_body,original_data,result_data,resource_id) values($1,$2,$3,$4,$5::jsonb,$6,$7,$8,$9::jsonb,$10::jsonb,$11::jsonb,$12)`,
-				method, path, rawQuery, ip, string(headers), status, nullIfEmpty(errText), int(took.Milliseconds()), jsonOrNull(reqBody), jsonOrNull(orig), jsonOrNull(result), rid)
-		}(c.Request.Method, c.FullPath(), c.Request.URL.RawQuery, clientIP(c), recorder.status, errMsg, headersJSON, dur, rawBody, originalData, recorder.buf.Bytes(), resourceID)
+			_, _ = pool.Exec(ctx, `insert into request_logs(method,path,query,ip,headers,status_code,error,duration_ms,request_body,original_data,result_data,resource_id,api_key_id,api_key_name) values($1,$2,$3,$4,$5::jsonb,$6,$7,$8,$9::jsonb,$10::jsonb,$11::jsonb,$12,$13,$14)`,
+				method, path, rawQuery, ip, string(headers), status, nullIfEmpty(errText), int(took.Milliseconds()), jsonOrNull(reqBody), jsonOrNull(orig), jsonOrNull(result), rid, keyID, keyName)
+		}(c.Request.Method, c.FullPath(), c.Request.URL.RawQuery, clientIP(c), recorder.status, errMsg, headersJSON, dur, rawBody, originalData, result, resourceID, keyID, keyName)
 	}
 }
 
 // Helper functions (minimal to avoid extra deps)
 
+// redactPin blanks the valid_pin field of a logged JSON body; PINs are only stored hashed.
+func redactPin(body []byte) []byte {
+	if !bytes.Contains(body, []byte(`"valid_pin"`)) {
+		return body
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil
+	}
+	if _, ok := m["valid_pin"]; ok {
+		m["valid_pin"] = "[redacted]"
+	}
+	out, err := json.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
 func nullIfEmpty(s string) *string {
 	if s == "" {
 		return nil
@@ -270,6 +308,9 @@ func fetchOriginal(c *gin.Context, pool *pgxpool.Pool, pattern, id string) []byt
 	return []byte(*raw)
 }
 
+// ClientIP is the client address used for logging, IP filtering and rate limiting.
+func ClientIP(c *gin.Context) string { return clientIP(c) }
+
 func clientIP(c *gin.Context) string {
 	// Priority order (Cloudflare aware):
 	// 1. CF-Connecting-IP
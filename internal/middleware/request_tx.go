@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+
+	"guangfu250923/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RequestTx gives every write request (POST/PATCH/PUT/DELETE) one database transaction, tagged
+// with the caller (client IP, and the API key name or user:<id> for a signed-in user) so
+// entity_versions can record who changed what. Handlers reach it through c.Get(db.RequestTxKey).
+// The transaction commits when the handler answered < 400 and rolls back otherwise; the response
+// is held back until the commit so clients never see uncommitted data.
+func RequestTx(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		actor := db.Actor{IP: clientIP(c)}
+		if k := CurrentAPIKey(c); k != nil {
+			actor.APIKey = k.Name
+		} else if u := CurrentUser(c); u != nil {
+			actor.APIKey = "user:" + u.ID
+		}
+		rt := db.NewRequestTx(pool, actor)
+		c.Set(db.RequestTxKey, rt)
+		rec := &txRecorder{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = rec
+		c.Next()
+
+		commit := rec.status < 400 && len(c.Errors) == 0
+		if err := rt.Finish(context.Background(), commit); err != nil {
+			log.Printf("request tx %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+			rec.buf.Reset()
+			rec.status = http.StatusInternalServerError
+			rec.Header().Set("Content-Type", "application/json; charset=utf-8")
+			rec.buf.WriteString(`{"error":"commit failed"}`)
+		}
+		rec.flush()
+	}
+}
+
+// txRecorder buffers the handler's response until the transaction outcome is known.
+type txRecorder struct {
+	gin.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (r *txRecorder) WriteHeader(code int)              { r.status = code }
+func (r *txRecorder) WriteHeaderNow()                   {}
+func (r *txRecorder) Status() int                       { return r.status }
+func (r *txRecorder) Write(b []byte) (int, error)       { return r.buf.Write(b) }
+func (r *txRecorder) WriteString(s string) (int, error) { return r.buf.WriteString(s) }
+
+func (r *txRecorder) flush() {
+	r.ResponseWriter.WriteHeader(r.status)
+	if r.buf.Len() > 0 {
+		r.ResponseWriter.Write(r.buf.Bytes())
+	}
+}
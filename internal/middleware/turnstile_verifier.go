@@ -1,9 +1,19 @@
 package middleware
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"guangfu250923/internal/turnstile"
 
@@ -12,22 +22,174 @@ import (
 
 type tokenRequest struct {
 	CFTurnstileResponse string `json:"cf-turnstile-response"`
+	HCaptchaResponse    string `json:"h-captcha-response"`
+	RecaptchaResponse   string `json:"g-recaptcha-response"`
+}
+
+// defaultTurnstileRoutes are the public create endpoints that require a Turnstile token when
+// TURNSTILE_ROUTES is not set.
+const defaultTurnstileRoutes = "POST /supplies,POST /human_resources,POST /reports,POST /supply_providers,POST /places"
+
+var (
+	captchaVerifierOnce sync.Once
+	captchaVerifier     turnstile.TokenVerifier
+)
+
+// sharedVerifier builds the captcha verifier once, so TurnstileVerifier and TurnstileRoutes share
+// one result cache. It is nil (verification off) unless VERIFY_TURNSTILE or VERIFY_CAPTCHA is
+// true and the secret of the provider chosen by CAPTCHA_PROVIDER (turnstile, hcaptcha or
+// recaptcha; default turnstile) is set.
+func sharedVerifier() turnstile.TokenVerifier {
+	captchaVerifierOnce.Do(func() { captchaVerifier = setupVerifier() })
+	return captchaVerifier
 }
 
 func setupVerifier() turnstile.TokenVerifier {
-	secretKey := os.Getenv("TURNSTILE_SECRET_KEY")
-	if !strings.EqualFold(os.Getenv("VERIFY_TURNSTILE"), "true") || secretKey == "" {
+	if !strings.EqualFold(os.Getenv("VERIFY_TURNSTILE"), "true") && !strings.EqualFold(os.Getenv("VERIFY_CAPTCHA"), "true") {
 		return nil
 	}
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("CAPTCHA_PROVIDER")))
+	var v turnstile.TokenVerifier
+	switch provider {
+	case "", "turnstile":
+		provider = "turnstile"
+		if secretKey := os.Getenv("TURNSTILE_SECRET_KEY"); secretKey != "" {
+			v = turnstile.NewTokenVerifier(turnstile.NewTokenVerifierOptions{
+				APIURL:    os.Getenv("TURNSTILE_API_URL"),
+				SecretKey: secretKey,
+			})
+		}
+	case "hcaptcha":
+		if secretKey := os.Getenv("HCAPTCHA_SECRET_KEY"); secretKey != "" {
+			v = turnstile.NewHCaptchaVerifier(turnstile.NewHCaptchaVerifierOptions{
+				APIURL:    os.Getenv("HCAPTCHA_API_URL"),
+				SecretKey: secretKey,
+				SiteKey:   os.Getenv("HCAPTCHA_SITE_KEY"),
+			})
+		}
+	case "recaptcha":
+		if secretKey := os.Getenv("RECAPTCHA_SECRET_KEY"); secretKey != "" {
+			minScore, _ := strconv.ParseFloat(os.Getenv("RECAPTCHA_MIN_SCORE"), 64)
+			v = turnstile.NewRecaptchaVerifier(turnstile.NewRecaptchaVerifierOptions{
+				APIURL:    os.Getenv("RECAPTCHA_API_URL"),
+				SecretKey: secretKey,
+				MinScore:  minScore,
+				Action:    os.Getenv("RECAPTCHA_ACTION"),
+			})
+		}
+	default:
+		log.Printf("captcha: unknown CAPTCHA_PROVIDER %q, verification disabled", provider)
+		return nil
+	}
+	if v == nil {
+		log.Printf("captcha: %s secret key not set, verification disabled", provider)
+		return nil
+	}
+
+	ttl := 300
+	if n, err := strconv.Atoi(os.Getenv("CAPTCHA_CACHE_TTL_SEC")); err == nil && n >= 0 {
+		ttl = n
+	}
+	metrics := turnstile.MetricsFor(provider)
+	if ttl > 0 {
+		v = turnstile.NewCachedVerifier(v, time.Duration(ttl)*time.Second, metrics)
+	}
+	return turnstile.NewCountingVerifier(v, metrics)
+}
+
+// turnstileToken reads the token from the X-Turnstile-Token (or X-Captcha-Token) header or from
+// the cf-turnstile-response, h-captcha-response or g-recaptcha-response body field. The body is
+// put back so the handler can still bind it.
+func turnstileToken(c *gin.Context) (string, error) {
+	for _, h := range []string{"X-Turnstile-Token", "X-Captcha-Token"} {
+		if token := strings.TrimSpace(c.GetHeader(h)); token != "" {
+			return token, nil
+		}
+	}
+	if c.Request.Body == nil {
+		return "", nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return "", nil
+	}
+	var in tokenRequest
+	if err := json.Unmarshal(body, &in); err != nil {
+		return "", err
+	}
+	for _, token := range []string{in.CFTurnstileResponse, in.HCaptchaResponse, in.RecaptchaResponse} {
+		if token != "" {
+			return token, nil
+		}
+	}
+	return "", nil
+}
+
+// requestFingerprint hashes the request's method, path and body, so a cached verification
+// result is only reused for a byte-identical retry. The body is put back for the handler.
+func requestFingerprint(c *gin.Context) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(c.Request.Body)
+		c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+	}
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\x00"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyTurnstile answers 400 and aborts unless the request carries a valid token.
+func verifyTurnstile(c *gin.Context, verifier turnstile.TokenVerifier) bool {
+	block := func(reason string) bool {
+		c.Error(errors.New("turnstile: " + reason)) //nolint:errcheck
+		c.JSON(http.StatusBadRequest, gin.H{"error": "blocked", "reason": reason})
+		c.Abort()
+		return false
+	}
 
-	return turnstile.NewTokenVerifier(turnstile.NewTokenVerifierOptions{
-		APIURL:    os.Getenv("TURNSTILE_API_URL"),
-		SecretKey: secretKey,
+	token, err := turnstileToken(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return false
+	}
+	if token == "" {
+		return block("turnstile token is required")
+	}
+
+	fingerprint, err := requestFingerprint(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return false
+	}
+	success, err := verifier.Verify(turnstile.VerifyOptions{
+		Token:    token,
+		RemoteIP: clientIP(c),
+		Request:  fingerprint,
 	})
+	if err != nil {
+		return block("failed to verify turnstile token")
+	}
+	if !success {
+		return block("invalid turnstile token")
+	}
+	return true
 }
 
 func TurnstileVerifier() gin.HandlerFunc {
-	verifier := setupVerifier()
+	verifier := sharedVerifier()
 
 	return func(c *gin.Context) {
 		// if verifier is not setup, just proceed
@@ -35,39 +197,39 @@ func TurnstileVerifier() gin.HandlerFunc {
 			c.Next()
 			return
 		}
-
-		var in tokenRequest
-		if err := c.ShouldBindBodyWithJSON(&in); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			c.Abort()
+		if !verifyTurnstile(c, verifier) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		token := in.CFTurnstileResponse
-		if token == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "blocked", "reason": "turnstile token is required"})
-			c.Abort()
-			return
+// TurnstileRoutes requires a Turnstile token on the routes listed in TURNSTILE_ROUTES
+// (comma-separated "METHOD /route/pattern", defaulting to the public create endpoints).
+// Requests with a valid API key (partners syncing data) skip the check. Like TurnstileVerifier
+// it does nothing unless verification is enabled (see sharedVerifier).
+func TurnstileRoutes() gin.HandlerFunc {
+	verifier := sharedVerifier()
+	raw := os.Getenv("TURNSTILE_ROUTES")
+	if strings.TrimSpace(raw) == "" {
+		raw = defaultTurnstileRoutes
+	}
+	routes := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 2 {
+			routes[strings.ToUpper(fields[0])+" "+fields[1]] = true
 		}
+	}
 
-		success, err := verifier.Verify(turnstile.VerifyOptions{
-			Token: token,
-			// TODO: should extract the clientIP function to some utils package
-			RemoteIP: c.ClientIP(),
-		})
-
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "blocked", "reason": "failed to verify turnstile token"})
-			c.Abort()
+	return func(c *gin.Context) {
+		if verifier == nil || !routes[c.Request.Method+" "+c.FullPath()] || CurrentAPIKey(c) != nil {
+			c.Next()
 			return
 		}
-
-		if !success {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "blocked", "reason": "invalid turnstile token"})
-			c.Abort()
+		if !verifyTurnstile(c, verifier) {
 			return
 		}
-
 		c.Next()
 	}
 }
@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// User is the signed-in person a session token resolved to.
+type User struct {
+	ID          string
+	SessionID   string
+	DisplayName string
+	Roles       []string // user_roles as "<role>" or "site_coordinator:<place id>"
+}
+
+const userContextKey = "middleware.user"
+
+// sessionCacheTTL bounds how long a session revoked on another instance keeps working here.
+const sessionCacheTTL = 30 * time.Second
+
+var sessionTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+type sessionClaims struct {
+	SessionID string `json:"sid"`
+	UserID    string `json:"sub"`
+	Exp       int64  `json:"exp"`
+}
+
+type sessionCacheEntry struct {
+	user     *User // nil: unknown, revoked or expired
+	loadedAt time.Time
+	usedAt   time.Time
+}
+
+// sessionCache holds session lookups by session id. Entries older than sessionCacheTTL are swept
+// out at most once per TTL when a lookup is stored, so sessions that expire or stop being used
+// don't stay in memory until logout.
+var sessionCache = struct {
+	mu      sync.Mutex
+	items   map[string]*sessionCacheEntry
+	sweptAt time.Time
+}{items: map[string]*sessionCacheEntry{}}
+
+// ForgetSession drops the cached lookup of a session so a logout takes effect at once.
+func ForgetSession(id string) {
+	sessionCache.mu.Lock()
+	delete(sessionCache.items, id)
+	sessionCache.mu.Unlock()
+}
+
+// ForgetSessions drops every cached session lookup so role changes take effect at once.
+func ForgetSessions() {
+	sessionCache.mu.Lock()
+	sessionCache.items = map[string]*sessionCacheEntry{}
+	sessionCache.mu.Unlock()
+}
+
+// SessionTTL is how long a session token is valid: SESSION_TTL_HOURS, default 30 days.
+func SessionTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SESSION_TTL_HOURS") + "h"); err == nil && d > 0 {
+		return d
+	}
+	return 30 * 24 * time.Hour
+}
+
+// sessionSecret signs session tokens: SESSION_SECRET, or LINE_JWT_STATE_SECRET when unset.
+func sessionSecret() []byte {
+	if s := os.Getenv("SESSION_SECRET"); s != "" {
+		return []byte(s)
+	}
+	return []byte(os.Getenv("LINE_JWT_STATE_SECRET"))
+}
+
+func signSession(data string) string {
+	mac := hmac.New(sha256.New, sessionSecret())
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IssueSessionToken signs a token for the user_sessions row sessionID.
+func IssueSessionToken(sessionID, userID string, expires time.Time) (string, error) {
+	if len(sessionSecret()) == 0 {
+		return "", errors.New("missing session secret")
+	}
+	b, _ := json.Marshal(sessionClaims{SessionID: sessionID, UserID: userID, Exp: expires.Unix()})
+	data := sessionTokenHeader + "." + base64.RawURLEncoding.EncodeToString(b)
+	return data + "." + signSession(data), nil
+}
+
+func parseSessionToken(tok string) (*sessionClaims, error) {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 || parts[0] != sessionTokenHeader || len(sessionSecret()) == 0 {
+		return nil, errors.New("bad session token")
+	}
+	if !hmac.Equal([]byte(signSession(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return nil, errors.New("bad session signature")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var cl sessionClaims
+	if err := json.Unmarshal(b, &cl); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() >= cl.Exp {
+		return nil, errors.New("session expired")
+	}
+	return &cl, nil
+}
+
+// isSessionToken tells session tokens (three dot-separated parts) from API keys, which share the
+// Authorization: Bearer header.
+func isSessionToken(tok string) bool {
+	return strings.Count(tok, ".") == 2
+}
+
+// UserAuth resolves Authorization: Bearer <session token> into a *User stored in the gin context
+// (see CurrentUser). Like APIKeyAuth it never rejects a request itself; routes that need a user
+// use RequireUser. Sessions are checked against user_sessions (revoked and expired sessions don't
+// resolve) and the lookups cached for sessionCacheTTL.
+func UserAuth(pool *pgxpool.Pool) gin.HandlerFunc {
+	lookup := func(cl *sessionClaims) *User {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		u := &User{SessionID: cl.SessionID}
+		var name *string
+		err := pool.QueryRow(ctx, `select u.id,u.display_name,array(select r.role||coalesce(':'||r.place_id,'') from user_roles r where r.user_id=u.id order by 1)
+			from user_sessions s join users u on u.id=s.user_id
+			where s.id=$1 and s.user_id=$2 and s.revoked_at is null and s.expires_at > now()`, cl.SessionID, cl.UserID).Scan(&u.ID, &name, &u.Roles)
+		if err != nil {
+			return nil
+		}
+		if name != nil {
+			u.DisplayName = *name
+		}
+		return u
+	}
+
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		if pool == nil || !strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+			c.Next()
+			return
+		}
+		tok := strings.TrimSpace(auth[7:])
+		if !isSessionToken(tok) {
+			c.Next()
+			return
+		}
+		cl, err := parseSessionToken(tok)
+		if err != nil {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		sessionCache.mu.Lock()
+		ent, ok := sessionCache.items[cl.SessionID]
+		sessionCache.mu.Unlock()
+		if !ok || now.Sub(ent.loadedAt) > sessionCacheTTL {
+			ent = &sessionCacheEntry{user: lookup(cl), loadedAt: now}
+			sessionCache.mu.Lock()
+			sessionCache.items[cl.SessionID] = ent
+			if now.Sub(sessionCache.sweptAt) > sessionCacheTTL {
+				for id, e := range sessionCache.items {
+					if now.Sub(e.loadedAt) > sessionCacheTTL {
+						delete(sessionCache.items, id)
+					}
+				}
+				sessionCache.sweptAt = now
+			}
+			sessionCache.mu.Unlock()
+		}
+		if u := ent.user; u != nil {
+			c.Set(userContextKey, u)
+			// last_used_at is informational; write it at most once a minute per session
+			sessionCache.mu.Lock()
+			touch := now.Sub(ent.usedAt) > time.Minute
+			if touch {
+				ent.usedAt = now
+			}
+			sessionCache.mu.Unlock()
+			if touch {
+				go func(id string) {
+					ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+					defer cancel()
+					_, _ = pool.Exec(ctx, `update user_sessions set last_used_at=now() where id=$1`, id)
+				}(u.SessionID)
+			}
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user UserAuth resolved for the request, or nil.
+func CurrentUser(c *gin.Context) *User {
+	if v, ok := c.Get(userContextKey); ok {
+		if u, ok := v.(*User); ok {
+			return u
+		}
+	}
+	return nil
+}
+
+// RequireUser rejects requests without a valid session token (401).
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentUser(c) == nil {
+			c.Error(errors.New("unauthorized: session required")) //nolint:errcheck
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "reason": "session required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
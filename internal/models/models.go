@@ -16,6 +16,7 @@ type VolunteerOrganization struct {
 	MeetingInfo        string     `json:"meeting_info"`
 	Notes              string     `json:"notes"`
 	ImageURL           *string    `json:"image_url"`
+	DeletedAt          *int64     `json:"deleted_at,omitempty"`
 }
 
 // Shelter represents shelters table row
@@ -36,9 +37,11 @@ type Shelter struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	OpeningHours *string `json:"opening_hours"`
-	CreatedAt    int64   `json:"created_at"`
-	UpdatedAt    int64   `json:"updated_at"`
+	OpeningHours *string  `json:"opening_hours"`
+	CreatedAt    int64    `json:"created_at"`
+	UpdatedAt    int64    `json:"updated_at"`
+	DeletedAt    *int64   `json:"deleted_at,omitempty"`
+	DistanceM    *float64 `json:"distance_m,omitempty"`
 }
 
 // MedicalStation represents medical_stations table row
@@ -60,11 +63,13 @@ type MedicalStation struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	AffiliatedOrganization *string `json:"affiliated_organization"`
-	Notes                  *string `json:"notes"`
-	Link                   *string `json:"link"`
-	CreatedAt              int64   `json:"created_at"`
-	UpdatedAt              int64   `json:"updated_at"`
+	AffiliatedOrganization *string  `json:"affiliated_organization"`
+	Notes                  *string  `json:"notes"`
+	Link                   *string  `json:"link"`
+	CreatedAt              int64    `json:"created_at"`
+	UpdatedAt              int64    `json:"updated_at"`
+	DeletedAt              *int64   `json:"deleted_at,omitempty"`
+	DistanceM              *float64 `json:"distance_m,omitempty"`
 }
 
 // MentalHealthResource represents mental_health_resources table row
@@ -85,13 +90,15 @@ type MentalHealthResource struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	Status           string  `json:"status"`
-	Capacity         *int    `json:"capacity"`
-	WaitingTime      *string `json:"waiting_time"`
-	Notes            *string `json:"notes"`
-	EmergencySupport bool    `json:"emergency_support"`
-	CreatedAt        int64   `json:"created_at"`
-	UpdatedAt        int64   `json:"updated_at"`
+	Status           string   `json:"status"`
+	Capacity         *int     `json:"capacity"`
+	WaitingTime      *string  `json:"waiting_time"`
+	Notes            *string  `json:"notes"`
+	EmergencySupport bool     `json:"emergency_support"`
+	CreatedAt        int64    `json:"created_at"`
+	UpdatedAt        int64    `json:"updated_at"`
+	DeletedAt        *int64   `json:"deleted_at,omitempty"`
+	DistanceM        *float64 `json:"distance_m,omitempty"`
 }
 
 // Accommodation represents accommodations table row
@@ -117,8 +124,10 @@ type Accommodation struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+	DeletedAt *int64   `json:"deleted_at,omitempty"`
+	DistanceM *float64 `json:"distance_m,omitempty"`
 }
 
 // ShowerStation represents shower_stations table row
@@ -148,8 +157,10 @@ type ShowerStation struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+	DeletedAt *int64   `json:"deleted_at,omitempty"`
+	DistanceM *float64 `json:"distance_m,omitempty"`
 }
 
 // WaterRefillStation represents water_refill_stations table row
@@ -174,8 +185,10 @@ type WaterRefillStation struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+	DeletedAt *int64   `json:"deleted_at,omitempty"`
+	DistanceM *float64 `json:"distance_m,omitempty"`
 }
 
 // Restroom represents restrooms table row
@@ -204,8 +217,10 @@ type Restroom struct {
 		Lat *float64 `json:"lat"`
 		Lng *float64 `json:"lng"`
 	} `json:"coordinates"`
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+	DeletedAt *int64   `json:"deleted_at,omitempty"`
+	DistanceM *float64 `json:"distance_m,omitempty"`
 }
 
 // HumanResource represents human_resources view/aggregation row
@@ -248,6 +263,7 @@ type HumanResource struct {
 	PendingRoles            *int     `json:"pending_roles"`
 	UrgentRequests          *int     `json:"urgent_requests"`
 	MedicalRequests         *int     `json:"medical_requests"`
+	DeletedAt               *int64   `json:"deleted_at,omitempty"`
 }
 
 // Supply represents supplies table row
@@ -260,6 +276,7 @@ type Supply struct {
 	PiiDate   *int64  `json:"pii_date"`
 	CreatedAt int64   `json:"created_at"`
 	UpdatedAt int64   `json:"updated_at"`
+	DeletedAt *int64  `json:"deleted_at,omitempty"`
 }
 
 // SupplyItem represents supply_items table row (corrected naming)
@@ -271,20 +288,51 @@ type SupplyItem struct {
 	ReceivedCount int     `json:"recieved_count"`
 	TotalCount    int     `json:"total_count"`
 	Unit          *string `json:"unit"`
+	// PledgedCount is promised by supply_providers still pledged, confirmed or in transit;
+	// OutstandingCount is what is left to ask for: total - received - pledged, at least 0
+	PledgedCount     int `json:"pledged_count"`
+	OutstandingCount int `json:"outstanding_count"`
+	// NormalizedUnit is the canonical unit of Unit (see GET /units) and the normalized counts are in
+	// it; all null when the unit isn't in the catalog, which UnitReview flags
+	NormalizedUnit          *string  `json:"normalized_unit"`
+	NormalizedTotalCount    *float64 `json:"normalized_total_count"`
+	NormalizedReceivedCount *float64 `json:"normalized_received_count"`
+	UnitReview              bool     `json:"unit_review"`
+	UnitFactor              *float64 `json:"-"` // 1 Unit = UnitFactor NormalizedUnit
+	// CategoryID and CatalogItemID map Tag and Name to the item catalog; null when unmapped
+	CategoryID    *string `json:"category_id"`
+	CatalogItemID *string `json:"catalog_item_id"`
+	DeletedAt     *int64  `json:"deleted_at,omitempty"`
 }
 
 // SupplyProvider represents supply_providers table row
 type SupplyProvider struct {
-	ID            string `json:"id"`
-	Name          string `json:"name"`
-	Phone         string `json:"phone"`
-	SupplyItemID  string `json:"supply_item_id"`
-	Address       string `json:"address"`
-	Notes         *string `json:"notes"`
-	ProvideCount  int     `json:"provide_count"`
-	ProvideUnit   *string `json:"provide_unit"`
-	CreatedAt     int64  `json:"created_at"`
-	UpdatedAt     int64  `json:"updated_at"`
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Phone        string `json:"phone"`
+	SupplyItemID string `json:"supply_item_id"`
+	Address      string `json:"address"`
+	Coordinates  *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"`
+	Notes        *string `json:"notes"`
+	ProvideCount int     `json:"provide_count"`
+	ProvideUnit  *string `json:"provide_unit"`
+	// AllocatedCount is what of provide_count was allocated to places' needs (see allocations)
+	AllocatedCount int `json:"allocated_count"`
+	// NormalizedUnit is the canonical unit of the pledge's unit (provide_unit, else the item's)
+	NormalizedUnit         *string  `json:"normalized_unit"`
+	NormalizedProvideCount *float64 `json:"normalized_provide_count"`
+	UnitReview             bool     `json:"unit_review"`
+	UnitFactor             *float64 `json:"-"`
+	// Status is the pledge's progress: pledged, confirmed, in_transit, delivered or cancelled
+	Status          string  `json:"status"`
+	StatusChangedAt *int64  `json:"status_changed_at"`
+	DeliveryID      *string `json:"delivery_id"` // supply_deliveries entry the delivered pledge was booked as
+	CreatedAt       int64   `json:"created_at"`
+	UpdatedAt       int64   `json:"updated_at"`
+	DeletedAt       *int64  `json:"deleted_at,omitempty"`
 }
 
 // Report represents reports table row
@@ -298,6 +346,7 @@ type Report struct {
 	LocationID   string  `json:"location_id"`
 	CreatedAt    int64   `json:"created_at"`
 	UpdatedAt    int64   `json:"updated_at"`
+	DeletedAt    *int64  `json:"deleted_at,omitempty"`
 }
 
 // SpamResult represents spam_result table row
@@ -309,61 +358,191 @@ type SpamResult struct {
 	IsSpam      bool                   `json:"is_spam"`
 	Judgment    string                 `json:"judgment"`
 	ValidatedAt int64                  `json:"validated_at"`
+	DeletedAt   *int64                 `json:"deleted_at,omitempty"`
 }
 
 // Place represents places table row
 type Place struct {
-	ID                string                   `json:"id"`
-	Name              string                   `json:"name"`
-	Address           string                   `json:"address"`
-	AddressDescription *string                 `json:"address_description"`
-	Coordinates       *struct{ Lat *float64 `json:"lat"`; Lng *float64 `json:"lng"` } `json:"coordinates"`
-	Type              string                   `json:"type"`
-	SubType           *string                  `json:"sub_type"`
-	InfoSources       []string                 `json:"info_sources"`
-	VerifiedAt        *int64                   `json:"verified_at"`
-	WebsiteURL        *string                  `json:"website_url"`
-	Status            string                   `json:"status"`
-	Resources         []map[string]interface{} `json:"resources"`
-	OpenDate          *string                  `json:"open_date"`
-	EndDate           *string                  `json:"end_date"`
-	OpenTime          *string                  `json:"open_time"`
-	EndTime           *string                  `json:"end_time"`
-	ContactName       string                   `json:"contact_name"`
-	ContactPhone      string                   `json:"contact_phone"`
-	Notes             *string                  `json:"notes"`
-	Tags              []map[string]interface{} `json:"tags"`
-	AdditionalInfo    map[string]interface{}   `json:"additional_info"`
-	CreatedAt         int64                    `json:"created_at"`
-	UpdatedAt         int64                    `json:"updated_at"`
+	ID                 string  `json:"id"`
+	Name               string  `json:"name"`
+	Address            string  `json:"address"`
+	AddressDescription *string `json:"address_description"`
+	Coordinates        *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"`
+	Type           string                   `json:"type"`
+	SubType        *string                  `json:"sub_type"`
+	InfoSources    []string                 `json:"info_sources"`
+	VerifiedAt     *int64                   `json:"verified_at"`
+	WebsiteURL     *string                  `json:"website_url"`
+	Status         string                   `json:"status"`
+	Resources      []map[string]interface{} `json:"resources"`
+	OpenDate       *string                  `json:"open_date"`
+	EndDate        *string                  `json:"end_date"`
+	OpenTime       *string                  `json:"open_time"`
+	EndTime        *string                  `json:"end_time"`
+	ContactName    string                   `json:"contact_name"`
+	ContactPhone   string                   `json:"contact_phone"`
+	Notes          *string                  `json:"notes"`
+	Tags           []map[string]interface{} `json:"tags"`
+	AdditionalInfo map[string]interface{}   `json:"additional_info"`
+	CreatedAt      int64                    `json:"created_at"`
+	UpdatedAt      int64                    `json:"updated_at"`
+	DeletedAt      *int64                   `json:"deleted_at,omitempty"`
+	DistanceM      *float64                 `json:"distance_m,omitempty"`
 }
 
 // RequirementsHR represents requirements_hr table row
 type RequirementsHR struct {
-	ID            string                   `json:"id"`
-	PlaceID       string                   `json:"place_id"`
-	RequiredType  string                   `json:"required_type"`
-	Name          string                   `json:"name"`
-	Unit          string                   `json:"unit"`
-	RequireCount  int                      `json:"require_count"`
-	ReceivedCount int                      `json:"received_count"`
-	Tags          []map[string]interface{} `json:"tags"`
-	AdditionalInfo map[string]interface{}  `json:"additional_info"`
-	CreatedAt     int64                    `json:"created_at"`
-	UpdatedAt     int64                    `json:"updated_at"`
+	ID             string                   `json:"id"`
+	PlaceID        string                   `json:"place_id"`
+	RequiredType   string                   `json:"required_type"`
+	Name           string                   `json:"name"`
+	Unit           string                   `json:"unit"`
+	RequireCount   int                      `json:"require_count"`
+	ReceivedCount  int                      `json:"received_count"`
+	Tags           []map[string]interface{} `json:"tags"`
+	AdditionalInfo map[string]interface{}   `json:"additional_info"`
+	CreatedAt      int64                    `json:"created_at"`
+	UpdatedAt      int64                    `json:"updated_at"`
+	DeletedAt      *int64                   `json:"deleted_at,omitempty"`
 }
 
 // RequirementsSupplies represents requirements_supplies table row
 type RequirementsSupplies struct {
-	ID            string                   `json:"id"`
-	PlaceID       string                   `json:"place_id"`
-	RequiredType  string                   `json:"required_type"`
-	Name          string                   `json:"name"`
-	Unit          string                   `json:"unit"`
-	RequireCount  int                      `json:"require_count"`
-	ReceivedCount int                      `json:"received_count"`
-	Tags          []map[string]interface{} `json:"tags"`
-	AdditionalInfo map[string]interface{}  `json:"additional_info"`
-	CreatedAt     int64                    `json:"created_at"`
-	UpdatedAt     int64                    `json:"updated_at"`
+	ID             string                   `json:"id"`
+	PlaceID        string                   `json:"place_id"`
+	RequiredType   string                   `json:"required_type"`
+	Name           string                   `json:"name"`
+	Unit           string                   `json:"unit"`
+	RequireCount   int                      `json:"require_count"`
+	ReceivedCount  int                      `json:"received_count"`
+	Tags           []map[string]interface{} `json:"tags"`
+	AdditionalInfo map[string]interface{}   `json:"additional_info"`
+	// NormalizedUnit is the canonical unit of Unit; null when it isn't in the catalog (UnitReview)
+	NormalizedUnit          *string  `json:"normalized_unit"`
+	NormalizedRequireCount  *float64 `json:"normalized_require_count"`
+	NormalizedReceivedCount *float64 `json:"normalized_received_count"`
+	UnitReview              bool     `json:"unit_review"`
+	UnitFactor              *float64 `json:"-"`
+	// CategoryID and CatalogItemID map RequiredType and Name to the item catalog; null when unmapped
+	CategoryID    *string `json:"category_id"`
+	CatalogItemID *string `json:"catalog_item_id"`
+	CreatedAt     int64   `json:"created_at"`
+	UpdatedAt     int64   `json:"updated_at"`
+	DeletedAt     *int64  `json:"deleted_at,omitempty"`
+}
+
+// MapFeature is the normalized, kind-agnostic shape returned by /map/features.
+type MapFeature struct {
+	ID          string  `json:"id"`
+	Kind        string  `json:"kind"`
+	Name        string  `json:"name"`
+	Address     *string `json:"address"`
+	Status      *string `json:"status"`
+	Coordinates *struct {
+		Lat *float64 `json:"lat"`
+		Lng *float64 `json:"lng"`
+	} `json:"coordinates"`
+	UpdatedAt int64  `json:"updated_at"`
+	Link      string `json:"link"`
+}
+
+// APIKey is a partner API key as listed by /_admin/api_keys. Key holds the plain key and is only
+// set in the response that issued or rotated it.
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	KeyPrefix  string   `json:"key_prefix"`
+	Key        *string  `json:"key,omitempty"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *int64   `json:"expires_at"`
+	Revoked    bool     `json:"revoked"`
+	RevokedAt  *int64   `json:"revoked_at"`
+	LastUsedAt *int64   `json:"last_used_at"`
+	CreatedAt  int64    `json:"created_at"`
+	UpdatedAt  int64    `json:"updated_at"`
+}
+
+// IPDenyEntry is a row of ip_denylist: a single IP or CIDR whose writes IPFilter refuses.
+type IPDenyEntry struct {
+	ID        string  `json:"id"`
+	Pattern   string  `json:"pattern"`
+	Reason    *string `json:"reason"`
+	CreatedBy *string `json:"created_by"`
+	ExpiresAt *int64  `json:"expires_at"`
+	Active    bool    `json:"active"`
+	CreatedAt int64   `json:"created_at"`
+	UpdatedAt int64   `json:"updated_at"`
+}
+
+// User is someone who signed in with LINE Login.
+type User struct {
+	ID          string     `json:"id"`
+	LineSub     string     `json:"line_sub"`
+	DisplayName *string    `json:"display_name"`
+	PictureURL  *string    `json:"picture_url"`
+	Email       *string    `json:"email"`
+	LastLoginAt *int64     `json:"last_login_at"`
+	CreatedAt   int64      `json:"created_at"`
+	UpdatedAt   int64      `json:"updated_at"`
+	Roles       []UserRole `json:"roles,omitempty"`
+}
+
+// UserRole is a role assigned to a user (see internal/policy); PlaceID scopes a site coordinator.
+type UserRole struct {
+	ID        string  `json:"id"`
+	Role      string  `json:"role"`
+	PlaceID   *string `json:"place_id"`
+	CreatedBy *string `json:"created_by"`
+	CreatedAt int64   `json:"created_at"`
+}
+
+// Unit is a row of the unit catalog: a spelling the API knows and how it converts to the base unit
+// of its dimension (1 unit = Factor BaseUnit). Aliases are other spellings of it ("kg" for 公斤).
+type Unit struct {
+	Unit      string   `json:"unit"`
+	BaseUnit  string   `json:"base_unit"`
+	Factor    float64  `json:"factor"`
+	Aliases   []string `json:"aliases"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+// UnitConversion converts a packaging unit for one item (a supply item's name or tag, or a
+// requirement's name or required_type, lower-cased): 1 FromUnit = Factor ToUnit.
+type UnitConversion struct {
+	Item      string  `json:"item"`
+	FromUnit  string  `json:"from_unit"`
+	ToUnit    string  `json:"to_unit"`
+	Factor    float64 `json:"factor"`
+	CreatedAt int64   `json:"created_at"`
+	UpdatedAt int64   `json:"updated_at"`
+}
+
+// CatalogCategory is a category of the item catalog, what supply_items.tag and
+// requirements_supplies.required_type are mapped to (category_id).
+type CatalogCategory struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	NameEn    *string  `json:"name_en"`
+	Aliases   []string `json:"aliases"`
+	SortOrder int      `json:"sort_order"`
+	ItemCount int      `json:"item_count"` // catalog items in the category
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+// CatalogItem is a canonical item name of the catalog, what supply item and requirement names are
+// mapped to (catalog_item_id).
+type CatalogItem struct {
+	ID          string   `json:"id"`
+	CategoryID  string   `json:"category_id"`
+	Name        string   `json:"name"`
+	NameEn      *string  `json:"name_en"`
+	Aliases     []string `json:"aliases"`
+	DefaultUnit *string  `json:"default_unit"`
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
 }
@@ -0,0 +1,134 @@
+// Package pin hashes the 6-digit edit PINs (valid_pin) of supplies and human_resources. A PIN has
+// only ~531k values, so the hash is deliberately slow (PBKDF2-HMAC-SHA256) and salted per row;
+// the real protection is the attempt lockout in the handlers.
+package pin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	prefix     = "pbkdf2-sha256$"
+	iterations = 100000
+	saltLen    = 16
+	keyLen     = 32
+	// legacyBatch is how many plaintext PINs HashLegacy reads per query.
+	legacyBatch = 100
+)
+
+// Hash returns the stored form of a PIN: pbkdf2-sha256$<iterations>$<salt>$<hash>.
+func Hash(p string) (string, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return prefix + strconv.Itoa(iterations) + "$" + enc.EncodeToString(salt) + "$" + enc.EncodeToString(pbkdf2.Key([]byte(p), salt, iterations, keyLen, sha256.New)), nil
+}
+
+// IsHashed reports whether stored is a hash rather than a plaintext PIN from before hashing.
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, prefix)
+}
+
+// Verify reports whether p matches stored. Plaintext PINs not yet hashed by HashLegacy still
+// verify.
+func Verify(stored, p string) bool {
+	if !IsHashed(stored) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(p)) == 1
+	}
+	parts := strings.Split(strings.TrimPrefix(stored, prefix), "$")
+	if len(parts) != 3 {
+		return false
+	}
+	iter, err := strconv.Atoi(parts[0])
+	if err != nil || iter <= 0 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err1 := enc.DecodeString(parts[1])
+	want, err2 := enc.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || len(want) != keyLen {
+		return false
+	}
+	return hmac.Equal(pbkdf2.Key([]byte(p), salt, iter, keyLen, sha256.New), want)
+}
+
+// HashLegacy replaces the plaintext PINs still stored in supplies and human_resources with
+// hashes, legacyBatch rows at a time. It is safe to run on every start and from several instances
+// at once; when ctx ends it stops after the current row.
+func HashLegacy(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	if pool == nil {
+		return 0, errors.New("no database")
+	}
+	n := 0
+	for _, table := range []string{"supplies", "human_resources"} {
+		after := ""
+		for {
+			rows, err := pool.Query(ctx, `select id::text,valid_pin from `+table+` where valid_pin is not null and valid_pin <> '' and valid_pin not like 'pbkdf2-sha256$%'
+				and id::text > $1 order by id::text limit $2`, after, legacyBatch)
+			if err != nil {
+				return n, err
+			}
+			type legacy struct{ id, pin string }
+			var list []legacy
+			for rows.Next() {
+				var l legacy
+				if err := rows.Scan(&l.id, &l.pin); err != nil {
+					rows.Close()
+					return n, err
+				}
+				list = append(list, l)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return n, err
+			}
+			for _, l := range list {
+				if err := ctx.Err(); err != nil {
+					return n, err
+				}
+				h, err := Hash(l.pin)
+				if err != nil {
+					return n, err
+				}
+				// only if still the same plaintext, so a concurrent reset isn't overwritten
+				tag, err := pool.Exec(ctx, `update `+table+` set valid_pin=$2 where id::text=$1 and valid_pin=$3`, l.id, h, l.pin)
+				if err != nil {
+					return n, err
+				}
+				n += int(tag.RowsAffected())
+				after = l.id
+			}
+			if len(list) < legacyBatch {
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// StartHashLegacy runs HashLegacy in the background, so hashing many old PINs (each deliberately
+// slow) doesn't hold up startup. Plaintext PINs keep verifying until they are hashed.
+func StartHashLegacy(ctx context.Context, pool *pgxpool.Pool) {
+	go func() {
+		n, err := HashLegacy(ctx, pool)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("hashing legacy PINs failed after %d: %v", n, err)
+		} else if n > 0 {
+			log.Printf("hashed %d legacy PINs", n)
+		}
+	}()
+}
@@ -0,0 +1,205 @@
+// Package policy decides which callers may perform which writes. Routes declare a permission
+// ("<resource>:<action>" or "admin:<area>"); callers hold roles, from their API key's scopes or
+// from user_roles when signed in. A rule may limit a role to some fields of the request body, and
+// site coordinators only act on the places they were assigned.
+package policy
+
+import (
+	"sort"
+	"strings"
+)
+
+// Role is what a caller may do. Anyone and Owner are not assigned: every caller is Anyone, and
+// Owner rules are left to the handler, which knows who owns the row (owner, co-owner or PIN).
+type Role string
+
+const (
+	Viewer          Role = "viewer"           // audit reads: soft-deleted rows, full actor IPs
+	SiteCoordinator Role = "site_coordinator" // operational fields of the assigned places
+	Moderator       Role = "moderator"        // any edit, delete / revert, spam and abuse handling
+	PartnerSync     Role = "partner_sync"     // create and update any resource (data sync)
+	Admin           Role = "admin"            // everything
+
+	Anyone Role = "anyone"
+	Owner  Role = "owner"
+)
+
+// Roles lists the roles that can be assigned to users and API keys.
+func Roles() []Role {
+	return []Role{Viewer, SiteCoordinator, Moderator, PartnerSync, Admin}
+}
+
+// Valid reports whether r can be assigned.
+func (r Role) Valid() bool {
+	for _, v := range Roles() {
+		if r == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Grant is a role held by a caller. PlaceID scopes a site coordinator to one place.
+type Grant struct {
+	Role    Role
+	PlaceID string
+}
+
+// ParseGrant reads the "<role>" / "site_coordinator:<place id>" form grants are stored in.
+func ParseGrant(s string) (Grant, bool) {
+	role, place, _ := strings.Cut(s, ":")
+	g := Grant{Role: Role(role), PlaceID: place}
+	if !g.Role.Valid() || (g.Role == SiteCoordinator) != (place != "") {
+		return Grant{}, false
+	}
+	return g, true
+}
+
+func (g Grant) String() string {
+	if g.PlaceID != "" {
+		return string(g.Role) + ":" + g.PlaceID
+	}
+	return string(g.Role)
+}
+
+// Principal is everything a request is allowed by: role grants plus the scopes of its API key
+// ("*", "admin", "<resource>:write"), which keep working as before.
+type Principal struct {
+	Grants []Grant
+	Scopes []string
+}
+
+func (p Principal) has(role Role, placeID string) bool {
+	for _, g := range p.Grants {
+		if g.Role == role && (g.PlaceID == "" || g.PlaceID == placeID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Principal) hasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == "*" || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Coordinates reports whether p is a site coordinator of any place, i.e. whether a place scope
+// has to be resolved before checking.
+func (p Principal) Coordinates() bool {
+	for _, g := range p.Grants {
+		if g.Role == SiteCoordinator {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule lets Role perform a permission. Fields limits it to those body fields, Except to all but
+// those; with neither the role may send any field.
+type Rule struct {
+	Role   Role     `json:"role"`
+	Fields []string `json:"fields,omitempty"`
+	Except []string `json:"except,omitempty"`
+}
+
+func (r Rule) allows(field string) bool {
+	if r.Fields != nil {
+		return contains(r.Fields, field)
+	}
+	return !contains(r.Except, field)
+}
+
+func (r Rule) unrestricted() bool { return r.Fields == nil && r.Except == nil }
+
+// Decision is the outcome of Check.
+type Decision struct {
+	Allowed bool     // the caller may perform the request as sent
+	Owner   bool     // otherwise, the row's owner may; the handler has to check
+	Denied  []string // fields the caller's roles don't cover
+}
+
+// Policy maps permissions onto the rules that grant them.
+type Policy struct {
+	rules map[string][]Rule
+}
+
+// Check decides whether p may perform perm, sending fields (nil for requests without a body).
+// placeID is the place the target belongs to, for site coordinators; "" when it has none.
+func (pol *Policy) Check(p Principal, perm, placeID string, fields []string) Decision {
+	if p.has(Admin, "") || p.hasScope(legacyScope(perm)) {
+		return Decision{Allowed: true}
+	}
+	var d Decision
+	var held []Rule
+	for _, r := range pol.rules[perm] {
+		switch {
+		case r.Role == Owner:
+			d.Owner = true
+		case r.Role == Anyone || p.has(r.Role, placeID):
+			if r.unrestricted() {
+				return Decision{Allowed: true}
+			}
+			held = append(held, r)
+		}
+	}
+	for _, f := range fields {
+		ok := false
+		for _, r := range held {
+			if r.allows(f) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			d.Denied = append(d.Denied, f)
+		}
+	}
+	// field-limited rules cover requests that send at least one field, all of them allowed
+	d.Allowed = len(held) > 0 && len(fields) > 0 && len(d.Denied) == 0
+	if len(held) == 0 {
+		d.Denied = nil
+	}
+	return d
+}
+
+// Describe returns every permission with its rules, for GET /_admin/policy.
+func (pol *Policy) Describe() map[string][]Rule {
+	out := make(map[string][]Rule, len(pol.rules))
+	for perm, rules := range pol.rules {
+		out[perm] = append([]Rule{}, rules...)
+	}
+	return out
+}
+
+// Permissions lists every permission the policy knows, sorted.
+func (pol *Policy) Permissions() []string {
+	list := make([]string, 0, len(pol.rules))
+	for perm := range pol.rules {
+		list = append(list, perm)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// legacyScope is the API key scope that grants perm outright: "<resource>:write" for every action
+// on a resource, "admin" for the admin endpoints.
+func legacyScope(perm string) string {
+	res, _, _ := strings.Cut(perm, ":")
+	if res == "admin" {
+		return "admin"
+	}
+	return res + ":write"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
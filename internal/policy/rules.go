@@ -0,0 +1,74 @@
+package policy
+
+// Options are the deployment switches the default rules depend on.
+type Options struct {
+	// OpenSupplyProviders keeps PATCH /supply_providers/:id open to anyone, as it is while
+	// VERIFY_SUPPLY_PIN is off.
+	OpenSupplyProviders bool
+}
+
+// ownedResources are edited by the signed-in owner, a co-owner or the PIN holder (see
+// handlers.authorizeEdit) besides the roles below.
+var ownedResources = []string{"supplies", "supply_items", "supply_providers", "human_resources", "places"}
+
+// placeFields are the operational fields of a place its site coordinators keep up to date; what
+// and where the place is (name, address, coordinates, type) stays with moderators and partners.
+var placeFields = []string{"status", "resources", "open_date", "end_date", "open_time", "end_time",
+	"contact_name", "contact_phone", "notes", "tags", "additional_info"}
+
+// Default builds the rules for resources (the writable resource path names):
+//
+//	<resource>:update  partner_sync, moderator; the owner where rows have one
+//	<resource>:delete  moderator
+//	<resource>:revert  moderator (revert and restore)
+//	<resource>:audit   viewer, moderator (soft-deleted rows, full actor IPs in history)
+//	allocations:create, allocations:update  partner_sync, moderator, the place's site coordinators
+//	admin:<area>       admin; moderators also read request logs and manage the IP denylist
+//	                   and the unit and item catalogs
+//
+// An API key with "<resource>:write" still grants every action on the resource and "admin" every
+// admin area; the admin role and the "*" scope grant everything.
+func Default(resources []string, opts Options) *Policy {
+	pol := &Policy{rules: map[string][]Rule{}}
+	add := func(perm string, rules ...Rule) {
+		pol.rules[perm] = append(pol.rules[perm], rules...)
+	}
+	for _, res := range resources {
+		add(res+":update", Rule{Role: PartnerSync}, Rule{Role: Moderator})
+		add(res+":delete", Rule{Role: Moderator})
+		add(res+":revert", Rule{Role: Moderator})
+		add(res+":audit", Rule{Role: Viewer}, Rule{Role: Moderator})
+	}
+	for _, res := range ownedResources {
+		add(res+":update", Rule{Role: Owner})
+	}
+
+	// 2025-10-06: volunteers on site confirm arrivals without any credential
+	add("human_resources:update", Rule{Role: Anyone, Fields: []string{"status", "is_completed", "headcount_got"}})
+	if opts.OpenSupplyProviders {
+		add("supply_providers:update", Rule{Role: Anyone})
+	}
+	add("reports:update", Rule{Role: Anyone})
+	add("restrooms:update", Rule{Role: Anyone})
+	add("spam_results:create", Rule{Role: PartnerSync}, Rule{Role: Moderator})
+
+	// Site coordinators, scoped to their places
+	add("places:update", Rule{Role: SiteCoordinator, Fields: placeFields})
+	for _, res := range []string{"requirements_hr", "requirements_supplies"} {
+		add(res+":update", Rule{Role: SiteCoordinator, Except: []string{"place_id"}})
+		add(res+":delete", Rule{Role: SiteCoordinator})
+	}
+	// matching pledges with a place's needs (POST /allocations) and tracking them (PATCH)
+	for _, perm := range []string{"allocations:create", "allocations:update"} {
+		add(perm, Rule{Role: PartnerSync}, Rule{Role: Moderator}, Rule{Role: SiteCoordinator})
+	}
+
+	add("admin:request_logs", Rule{Role: Moderator})
+	add("admin:ip_denylist", Rule{Role: Moderator})
+	add("admin:units", Rule{Role: Moderator})
+	add("admin:catalog", Rule{Role: Moderator})
+	add("admin:api_keys")
+	add("admin:users")
+	add("admin:captcha_metrics")
+	return pol
+}
@@ -0,0 +1,70 @@
+package turnstile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// maxCachedResults bounds the cache; when full, expired entries are dropped and if that isn't
+// enough the cache starts over.
+const maxCachedResults = 10000
+
+type cachedResult struct {
+	success bool
+	expires time.Time
+}
+
+type CachedVerifier struct {
+	inner   TokenVerifier
+	ttl     time.Duration
+	metrics *Metrics
+	mu      sync.Mutex
+	results map[string]cachedResult
+}
+
+// NewCachedVerifier remembers verification results for ttl. Captcha tokens are single use, so
+// without it a client retrying a request (after a timeout or a 5xx) would fail verification the
+// second time. Results are keyed by token, client IP and VerifyOptions.Request, so only a retry of
+// the same request is answered from the cache and a solved captcha still covers a single
+// request; errors are not cached. Cache hits are counted in metrics when it is not nil.
+func NewCachedVerifier(inner TokenVerifier, ttl time.Duration, metrics *Metrics) TokenVerifier {
+	return &CachedVerifier{inner: inner, ttl: ttl, metrics: metrics, results: map[string]cachedResult{}}
+}
+
+func (v *CachedVerifier) Verify(opt VerifyOptions) (bool, error) {
+	sum := sha256.Sum256([]byte(opt.RemoteIP + "\x00" + opt.Token + "\x00" + opt.Request))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	v.mu.Lock()
+	if r, ok := v.results[key]; ok && now.Before(r.expires) {
+		v.mu.Unlock()
+		if v.metrics != nil {
+			v.metrics.CacheHits.Add(1)
+		}
+		return r.success, nil
+	}
+	v.mu.Unlock()
+
+	success, err := v.inner.Verify(opt)
+	if err != nil {
+		return false, err
+	}
+
+	v.mu.Lock()
+	if len(v.results) >= maxCachedResults {
+		for k, r := range v.results {
+			if now.After(r.expires) {
+				delete(v.results, k)
+			}
+		}
+		if len(v.results) >= maxCachedResults {
+			v.results = map[string]cachedResult{}
+		}
+	}
+	v.results[key] = cachedResult{success: success, expires: now.Add(v.ttl)}
+	v.mu.Unlock()
+	return success, nil
+}
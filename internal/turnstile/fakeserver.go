@@ -0,0 +1,93 @@
+package turnstile
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewFakeServer returns a stand-in for the siteverify endpoints of all three providers, for local
+// development and for exercising the verifiers without real keys:
+//
+//	POST /turnstile/v0/siteverify   Turnstile (JSON body)
+//	POST /siteverify                hCaptcha (form body)
+//	POST /recaptcha/api/siteverify  reCAPTCHA v3 (form body)
+//
+// Any secret is accepted. Tokens starting with "pass" succeed and every other token fails. A
+// reCAPTCHA token may carry ";score=0.3" and ";action=submit" to set the score (default 0.9) and
+// action. Like the real services each token can be verified once; a second verification fails
+// with "timeout-or-duplicate".
+func NewFakeServer() http.Handler {
+	var (
+		mu   sync.Mutex
+		used = map[string]bool{}
+	)
+	// check returns the error code for token, or "" when it passes.
+	check := func(token string) string {
+		if token == "" {
+			return "missing-input-response"
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if used[token] {
+			return "timeout-or-duplicate"
+		}
+		used[token] = true
+		if !strings.HasPrefix(token, "pass") {
+			return "invalid-input-response"
+		}
+		return ""
+	}
+	reply := func(w http.ResponseWriter, code string, extra map[string]interface{}) {
+		out := map[string]interface{}{
+			"success":      code == "",
+			"challenge_ts": time.Now().UTC().Format(time.RFC3339),
+			"hostname":     "localhost",
+		}
+		if code != "" {
+			out["error-codes"] = []string{code}
+		}
+		for k, v := range extra {
+			out[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /turnstile/v0/siteverify", func(w http.ResponseWriter, r *http.Request) {
+		var in verifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			reply(w, "bad-request", nil)
+			return
+		}
+		reply(w, check(in.Response), nil)
+	})
+	mux.HandleFunc("POST /siteverify", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, check(r.PostFormValue("response")), nil)
+	})
+	mux.HandleFunc("POST /recaptcha/api/siteverify", func(w http.ResponseWriter, r *http.Request) {
+		token := r.PostFormValue("response")
+		score, action := 0.9, ""
+		for _, part := range strings.Split(token, ";")[1:] {
+			k, v, _ := strings.Cut(part, "=")
+			switch k {
+			case "score":
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					score = f
+				}
+			case "action":
+				action = v
+			}
+		}
+		code := check(token)
+		if code != "" {
+			score = 0
+		}
+		reply(w, code, map[string]interface{}{"score": score, "action": action})
+	})
+	return mux
+}
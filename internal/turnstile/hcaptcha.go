@@ -0,0 +1,74 @@
+package turnstile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultHCaptchaVerifyURL = "https://api.hcaptcha.com/siteverify"
+
+type HCaptchaVerifier struct {
+	apiURL    string
+	secretKey string
+	siteKey   string
+	client    *http.Client
+}
+
+type NewHCaptchaVerifierOptions struct {
+	APIURL    string
+	SecretKey string
+	SiteKey   string // optional; when set hCaptcha also checks the token was issued for it
+}
+
+type hcaptchaResponse struct {
+	Success    bool     `json:"success"`
+	Hostname   string   `json:"hostname,omitempty"`
+	ErrorCodes []string `json:"error-codes,omitempty"`
+}
+
+func NewHCaptchaVerifier(opt NewHCaptchaVerifierOptions) TokenVerifier {
+	if opt.APIURL == "" {
+		opt.APIURL = defaultHCaptchaVerifyURL
+	}
+	return &HCaptchaVerifier{
+		apiURL:    opt.APIURL,
+		secretKey: opt.SecretKey,
+		siteKey:   opt.SiteKey,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *HCaptchaVerifier) Verify(opt VerifyOptions) (bool, error) {
+	if opt.Token == "" {
+		return false, fmt.Errorf("token is empty")
+	}
+	form := url.Values{}
+	form.Set("secret", v.secretKey)
+	form.Set("response", opt.Token)
+	if opt.RemoteIP != "" {
+		form.Set("remoteip", opt.RemoteIP)
+	}
+	if v.siteKey != "" {
+		form.Set("sitekey", v.siteKey)
+	}
+
+	resp, err := v.client.Post(v.apiURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, fmt.Errorf("failed to send verification request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var out hcaptchaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return out.Success, nil
+}
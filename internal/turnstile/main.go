@@ -1,3 +1,7 @@
+// Package turnstile verifies captcha tokens. TokenVerifier has implementations for Cloudflare
+// Turnstile (NewTokenVerifier), hCaptcha (NewHCaptchaVerifier) and reCAPTCHA v3
+// (NewRecaptchaVerifier); NewCachedVerifier and NewCountingVerifier wrap any of them, and
+// NewFakeServer stands in for the providers' siteverify endpoints during development.
 package turnstile
 
 import (
@@ -13,6 +17,9 @@ const defaultTurnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v
 type VerifyOptions struct {
 	Token    string
 	RemoteIP string
+	// Request identifies the request the token came with (e.g. a hash of its method, path and
+	// body). Providers ignore it; NewCachedVerifier only reuses a result for the same request.
+	Request string
 }
 type TokenVerifier interface {
 	Verify(opt VerifyOptions) (bool, error)
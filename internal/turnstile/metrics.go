@@ -0,0 +1,73 @@
+package turnstile
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Metrics counts verification outcomes for one provider.
+type Metrics struct {
+	Passed    atomic.Int64
+	Failed    atomic.Int64
+	Errors    atomic.Int64 // the provider could not be reached or answered garbage
+	CacheHits atomic.Int64 // answered by NewCachedVerifier without asking the provider
+}
+
+// Counts is a point-in-time copy of Metrics.
+type Counts struct {
+	Passed    int64 `json:"passed"`
+	Failed    int64 `json:"failed"`
+	Errors    int64 `json:"errors"`
+	CacheHits int64 `json:"cache_hits"`
+}
+
+var registry = struct {
+	mu      sync.Mutex
+	metrics map[string]*Metrics
+}{metrics: map[string]*Metrics{}}
+
+// MetricsFor returns the process-wide Metrics of a provider, creating them on first use.
+func MetricsFor(provider string) *Metrics {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	m, ok := registry.metrics[provider]
+	if !ok {
+		m = &Metrics{}
+		registry.metrics[provider] = m
+	}
+	return m
+}
+
+// Snapshot returns the current counts per provider.
+func Snapshot() map[string]Counts {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	out := make(map[string]Counts, len(registry.metrics))
+	for name, m := range registry.metrics {
+		out[name] = Counts{Passed: m.Passed.Load(), Failed: m.Failed.Load(), Errors: m.Errors.Load(), CacheHits: m.CacheHits.Load()}
+	}
+	return out
+}
+
+type CountingVerifier struct {
+	inner   TokenVerifier
+	metrics *Metrics
+}
+
+// NewCountingVerifier records the outcome of every verification in metrics.
+func NewCountingVerifier(inner TokenVerifier, metrics *Metrics) TokenVerifier {
+	return &CountingVerifier{inner: inner, metrics: metrics}
+}
+
+func (v *CountingVerifier) Verify(opt VerifyOptions) (bool, error) {
+	success, err := v.inner.Verify(opt)
+	switch {
+	case err != nil:
+		v.metrics.Errors.Add(1)
+	case success:
+		v.metrics.Passed.Add(1)
+	default:
+		v.metrics.Failed.Add(1)
+	}
+	return success, err
+}
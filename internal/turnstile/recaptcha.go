@@ -0,0 +1,90 @@
+package turnstile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRecaptchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+	defaultRecaptchaMinScore  = 0.5
+)
+
+// RecaptchaVerifier verifies reCAPTCHA v3 tokens. v3 never shows a challenge; instead every token
+// carries a score from 0.0 (likely a bot) to 1.0, and tokens below MinScore are rejected.
+type RecaptchaVerifier struct {
+	apiURL    string
+	secretKey string
+	minScore  float64
+	action    string
+	client    *http.Client
+}
+
+type NewRecaptchaVerifierOptions struct {
+	APIURL    string
+	SecretKey string
+	MinScore  float64 // defaults to 0.5
+	Action    string  // optional; when set the token's action must match
+}
+
+type recaptchaResponse struct {
+	Success    bool     `json:"success"`
+	Score      float64  `json:"score"`
+	Action     string   `json:"action,omitempty"`
+	Hostname   string   `json:"hostname,omitempty"`
+	ErrorCodes []string `json:"error-codes,omitempty"`
+}
+
+func NewRecaptchaVerifier(opt NewRecaptchaVerifierOptions) TokenVerifier {
+	if opt.APIURL == "" {
+		opt.APIURL = defaultRecaptchaVerifyURL
+	}
+	if opt.MinScore <= 0 {
+		opt.MinScore = defaultRecaptchaMinScore
+	}
+	return &RecaptchaVerifier{
+		apiURL:    opt.APIURL,
+		secretKey: opt.SecretKey,
+		minScore:  opt.MinScore,
+		action:    opt.Action,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *RecaptchaVerifier) Verify(opt VerifyOptions) (bool, error) {
+	if opt.Token == "" {
+		return false, fmt.Errorf("token is empty")
+	}
+	form := url.Values{}
+	form.Set("secret", v.secretKey)
+	form.Set("response", opt.Token)
+	if opt.RemoteIP != "" {
+		form.Set("remoteip", opt.RemoteIP)
+	}
+
+	resp, err := v.client.Post(v.apiURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, fmt.Errorf("failed to send verification request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var out recaptchaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !out.Success || out.Score < v.minScore {
+		return false, nil
+	}
+	if v.action != "" && out.Action != v.action {
+		return false, nil
+	}
+	return true, nil
+}